@@ -1,12 +1,16 @@
 package api
 
 import (
+	"GoAcademy/TO-DO/auth"
 	"GoAcademy/TO-DO/todo"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,7 +31,7 @@ func GetHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Default().Log(r.Context(), slog.LevelInfo, "Sending 'get' command to actor.")
 	//cmd is sent to the actor via the Store channel
-	todo.Store <- cmd
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
 
 	// Wait for the response from the actor
 	select {
@@ -44,6 +48,7 @@ func GetHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Internal server error: invalid result type", http.StatusInternalServerError)
 			return
 		}
+		todos = filterByAssignee(filterByFields(todos, fieldFilters(r.URL.Query())), assigneeFilter(r))
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(todos)
 		slog.Default().Log(r.Context(), slog.LevelInfo, "Successfully sent to-do list to client.", "items_count", len(todos))
@@ -97,7 +102,7 @@ func CreateHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request: Name cannot be empty", http.StatusBadRequest)
 		return
 	}
-	if _, err := time.Parse("02-01-2006", t.Due); err != nil {
+	if _, err := time.Parse(todo.DueLayout, t.Due); err != nil {
 		slog.Default().Log(
 			r.Context(),
 			slog.LevelWarn,
@@ -109,6 +114,39 @@ func CreateHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request: Due date must be in DD-MM-YYYY format", http.StatusBadRequest)
 		return
 	}
+	if t.Priority < 0 || t.Priority > 9 {
+		slog.Default().Log(
+			r.Context(),
+			slog.LevelWarn,
+			"Create request with invalid Priority.",
+			"priority", t.Priority,
+		)
+
+		http.Error(w, "Bad Request: Priority must be between 0 (none) and 9", http.StatusBadRequest)
+		return
+	}
+	if len(t.Notes) > todo.MaxNotesSize {
+		slog.Default().Log(
+			r.Context(),
+			slog.LevelWarn,
+			"Create request with notes over the size limit.",
+			"size", len(t.Notes),
+		)
+
+		http.Error(w, "Bad Request: Notes must be at most "+strconv.Itoa(todo.MaxNotesSize)+" bytes", http.StatusBadRequest)
+		return
+	}
+	if err := todo.ValidateEstimate(t.Estimate); err != nil {
+		slog.Default().Log(
+			r.Context(),
+			slog.LevelWarn,
+			"Create request with invalid Estimate.",
+			"estimate", t.Estimate,
+		)
+
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	slog.Default().Log(
 		r.Context(),
 		slog.LevelInfo,
@@ -126,7 +164,7 @@ func CreateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Default().Log(r.Context(), slog.LevelInfo, "Sending 'add' command to actor.")
 	//cmd is sent to the actor via the Store channel
-	todo.Store <- cmd
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
 
 	// Wait for the response from the actor
 	select {
@@ -153,6 +191,14 @@ func CreateHandler(w http.ResponseWriter, r *http.Request) {
 		)
 
 	case err := <-cmd.ErrChan:
+		if errors.Is(err, todo.ErrUnknownStatus) || errors.Is(err, todo.ErrInvalidField) {
+			// A Status or custom field value was given that the new item's list doesn't allow.
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if accessError(w, err) {
+			return
+		}
 		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -179,10 +225,15 @@ func UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		ID        int     `json:"id"`
-		Name      *string `json:"name,omitempty"`
-		Due       *string `json:"due,omitempty"`
-		Completed *bool   `json:"completed,omitempty"`
+		ID        int      `json:"id"`
+		Name      *string  `json:"name,omitempty"`
+		Due       *string  `json:"due,omitempty"`
+		Completed *bool    `json:"completed,omitempty"`
+		Priority  *int     `json:"priority,omitempty"`
+		Notes     *string  `json:"notes,omitempty"`
+		Estimate  *float64 `json:"estimate,omitempty"`
+		// Fields sets custom field values by name; "" clears one.
+		Fields map[string]string `json:"fields,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.Default().Log(
@@ -196,21 +247,52 @@ func UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	if req.Notes != nil && len(*req.Notes) > todo.MaxNotesSize {
+		slog.Default().Log(
+			r.Context(),
+			slog.LevelWarn,
+			"Update request with notes over the size limit.",
+			"size", len(*req.Notes),
+		)
+		http.Error(w, "Bad Request: Notes must be at most "+strconv.Itoa(todo.MaxNotesSize)+" bytes", http.StatusBadRequest)
+		return
+	}
+	if req.Estimate != nil {
+		if err := todo.ValidateEstimate(*req.Estimate); err != nil {
+			slog.Default().Log(
+				r.Context(),
+				slog.LevelWarn,
+				"Update request with invalid Estimate.",
+				"estimate", *req.Estimate,
+			)
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Priority != nil {
+		if err := todo.ValidatePriority(*req.Priority); err != nil {
+			slog.Default().Log(
+				r.Context(),
+				slog.LevelWarn,
+				"Update request with invalid Priority.",
+				"priority", *req.Priority,
+			)
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	cmd := todo.Command{
-		Action: todo.OpUpdate,
-		Ctx:    r.Context(),
-		UpdatePayload: struct {
-			Name      *string
-			Due       *string
-			Completed *bool
-		}{Name: req.Name, Due: req.Due, Completed: req.Completed},
-		ID:      req.ID,
-		Result:  make(chan any), // The Command creates a new channel specific to the request to receive the response from the actor
-		ErrChan: make(chan error),
+		Action:        todo.OpUpdate,
+		Ctx:           r.Context(),
+		UpdatePayload: todo.ItemUpdate{Name: req.Name, Due: req.Due, Completed: req.Completed, Priority: req.Priority, Notes: req.Notes, Estimate: req.Estimate, Fields: req.Fields},
+		ID:            req.ID,
+		Result:        make(chan any), // The Command creates a new channel specific to the request to receive the response from the actor
+		ErrChan:       make(chan error),
 	}
 	slog.Default().Log(r.Context(), slog.LevelInfo, "Sending 'update' command to actor.")
 	//cmd is sent to the actor via the Store channel
-	todo.Store <- cmd
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
 
 	// Wait for the response from the actor
 	select {
@@ -238,6 +320,13 @@ func UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		)
 
 	case err := <-cmd.ErrChan:
+		if errors.Is(err, todo.ErrInvalidField) {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if accessError(w, err) {
+			return
+		}
 		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -294,7 +383,7 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Default().Log(r.Context(), slog.LevelInfo, "Sending 'delete' command to actor.")
 	//cmd is sent to the actor via the Store channel
-	todo.Store <- cmd
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
 
 	// Wait for the response from the actor
 	select {
@@ -320,13 +409,31 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		)
 
 	case err := <-cmd.ErrChan:
+		if accessError(w, err) {
+			return
+		}
 		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 
 }
 
-var listTmpl = template.Must(template.ParseFiles("web/templates/list.html"))
+// listTmpl is parsed on first use rather than at package initialisation so that the package
+// (and its tests) can be loaded from a working directory other than the project root.
+var listTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/list.html"))
+})
+
+// listPage is the data the list template is rendered with.
+type listPage struct {
+	Items []todo.Item
+	Query string // the search box contents; when set, Items are the search results, best first
+	// Filters are the custom field values Items were filtered by, from field.<name>=<value> query parameters.
+	Filters   map[string]string
+	Assignee  string // the username Items were filtered by, from the assignee query parameter
+	User      string // the logged-in user's name, shown next to the logout button
+	CSRFToken string // see csrfToken
+}
 
 func ListHandler(w http.ResponseWriter, r *http.Request) {
 	slog.Default().Log(
@@ -336,6 +443,22 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 	)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	page := listPage{Query: strings.TrimSpace(r.URL.Query().Get("q")), Filters: fieldFilters(r.URL.Query()), Assignee: assigneeFilter(r)}
+	if page.Query != "" {
+		results, err := search(r, page.Query)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error for list page search.", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		for _, res := range results {
+			page.Items = append(page.Items, res.Item)
+		}
+		page.Items = filterByAssignee(filterByFields(page.Items, page.Filters), page.Assignee)
+		renderList(w, r, page)
+		return
+	}
+
 	// Create the command to send to the actor to get the list of items
 	cmd := todo.Command{
 		Action:  todo.OpGet,
@@ -344,7 +467,7 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 		ErrChan: make(chan error),
 	}
 	slog.Default().Log(r.Context(), slog.LevelInfo, "Sending 'get' command to actor for list page.")
-	todo.Store <- cmd
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
 
 	// Wait for the response from the actor
 	select {
@@ -356,17 +479,25 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-
-		slog.Default().Log(r.Context(), slog.LevelInfo, "Rendering list page", "items_count", len(items))
-		if err := listTmpl.Execute(w, items); err != nil {
-			slog.Default().Log(r.Context(), slog.LevelError, "Failed to render to-do list template.", "error", err)
-			http.Error(w, "Internal Server Error: could not render page", http.StatusInternalServerError)
-			return
-		}
-		slog.Default().Log(r.Context(), slog.LevelInfo, "To-do list page successfully rendered and sent to client.", "items_count", len(items))
+		page.Items = filterByAssignee(filterByFields(items, page.Filters), page.Assignee)
+		renderList(w, r, page)
 
 	case err := <-cmd.ErrChan:
 		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error for list page.", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
+
+func renderList(w http.ResponseWriter, r *http.Request, page listPage) {
+	if u, ok := auth.UserFrom(r.Context()); ok {
+		page.User = u.Username
+	}
+	page.CSRFToken = csrfToken(r)
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Rendering list page", "items_count", len(page.Items), "q", page.Query)
+	if err := listTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render to-do list template.", "error", err)
+		http.Error(w, "Internal Server Error: could not render page", http.StatusInternalServerError)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "To-do list page successfully rendered and sent to client.", "items_count", len(page.Items))
+}
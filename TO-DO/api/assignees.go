@@ -0,0 +1,170 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// myTodosTmpl is parsed on first use, like listTmpl.
+var myTodosTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/mytodos.html"))
+})
+
+// AssigneesRequest is the JSON body for setting an item's assignees, by username.
+type AssigneesRequest struct {
+	Assignees []string `json:"assignees"`
+}
+
+// myTodosPage is the data the "my tasks" template is rendered with.
+type myTodosPage struct {
+	User  string
+	Lists []myTodosList
+}
+
+// myTodosList is one list's items on the "my tasks" page. Items that aren't in a list have an empty Name.
+type myTodosList struct {
+	Name  string
+	Items []todo.Item
+}
+
+// assigneeFilter returns the username to filter by from the assignee query parameter, or "" for none.
+// "me" stands for the logged-in user.
+func assigneeFilter(r *http.Request) string {
+	assignee := auth.NormalizeUsername(r.URL.Query().Get("assignee"))
+	if assignee == "me" {
+		u, _ := auth.UserFrom(r.Context())
+		return u.Username
+	}
+	return assignee
+}
+
+// filterByAssignee keeps the items assigned to the given user. An empty username keeps every item.
+func filterByAssignee(items []todo.Item, username string) []todo.Item {
+	if username == "" {
+		return items
+	}
+	filtered := []todo.Item{}
+	for _, item := range items {
+		if todo.IsAssigned(item, username) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// AssigneesHandler serves PUT /api/v1/todos/{id}/assignees, which replaces the item's assignees with the users
+// in an AssigneesRequest. An empty list unassigns everyone.
+func AssigneesHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPut {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/assignees endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use PUT", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+		defer r.Body.Close()
+		var req AssigneesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode assignees request.", "error", err)
+			http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to set assignees.", "id", id, "assignees", req.Assignees)
+
+		cmd := todo.Command{Action: todo.OpAssign, Ctx: r.Context(), ID: id}
+		for _, name := range req.Assignees {
+			u, ok := a.Lookup(name)
+			if !ok {
+				http.Error(w, "Bad Request: no user named "+name, http.StatusBadRequest)
+				return
+			}
+			cmd.Assignees = append(cmd.Assignees, u)
+		}
+
+		result, err := dispatch(cmd)
+		if err != nil {
+			if accessError(w, err) {
+				return
+			}
+			if errors.Is(err, todo.ErrInvalidAssignee) {
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// MyTodosHandler serves GET /me/todos, the open items assigned to the logged-in user across all lists,
+// soonest due first. Browsers get a page grouped by list; other clients get the items as JSON.
+func MyTodosHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for my to-dos.")
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /me/todos endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	u, ok := auth.UserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized: log in first", http.StatusUnauthorized)
+		return
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	items := slices.DeleteFunc(filterByAssignee(result.([]todo.Item), u.Username), func(item todo.Item) bool { return item.Completed })
+	slices.SortStableFunc(items, func(x, y todo.Item) int { return dueDate(x).Compare(dueDate(y)) })
+
+	if !wantsHTML(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+	page := myTodosPage{User: u.Username}
+	for _, item := range items {
+		list := todo.ListOf(item)
+		i := slices.IndexFunc(page.Lists, func(l myTodosList) bool { return l.Name == list })
+		if i < 0 {
+			page.Lists = append(page.Lists, myTodosList{Name: list})
+			i = len(page.Lists) - 1
+		}
+		page.Lists[i].Items = append(page.Lists[i].Items, item)
+	}
+	slices.SortStableFunc(page.Lists, func(x, y myTodosList) int { return strings.Compare(x.Name, y.Name) })
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := myTodosTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render my to-dos template.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// dueDate parses an item's due date. Items with an unparseable one sort last.
+func dueDate(item todo.Item) time.Time {
+	due, err := time.Parse(todo.DueLayout, item.Due)
+	if err != nil {
+		return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return due
+}
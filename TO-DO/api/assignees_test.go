@@ -0,0 +1,93 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAssignees(t *testing.T) {
+	startTestStore(t)
+	t.Chdir("..")
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/create", CreateHandler)
+	mux.HandleFunc("/get", GetHandler)
+	mux.HandleFunc("/me/todos", MyTodosHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/assignees", AssigneesHandler(a))
+	mux.HandleFunc("/api/v1/lists/{list}/shares/{username}", ShareHandler(a))
+	handler := AuthMiddleware(a, mux)
+
+	cookies := map[string]*http.Cookie{}
+	for _, name := range []string{"ana", "bob"} {
+		u, _ := a.Register(name, "correct horse", context.Background())
+		s, _ := a.StartSession(u)
+		cookies[name] = &http.Cookie{Name: SessionCookie, Value: s.Token}
+	}
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(cookies[user])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, body := range []string{
+		`{"Name": "Paint the fence", "Due": "01-06-2026", "Projects": ["Home"]}`,
+		`{"Name": "Buy paint", "Due": "01-05-2026", "Projects": ["Home"]}`,
+		`{"Name": "File taxes", "Due": "01-04-2026"}`,
+	} {
+		if rec := do("ana", http.MethodPost, "/create", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Create failed: %d %s", rec.Code, rec.Body)
+		}
+	}
+	do("ana", http.MethodPut, "/api/v1/lists/Home/shares/bob", `{"role": "viewer"}`)
+
+	for _, tc := range []struct {
+		user, path, body string
+		code             int
+	}{
+		{"ana", "/api/v1/todos/1/assignees", `{"assignees": ["BOB", "ana"]}`, http.StatusOK},
+		{"ana", "/api/v1/todos/2/assignees", `{"assignees": ["bob"]}`, http.StatusOK},
+		{"ana", "/api/v1/todos/3/assignees", `{"assignees": ["bob"]}`, http.StatusBadRequest}, // not shared with bob
+		{"ana", "/api/v1/todos/1/assignees", `{"assignees": ["nobody"]}`, http.StatusBadRequest},
+		{"ana", "/api/v1/todos/9/assignees", `{"assignees": []}`, http.StatusNotFound},
+		{"bob", "/api/v1/todos/1/assignees", `{"assignees": []}`, http.StatusForbidden}, // viewers can't assign
+	} {
+		if rec := do(tc.user, http.MethodPut, tc.path, tc.body); rec.Code != tc.code {
+			t.Errorf("%s PUT %s %s: expected %d, got %d %s", tc.user, tc.path, tc.body, tc.code, rec.Code, rec.Body)
+		}
+	}
+
+	var items []todo.Item
+	json.NewDecoder(do("ana", http.MethodGet, "/get?assignee=me", "").Body).Decode(&items)
+	if len(items) != 1 || items[0].ID != 1 {
+		t.Errorf("Expected only item 1 to be assigned to ana, got %+v", items)
+	}
+
+	// bob's tasks come soonest due first.
+	items = nil
+	json.NewDecoder(do("bob", http.MethodGet, "/me/todos", "").Body).Decode(&items)
+	if len(items) != 2 || items[0].ID != 2 || items[1].ID != 1 {
+		t.Errorf("Expected items 2 and 1, got %+v", items)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/me/todos", nil)
+	req.AddCookie(cookies["bob"])
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "Buy paint") || strings.Contains(body, "File taxes") {
+		t.Errorf("Unexpected my tasks page: %d %s", rec.Code, body)
+	}
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// multipartOverhead allows for the multipart headers and boundaries around an uploaded file.
+const multipartOverhead = 1 << 20 // 1 MB
+
+// AttachmentsHandler serves /api/v1/todos/{id}/attachments.
+// GET lists the item's attachments; POST uploads a file sent as multipart/form-data in a field named "file".
+func AttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list attachments.", "id", id)
+		item, ok, err := getItem(r, id)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+			return
+		}
+		attachments := item.Attachments
+		if attachments == nil {
+			attachments = []todo.Attachment{}
+		}
+		json.NewEncoder(w).Encode(attachments)
+
+	case http.MethodPost:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to upload attachment.", "id", id)
+		uploadAttachment(w, r, id)
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/attachments endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+func uploadAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	// Check the item exists before reading a potentially large body.
+	if _, ok, err := getItem(r, id); err != nil || !ok {
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+		return
+	}
+
+	if todo.Quota.MaxFileSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, todo.Quota.MaxFileSize+multipartOverhead)
+	}
+	defer r.Body.Close()
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Bad Request: expected a multipart/form-data upload", http.StatusBadRequest)
+		return
+	}
+
+	// The file is streamed straight into the blob store rather than buffered in memory.
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			http.Error(w, `Bad Request: no "file" field in the upload`, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			uploadError(w, r, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		sum, size, sniffed, err := todo.InstanceFrom(r.Context()).Blobs.Put(part, todo.Quota.MaxFileSize)
+		if err != nil {
+			uploadError(w, r, err)
+			return
+		}
+		filename := sanitizeFilename(part.FileName())
+		a := todo.Attachment{
+			ID:         uuid.New().String(),
+			Filename:   filename,
+			Size:       size,
+			MIMEType:   attachmentType(sniffed, filename),
+			SHA256:     sum,
+			UploadedAt: time.Now().UTC(),
+		}
+
+		result, err := dispatch(todo.Command{Action: todo.OpAttach, Ctx: r.Context(), ID: id, Attachment: a})
+		if err != nil {
+			uploadError(w, r, err)
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Attachment uploaded.", "id", id, "attachment_id", a.ID, "size", size, "mime_type", a.MIMEType)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+}
+
+// uploadError reports a failed upload, using 413 when a size limit or quota was hit.
+func uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, todo.ErrQuotaExceeded), errors.As(err, &maxBytes):
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Attachment rejected by quota.", "error", err)
+		http.Error(w, "Request Entity Too Large: "+err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, todo.ErrNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, todo.ErrForbidden):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	default:
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to store attachment.", "error", err)
+		http.Error(w, "Internal Server Error: could not store attachment", http.StatusInternalServerError)
+	}
+}
+
+// AttachmentHandler serves /api/v1/todos/{id}/attachments/{attachment}.
+// GET downloads the file; DELETE removes it from the item.
+func AttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+	attachmentID := r.PathValue("attachment")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to download attachment.", "id", id, "attachment_id", attachmentID)
+		downloadAttachment(w, r, id, attachmentID)
+
+	case http.MethodDelete:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to delete attachment.", "id", id, "attachment_id", attachmentID)
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := dispatch(todo.Command{Action: todo.OpDetach, Ctx: r.Context(), ID: id, AttachmentID: attachmentID}); err != nil {
+			if accessError(w, err) {
+				return
+			}
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"status": "success","message":"Attachment deleted successfully."}`))
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/attachments/{attachment} endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET or DELETE", http.StatusMethodNotAllowed)
+	}
+}
+
+func downloadAttachment(w http.ResponseWriter, r *http.Request, id int, attachmentID string) {
+	item, ok, err := getItem(r, id)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var a todo.Attachment
+	found := false
+	for _, a = range item.Attachments {
+		if a.ID == attachmentID {
+			found = true
+			break
+		}
+	}
+	if !ok || !found {
+		http.Error(w, "Not Found: no such attachment", http.StatusNotFound)
+		return
+	}
+
+	f, err := todo.InstanceFrom(r.Context()).Blobs.Open(a.SHA256)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Attachment contents are missing.", "id", id, "attachment_id", a.ID, "sha256", a.SHA256, "error", err)
+		http.Error(w, "Not Found: attachment contents are missing", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	// Uploaded files are always downloaded rather than displayed, and the browser is told not to second-guess
+	// the type or run anything in them, so an uploaded HTML or SVG file can't script this site.
+	w.Header().Set("Content-Type", a.MIMEType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, "", a.UploadedAt, f)
+}
+
+// sanitizeFilename keeps only the base name of an uploaded file's name, without control characters.
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		name = name[:len(name)-1]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// attachmentType picks the MIME type stored for an upload. The sniffed type is trusted over the
+// client's; the file extension is only used when sniffing can't tell (plain text or binary data).
+func attachmentType(sniffed, filename string) string {
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func uploadRequest(t *testing.T, url, filename, contents string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(contents))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestAttachmentHandlers(t *testing.T) {
+	old := todo.Blobs
+	todo.Blobs = &todo.BlobStore{Dir: t.TempDir()}
+	t.Cleanup(func() { todo.Blobs = old })
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Renew passport", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/attachments", AttachmentsHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/attachments/{attachment}", AttachmentHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, uploadRequest(t, "/api/v1/todos/1/attachments", `C:\photos\page.html`, "<html><script>alert(1)</script></html>"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var a todo.Attachment
+	json.NewDecoder(rec.Body).Decode(&a)
+	if a.Filename != "page.html" || !strings.HasPrefix(a.MIMEType, "text/html") || a.Size != 38 {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/1/attachments/"+a.ID, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "alert(1)") {
+		t.Fatalf("Expected the file back, got %d: %s", rec.Code, rec.Body)
+	}
+	h := rec.Header()
+	if !strings.HasPrefix(h.Get("Content-Disposition"), "attachment") || h.Get("X-Content-Type-Options") != "nosniff" || h.Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("Expected the download to be served safely, got %v", h)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/todos/1/attachments/"+a.ID, nil))
+	if rec.Code != http.StatusOK || todo.Blobs.Exists(a.SHA256) {
+		t.Errorf("Expected the attachment and its blob to be deleted, got %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		req  *http.Request
+		code int
+	}{
+		{httptest.NewRequest(http.MethodGet, "/api/v1/todos/1/attachments/"+a.ID, nil), http.StatusNotFound},
+		{uploadRequest(t, "/api/v1/todos/2/attachments", "x.txt", "x"), http.StatusNotFound},
+		{httptest.NewRequest(http.MethodPost, "/api/v1/todos/1/attachments", strings.NewReader("not multipart")), http.StatusBadRequest},
+	} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, tc.req)
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.req.Method, tc.req.URL, tc.code, rec.Code)
+		}
+	}
+}
+
+func TestUploadAttachment_TooLarge(t *testing.T) {
+	oldBlobs, oldQuota := todo.Blobs, todo.Quota
+	todo.Blobs = &todo.BlobStore{Dir: t.TempDir()}
+	todo.Quota.MaxFileSize = 4
+	t.Cleanup(func() { todo.Blobs, todo.Quota = oldBlobs, oldQuota })
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Renew passport", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/attachments", AttachmentsHandler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, uploadRequest(t, "/api/v1/todos/1/attachments", "big.txt", "too big"))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"report.pdf":            "report.pdf",
+		"../../etc/passwd":      "passwd",
+		`C:\Users\me\notes.txt`: "notes.txt",
+		"bad\x00\"name\n.txt":   "badname.txt",
+		"":                      "attachment",
+		"..":                    "attachment",
+	} {
+		if got := sanitizeFilename(in); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionCookie is the name of the cookie holding the session token.
+const SessionCookie = "todo_session"
+
+// loginTmpl is parsed on first use, like listTmpl. It serves both the login and the registration page.
+var loginTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/login.html"))
+})
+
+// publicPaths can be reached without logging in; everything else needs a session.
+var publicPaths = map[string]bool{
+	"/login":                true,
+	"/register":             true,
+	"/api/v1/auth/login":    true,
+	"/api/v1/auth/register": true,
+	"/auth/oidc/login":      true,
+	"/auth/oidc/callback":   true,
+}
+
+// CredentialsRequest is the JSON body for registering and logging in.
+type CredentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SessionResponse is returned after logging in through the API. The token itself is only in the cookie.
+type SessionResponse struct {
+	User      auth.User `json:"user"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CSRFToken string    `json:"csrf_token"` // to send in the CSRFHeader with requests that change something
+}
+
+// MeResponse is the logged-in user, and the CSRF token of their session, if they are using one.
+type MeResponse struct {
+	auth.User
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+// loginPage is the data the login template is rendered with.
+type loginPage struct {
+	Register                             bool // the registration form rather than the login form
+	CanRegister                          bool // whether to offer a link to the registration form
+	Username, Next, Error                string
+	MinPasswordLength, MaxPasswordLength int
+	SSOName                              string // see SSOName
+	CSRFToken                            string // see csrfToken; set when someone logged in registers another account
+}
+
+// AuthMiddleware puts the logged-in user, if any, into the request context and turns away requests without a
+// valid session: browsers asking for a page are redirected to the login page, and anything else gets 401.
+// Scripts can send an API token as "Authorization: Bearer <token>" instead of a session cookie; the token then
+// needs the scope the route requires (see requiredScope), or the request gets 403. Administrative routes (see
+// adminOnly) are 403 for users who aren't administrators, however they log in. Attempts at guessing a password,
+// token or session are limited by the client's address; see AuthLimiter.
+func AuthMiddleware(a *auth.Accounts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret, ok := bearerToken(r); ok {
+			if authThrottled(w, r, false) {
+				return
+			}
+			u, t, err := a.AuthenticateToken(secret, r.Context())
+			if err != nil {
+				authFailed(r)
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Request with an invalid API token.", "path", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if scope := requiredScope(r); !t.Allows(scope) {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "API token lacks the scope for this route.", "token_id", t.ID, "path", r.URL.Path, "scope", scope)
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				http.Error(w, fmt.Sprintf("Forbidden: this token needs the %s scope", scope), http.StatusForbidden)
+				return
+			}
+			if !allowAdmin(w, r, u) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithToken(auth.WithUser(r.Context(), u), t)))
+			return
+		}
+		if c, err := r.Cookie(SessionCookie); err == nil {
+			if authThrottled(w, r, false) {
+				return
+			}
+			if u, _, ok := a.Session(c.Value); ok {
+				if !allowAdmin(w, r, u) {
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), u)))
+				return
+			}
+			authFailed(r)
+		}
+		if publicPaths[r.URL.Path] {
+			// Logging in and registering are where passwords are guessed, so every attempt counts.
+			if r.Method == http.MethodPost && authThrottled(w, r, true) {
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Request without a valid session.", "path", r.URL.Path)
+		if wantsHTML(r) {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Unauthorized: log in first", http.StatusUnauthorized)
+	})
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header, if there is one.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// requiredScope returns the API token scope a request needs. The administrative routes (see adminOnly) and
+// tokens themselves need admin; anything that changes data needs write; and reading needs read.
+// The WebSocket endpoint counts as writing, because updates can be sent over it.
+func requiredScope(r *http.Request) auth.Scope {
+	if adminOnly(r) || strings.HasPrefix(r.URL.Path, "/api/v1/auth/tokens") {
+		return auth.ScopeAdmin
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.URL.Path == "/api/v1/ws" {
+		return auth.ScopeWrite
+	}
+	return auth.ScopeRead
+}
+
+// adminOnly reports whether a request is for an administrative route: backups, webhooks and the server's
+// metrics under /debug/, since they expose or control everything. Only administrators may use them.
+func adminOnly(r *http.Request) bool {
+	for _, prefix := range []string{"/api/v1/admin/", "/api/v1/webhooks", "/debug/"} {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowAdmin reports whether the user may make the request, and answers 403 if not.
+func allowAdmin(w http.ResponseWriter, r *http.Request, u auth.User) bool {
+	if !adminOnly(r) || u.Admin {
+		return true
+	}
+	slog.Default().Log(r.Context(), slog.LevelWarn, "Administrative route refused.", "user_id", u.ID, "path", r.URL.Path)
+	http.Error(w, "Forbidden: only administrators may use this", http.StatusForbidden)
+	return false
+}
+
+// wantsHTML reports whether the request is a browser navigating to a page, rather than a script or API client.
+func wantsHTML(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// safeNext returns next if it is a path on this server, and the list page otherwise, so that the login
+// form can't be used to send users to another site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/list"
+	}
+	return next
+}
+
+// canRegister reports whether the request may create an account: anyone may create the first one, and
+// further ones need open registration or an administrator.
+func canRegister(a *auth.Accounts, r *http.Request) bool {
+	u, _ := auth.UserFrom(r.Context())
+	return u.Admin || a.RegistrationOpen() || a.Count() == 0
+}
+
+// startSession logs the user in and sets the session cookie.
+func startSession(w http.ResponseWriter, r *http.Request, a *auth.Accounts, u auth.User) (auth.Session, error) {
+	// Any session the client already had is replaced, so a token planted before login is worthless after it.
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		a.EndSession(c.Value)
+	}
+	s, err := a.StartSession(u)
+	if err != nil {
+		return s, err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    s.Token,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		MaxAge:   int(time.Until(s.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Default().Log(r.Context(), slog.LevelInfo, "User logged in.", "user_id", u.ID, "username", u.Username)
+	return s, nil
+}
+
+// endSession logs the request's session out and clears the cookie.
+func endSession(w http.ResponseWriter, r *http.Request, a *auth.Accounts) {
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		a.EndSession(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r), SameSite: http.SameSiteLaxMode})
+}
+
+// isHTTPS reports whether the client connected over HTTPS, directly or through a proxy that says so.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// LoginPageHandler serves /login: GET shows the login form and POST logs in with it.
+func LoginPageHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for login page.", "method", r.Method)
+		page := loginPage{CanRegister: canRegister(a, r), Next: safeNext(r.FormValue("next"))}
+		switch r.Method {
+		case http.MethodGet:
+			renderLogin(w, r, page, http.StatusOK)
+
+		case http.MethodPost:
+			page.Username = r.PostFormValue("username")
+			u, err := a.Authenticate(page.Username, r.PostFormValue("password"))
+			if err != nil {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Failed login.", "username", auth.NormalizeUsername(page.Username))
+				page.Error = err.Error()
+				renderLogin(w, r, page, http.StatusUnauthorized)
+				return
+			}
+			if _, err := startSession(w, r, a, u); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Failed to start session.", "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, page.Next, http.StatusSeeOther)
+
+		default:
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /login page.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// RegisterPageHandler serves /register: GET shows the registration form and POST creates the account and
+// logs in with it (unless a user is already logged in, creating the account for someone else).
+func RegisterPageHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for registration page.", "method", r.Method)
+		page := loginPage{Register: true, Next: safeNext(r.FormValue("next"))}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /register page.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !canRegister(a, r) {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(page.Next), http.StatusSeeOther)
+			return
+		}
+		if r.Method == http.MethodGet {
+			renderLogin(w, r, page, http.StatusOK)
+			return
+		}
+
+		page.Username = r.PostFormValue("username")
+		u, err := a.Register(page.Username, r.PostFormValue("password"), r.Context())
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Registration rejected.", "error", err)
+			page.Error = err.Error()
+			status := http.StatusBadRequest
+			if errors.Is(err, auth.ErrUserExists) {
+				status = http.StatusConflict
+			}
+			renderLogin(w, r, page, status)
+			return
+		}
+		if _, loggedIn := auth.UserFrom(r.Context()); !loggedIn {
+			if _, err := startSession(w, r, a, u); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Failed to start session.", "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Redirect(w, r, page.Next, http.StatusSeeOther)
+	}
+}
+
+func renderLogin(w http.ResponseWriter, r *http.Request, page loginPage, status int) {
+	page.MinPasswordLength, page.MaxPasswordLength = auth.MinPasswordLength, auth.MaxPasswordLength
+	page.SSOName = SSOName
+	page.CSRFToken = csrfToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := loginTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render login template.", "error", err)
+	}
+}
+
+// LogoutHandler serves POST /logout and POST /api/v1/auth/logout. The page version redirects to the login form.
+func LogoutHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for logout endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		endSession(w, r, a)
+		slog.Default().Log(r.Context(), slog.LevelInfo, "User logged out.")
+		if r.URL.Path == "/logout" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "success","message":"Logged out successfully."}`))
+	}
+}
+
+// RegisterHandler serves POST /api/v1/auth/register, creating an account from a CredentialsRequest.
+// It doesn't log in; use LoginHandler for that.
+func RegisterHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to register.")
+		req, ok := decodeCredentials(w, r)
+		if !ok {
+			return
+		}
+		if !canRegister(a, r) {
+			http.Error(w, "Forbidden: registration is closed; ask a user to create your account", http.StatusForbidden)
+			return
+		}
+		u, err := a.Register(req.Username, req.Password, r.Context())
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Registration rejected.", "error", err)
+			if errors.Is(err, auth.ErrUserExists) {
+				http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+			} else {
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u.Public())
+	}
+}
+
+// LoginHandler serves POST /api/v1/auth/login, which checks a CredentialsRequest and sets the session cookie.
+func LoginHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to log in.")
+		req, ok := decodeCredentials(w, r)
+		if !ok {
+			return
+		}
+		u, err := a.Authenticate(req.Username, req.Password)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed login.", "username", auth.NormalizeUsername(req.Username))
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		s, err := startSession(w, r, a, u)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to start session.", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(SessionResponse{User: u.Public(), ExpiresAt: s.ExpiresAt, CSRFToken: auth.CSRFToken(s.Token)})
+	}
+}
+
+// MeHandler serves GET /api/v1/auth/me, the logged-in user.
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/auth/me endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	u, ok := auth.UserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized: log in first", http.StatusUnauthorized)
+		return
+	}
+	resp := MeResponse{User: u}
+	if _, ok := auth.TokenFrom(r.Context()); !ok {
+		resp.CSRFToken = csrfToken(r)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func decodeCredentials(w http.ResponseWriter, r *http.Request) (CredentialsRequest, bool) {
+	var req CredentialsRequest
+	if r.Method != http.MethodPost {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for auth endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode credentials.", "error", err)
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
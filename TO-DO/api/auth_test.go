@@ -0,0 +1,196 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAuthServer returns accounts and a mux with the auth routes and one protected endpoint, wrapped in AuthMiddleware.
+func newAuthServer(t *testing.T, cfg auth.Config) (*auth.Accounts, http.Handler) {
+	t.Helper()
+	cfg.BcryptCost = bcrypt.MinCost
+	a, err := auth.NewAccounts(cfg, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", LoginPageHandler(a))
+	mux.HandleFunc("/register", RegisterPageHandler(a))
+	mux.HandleFunc("/logout", LogoutHandler(a))
+	mux.HandleFunc("/api/v1/auth/register", RegisterHandler(a))
+	mux.HandleFunc("/api/v1/auth/login", LoginHandler(a))
+	mux.HandleFunc("/api/v1/auth/me", MeHandler)
+	mux.HandleFunc("/api/v1/auth/tokens", TokensHandler(a))
+	mux.HandleFunc("/api/v1/auth/tokens/{id}", TokenHandler(a))
+	return a, AuthMiddleware(a, mux)
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == SessionCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	a, handler := newAuthServer(t, auth.Config{})
+
+	// A browser is sent to the login page, remembering where it was going.
+	req := httptest.NewRequest(http.MethodGet, "/list?q=milk", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login?next="+url.QueryEscape("/list?q=milk") {
+		t.Errorf("Expected a redirect to the login page, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	// Anything else gets 401.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/delete?id=1", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+
+	// The first account can be created without logging in, and logs in straight away.
+	form := url.Values{"username": {"Ana"}, "password": {"correct horse"}, "next": {"/list?q=milk"}}
+	req = httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	cookie := sessionCookie(rec)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/list?q=milk" || cookie == nil {
+		t.Fatalf("Expected to be registered and logged in, got %d %v", rec.Code, rec.Header())
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge <= 0 || cookie.Path != "/" {
+		t.Errorf("Expected a long-lived HTTP-only cookie, got %+v", cookie)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var me auth.User
+	json.NewDecoder(rec.Body).Decode(&me)
+	if rec.Code != http.StatusOK || me.Username != "ana" || me.PasswordHash != "" {
+		t.Errorf("Expected the logged-in user, got %d %+v", rec.Code, me)
+	}
+
+	// Further accounts need a logged-in user.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(`{"username": "eve", "password": "long enough"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected registration to be closed, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(`{"username": "bob", "password": "long enough"}`))
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "password_hash") {
+		t.Errorf("Expected the administrator to create an account, got %d: %s", rec.Code, rec.Body)
+	}
+	// Other users can't, even when logged in.
+	bob, _ := a.Lookup("bob")
+	bobSession, _ := a.StartSession(bob)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(`{"username": "carol", "password": "long enough"}`))
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: bobSession.Token})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a user who isn't an administrator to be refused, got %d", rec.Code)
+	}
+
+	// Logging out ends the session on the server, not just in the browser.
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || sessionCookie(rec).MaxAge >= 0 {
+		t.Errorf("Expected the cookie to be cleared, got %d %v", rec.Code, rec.Header())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the old cookie to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestLoginHandlers(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	a, handler := newAuthServer(t, auth.Config{})
+	a.Register("ana", "correct horse", context.Background())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username": "ana", "password": "wrong horse"}`)))
+	if rec.Code != http.StatusUnauthorized || sessionCookie(rec) != nil {
+		t.Errorf("Expected 401 without a cookie, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username": "ANA", "password": "correct horse"}`)))
+	var s SessionResponse
+	json.NewDecoder(rec.Body).Decode(&s)
+	if rec.Code != http.StatusOK || sessionCookie(rec) == nil || s.User.Username != "ana" || s.ExpiresAt.IsZero() {
+		t.Errorf("Expected to be logged in, got %d %+v", rec.Code, s)
+	}
+
+	// The login form shows the error and keeps the username; it never redirects to another site.
+	form := url.Values{"username": {"ana"}, "password": {"wrong horse"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if body := rec.Body.String(); rec.Code != http.StatusUnauthorized || !strings.Contains(body, "invalid username or password") || !strings.Contains(body, `value="ana"`) {
+		t.Errorf("Expected the form with an error, got %d:\n%s", rec.Code, body)
+	}
+	form.Set("password", "correct horse")
+	form.Set("next", "//evil.example.com/")
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/list" {
+		t.Errorf("Expected a redirect to the list, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// With registration closed the login page doesn't offer it, and the registration page sends users to log in.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if strings.Contains(rec.Body.String(), "/register") {
+		t.Errorf("Expected no registration link")
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/register", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("Expected a redirect to the login page, got %d", rec.Code)
+	}
+}
+
+func TestRegisterHandler_Open(t *testing.T) {
+	_, handler := newAuthServer(t, auth.Config{OpenRegistration: true})
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"username": "ana", "password": "correct horse"}`, http.StatusCreated},
+		{`{"username": "bob", "password": "long enough"}`, http.StatusCreated},
+		{`{"username": "Ana", "password": "correct horse"}`, http.StatusConflict},
+		{`{"username": "x", "password": "correct horse"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(tc.body)))
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d: %s", tc.body, tc.code, rec.Code, rec.Body)
+		}
+	}
+}
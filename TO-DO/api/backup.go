@@ -0,0 +1,91 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxRestoreSize limits the size of an uploaded backup.
+const maxRestoreSize = 50 << 20 // 50 MB
+
+// RestoreReport is the response to a restore request.
+type RestoreReport struct {
+	SchemaVersion int             `json:"schema_version"` // the version the backup was written in
+	Restored      int             `json:"restored"`
+	Errors        []todo.RowError `json:"errors,omitempty"`
+}
+
+// BackupHandler serves GET /api/v1/admin/backup, streaming a versioned snapshot of the whole list and its workflows.
+// The snapshot is taken by the actor, so it is consistent even while other requests are changing the list.
+func BackupHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received BACKUP request.")
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/admin/backup endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	snap := result.(todo.Snapshot)
+
+	filename := "todos-backup-" + time.Now().UTC().Format("20060102T150405Z") + ".json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := todo.WriteBackup(w, snap); err != nil {
+		// Headers have already been sent, so all we can do is log it.
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to write backup.", "error", err)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Backup sent to client.", "items_count", snap.ItemCount, "schema_version", todo.SchemaVersion)
+}
+
+// RestoreHandler serves POST /api/v1/admin/restore, replacing the whole list with the backup in the request body.
+// Backups written by older versions, including a plain copy of an old todos.json, are migrated first.
+// Nothing is changed unless every item in the backup is valid; invalid items are reported with 422.
+func RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received RESTORE request.")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/admin/restore endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreSize)
+	defer r.Body.Close()
+	snap, from, rowErrs, err := todo.ReadBackup(r.Body)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to read backup.", "error", err)
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := RestoreReport{SchemaVersion: from, Errors: rowErrs}
+	if len(rowErrs) > 0 {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Backup has invalid items.", "errors_count", len(rowErrs))
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	result, err := dispatch(todo.Command{Action: todo.OpRestore, Ctx: r.Context(), Items: snap.Items, Workflows: snap.Workflows, Fields: snap.Fields, Lists: snap.Lists})
+	if err != nil {
+		if accessError(w, err) {
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Restored = result.(int)
+	json.NewEncoder(w).Encode(report)
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Backup restored.", "items_count", report.Restored, "schema_version", from)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Keep me", Due: "01-01-2026"}})
+
+	rec := httptest.NewRecorder()
+	BackupHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/backup", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("Unexpected backup response %d: %v", rec.Code, rec.Header())
+	}
+	backup := rec.Body.String()
+
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Lose me", Due: "02-01-2026"}})
+
+	rec = httptest.NewRecorder()
+	RestoreHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/restore", strings.NewReader(backup)))
+	var report RestoreReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || report.Restored != 1 || report.SchemaVersion != todo.SchemaVersion {
+		t.Fatalf("Unexpected restore response %d: %+v", rec.Code, report)
+	}
+	if items := getItems(t); len(items) != 1 || items[0].Name != "Keep me" {
+		t.Errorf("Expected the list from the backup, got %+v", items)
+	}
+}
+
+func TestRestoreHandler_OldFormatAndInvalidItems(t *testing.T) {
+	startTestStore(t)
+
+	// A plain copy of an old todos.json is migrated.
+	old := `[{"ID":4,"Name":"From an old file","Completed":true,"Due":"01-01-2025"}]`
+	rec := httptest.NewRecorder()
+	RestoreHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/restore", strings.NewReader(old)))
+	var report RestoreReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || report.SchemaVersion != 0 || report.Restored != 1 {
+		t.Fatalf("Unexpected restore response %d: %+v", rec.Code, report)
+	}
+
+	// A backup with an invalid item changes nothing.
+	bad := `{"schema_version":1,"item_count":1,"items":[{"ID":1,"Name":"","Due":"01-01-2025"}]}`
+	rec = httptest.NewRecorder()
+	RestoreHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/restore", strings.NewReader(bad)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d: %s", rec.Code, rec.Body)
+	}
+	if items := getItems(t); len(items) != 1 || items[0].ID != 4 {
+		t.Errorf("Expected the list to be unchanged, got %+v", items)
+	}
+
+	rec = httptest.NewRecorder()
+	RestoreHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/restore", strings.NewReader(`{"schema_version":99}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a backup from a newer version, got %d", rec.Code)
+	}
+}
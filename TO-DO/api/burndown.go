@@ -0,0 +1,175 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// burndownTmpl is parsed on first use, like listTmpl.
+var burndownTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/burndown.html"))
+})
+
+// Chart geometry, in SVG user units. The plot area is inset by chartPad on every side to leave room for labels.
+const (
+	chartWidth  = 640
+	chartHeight = 260
+	chartPad    = 40
+)
+
+// chartSeries is one line of a chart, with its points already laid out as an SVG polyline "points" attribute.
+type chartSeries struct {
+	Name   string
+	Colour string
+	Dashed bool
+	Points string
+}
+
+// chart is a line chart drawn as inline SVG by the burndown template, so the page needs no JavaScript.
+type chart struct {
+	Title               string
+	Width, Height, Pad  int
+	Right, Bottom       int // the right and bottom edges of the plot area
+	YMax                string
+	FirstDate, LastDate string
+	Series              []chartSeries
+}
+
+// burndownPage is the data the burndown template is rendered with.
+type burndownPage struct {
+	From, To, Project, Context string // the form fields, as entered
+	Report                     todo.Burndown
+	Charts                     []chart
+	Error                      string
+}
+
+// buildBurndown reads the range and filters from the query, fetches the items and computes the burndown.
+// from and to default to the first day of the current month and today.
+func buildBurndown(r *http.Request, q url.Values) (todo.Burndown, error) {
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	from, err := parseReportDate(q.Get("from"), today.AddDate(0, 0, 1-today.Day()))
+	if err != nil {
+		return todo.Burndown{}, fmt.Errorf("from: %w", err)
+	}
+	to, err := parseReportDate(q.Get("to"), today)
+	if err != nil {
+		return todo.Burndown{}, fmt.Errorf("to: %w", err)
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+	if err != nil {
+		return todo.Burndown{}, err
+	}
+	return todo.BuildBurndown(result.([]todo.Item), from, to, strings.TrimSpace(q.Get("project")), strings.TrimSpace(q.Get("context")), now)
+}
+
+// BurndownHandler serves GET /api/v1/reports/burndown, the daily burndown and burnup series for the items in a project
+// and/or context. Query parameters: from and to (YYYY-MM-DD or DD-MM-YYYY, both included), project and context.
+func BurndownHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received burndown report request.", "query", r.URL.RawQuery)
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/reports/burndown endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := buildBurndown(r, r.URL.Query())
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid burndown report request.", "error", err)
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// BurndownPageHandler serves GET /reports/burndown, an HTML page charting the same report as BurndownHandler.
+func BurndownPageHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for burndown page.", "query", r.URL.RawQuery)
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /reports/burndown page.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	page := burndownPage{From: q.Get("from"), To: q.Get("to"), Project: q.Get("project"), Context: q.Get("context")}
+	status := http.StatusOK
+	report, err := buildBurndown(r, q)
+	if err != nil {
+		// The page is still shown, with the error next to the form, so the range can be corrected.
+		page.Error = err.Error()
+		status = http.StatusBadRequest
+	} else {
+		page.Report = report
+		page.From, page.To = report.From, report.To
+		page.Charts = burndownCharts(report)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := burndownTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render burndown template.", "error", err)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Burndown page successfully rendered and sent to client.")
+}
+
+// burndownCharts lays out the burndown and burnup charts for a report.
+func burndownCharts(b todo.Burndown) []chart {
+	if len(b.Points) == 0 {
+		return nil
+	}
+	// Both charts share a scale so they can be compared; the ideal line never exceeds the scope.
+	yMax := 1.0
+	for _, p := range b.Points {
+		yMax = max(yMax, p.Scope)
+	}
+	// The x axis covers the whole range, even the days that haven't happened yet.
+	from, _ := time.Parse(time.DateOnly, b.From)
+	to, _ := time.Parse(time.DateOnly, b.To)
+	days := int(to.Sub(from)/(24*time.Hour)) + 1
+
+	series := func(name, colour string, dashed bool, value func(todo.BurndownPoint) float64) chartSeries {
+		var pts []string
+		for i, p := range b.Points {
+			x := float64(chartPad)
+			if days > 1 {
+				x += float64(i) * float64(chartWidth-2*chartPad) / float64(days-1)
+			}
+			y := float64(chartHeight-chartPad) - value(p)/yMax*float64(chartHeight-2*chartPad)
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		return chartSeries{Name: name, Colour: colour, Dashed: dashed, Points: strings.Join(pts, " ")}
+	}
+	base := chart{
+		Width: chartWidth, Height: chartHeight, Pad: chartPad,
+		Right: chartWidth - chartPad, Bottom: chartHeight - chartPad,
+		YMax:      fmt.Sprintf("%g", yMax),
+		FirstDate: b.From, LastDate: b.To,
+	}
+
+	// The ideal line is drawn to the end of the range, not just to today.
+	ideal := series("Ideal", "#999", true, func(p todo.BurndownPoint) float64 { return p.Ideal })
+	ideal.Points += fmt.Sprintf(" %d,%d", base.Right, base.Bottom)
+
+	burndown := base
+	burndown.Title = "Burndown"
+	burndown.Series = []chartSeries{
+		ideal,
+		series("Remaining", "#c33", false, func(p todo.BurndownPoint) float64 { return p.Remaining }),
+	}
+	burnup := base
+	burnup.Title = "Burnup"
+	burnup.Series = []chartSeries{
+		series("Scope", "#36c", false, func(p todo.BurndownPoint) float64 { return p.Scope }),
+		series("Completed", "#088", false, func(p todo.BurndownPoint) float64 { return p.Completed }),
+	}
+	return []chart{burndown, burnup}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBurndownHandlers(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Design", Due: "01-01-2026", Estimate: 5, Projects: []string{"web"}}})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Build", Due: "01-01-2026", Estimate: 3, Projects: []string{"web"}}})
+	completed := true
+	dispatch(todo.Command{Action: todo.OpUpdate, ID: 1, UpdatePayload: todo.ItemUpdate{Completed: &completed}})
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	query := "?from=" + today + "&to=" + today + "&project=web"
+
+	rec := httptest.NewRecorder()
+	BurndownHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports/burndown"+query, nil))
+	var b todo.Burndown
+	json.NewDecoder(rec.Body).Decode(&b)
+	if rec.Code != http.StatusOK || len(b.Points) != 1 || b.Points[0].Scope != 8 || b.Points[0].Remaining != 3 {
+		t.Errorf("Unexpected burndown %d: %+v", rec.Code, b)
+	}
+
+	rec = httptest.NewRecorder()
+	BurndownPageHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/burndown"+query, nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || strings.Count(body, "<polyline") != 4 || strings.Contains(body, "<script") {
+		t.Errorf("Expected four SVG lines and no script, got %d:\n%s", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	BurndownPageHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/burndown?from=soon", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "dates must be") {
+		t.Errorf("Expected the page to show the error, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommentRequest is the JSON body for posting or editing a comment. Author is only used without accounts;
+// otherwise comments are posted by the logged-in user.
+type CommentRequest struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// CommentsHandler serves /api/v1/todos/{id}/comments.
+// GET lists the item's comments, oldest first; POST adds one, by the logged-in user.
+func CommentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list comments.", "id", id)
+		item, ok, err := getItem(r, id)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+			return
+		}
+		comments := item.Comments
+		if comments == nil {
+			comments = []todo.Comment{}
+		}
+		json.NewEncoder(w).Encode(comments)
+
+	case http.MethodPost:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to add comment.", "id", id)
+		req, ok := decodeCommentRequest(w, r)
+		if !ok {
+			return
+		}
+		c := todo.Comment{
+			ID:        uuid.New().String(),
+			Author:    strings.TrimSpace(req.Author),
+			Body:      req.Body,
+			CreatedAt: time.Now().UTC(),
+		}
+		if u, ok := auth.UserFrom(r.Context()); ok {
+			c.Author, c.AuthorID = u.Username, u.ID
+		}
+		if err := todo.ValidateComment(c); err != nil {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := dispatch(todo.Command{Action: todo.OpComment, Ctx: r.Context(), ID: id, Comment: c})
+		if err != nil {
+			commentError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/comments endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// CommentHandler serves /api/v1/todos/{id}/comments/{comment}.
+// PATCH replaces the comment's body; DELETE removes the comment. Both are for the comment's author and the item's
+// owners only; anyone else gets 403.
+func CommentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+	commentID := r.PathValue("comment")
+
+	switch r.Method {
+	case http.MethodPatch:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to edit comment.", "id", id, "comment_id", commentID)
+		req, ok := decodeCommentRequest(w, r)
+		if !ok {
+			return
+		}
+		// The author can't be changed, so only the body is checked here; the actor validates the edited comment as a whole.
+		if strings.TrimSpace(req.Body) == "" || len(req.Body) > todo.MaxCommentSize {
+			http.Error(w, "Bad Request: body must be between 1 and "+strconv.Itoa(todo.MaxCommentSize)+" bytes", http.StatusBadRequest)
+			return
+		}
+		result, err := dispatch(todo.Command{Action: todo.OpEditComment, Ctx: r.Context(), ID: id, Comment: todo.Comment{ID: commentID, Body: req.Body}})
+		if err != nil {
+			commentError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodDelete:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to delete comment.", "id", id, "comment_id", commentID)
+		if _, err := dispatch(todo.Command{Action: todo.OpDeleteComment, Ctx: r.Context(), ID: id, Comment: todo.Comment{ID: commentID}}); err != nil {
+			commentError(w, r, err)
+			return
+		}
+		w.Write([]byte(`{"status": "success","message":"Comment deleted successfully."}`))
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/comments/{comment} endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use PATCH or DELETE", http.StatusMethodNotAllowed)
+	}
+}
+
+func decodeCommentRequest(w http.ResponseWriter, r *http.Request) (CommentRequest, bool) {
+	// Leave room for the JSON around the body and for escaped characters.
+	r.Body = http.MaxBytesReader(w, r.Body, 2*todo.MaxCommentSize+todo.MaxAuthorLength+1024)
+	defer r.Body.Close()
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode comment request.", "error", err)
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func commentError(w http.ResponseWriter, r *http.Request, err error) {
+	if accessError(w, err) {
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
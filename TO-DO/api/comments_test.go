@@ -0,0 +1,126 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentHandlers(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Renew passport", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/comments", CommentsHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/comments/{comment}", CommentHandler)
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/v1/todos/1/comments", `{"author": " Ana ", "body": "Photos **first**"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var c todo.Comment
+	json.NewDecoder(rec.Body).Decode(&c)
+	if c.ID == "" || c.Author != "Ana" || c.CreatedAt.IsZero() {
+		t.Errorf("Unexpected comment %+v", c)
+	}
+
+	rec = serve(http.MethodPatch, "/api/v1/todos/1/comments/"+c.ID, `{"body": "Photos done"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Photos done") {
+		t.Errorf("Expected the edited comment, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(http.MethodGet, "/api/v1/todos/1/comments", "")
+	var comments []todo.Comment
+	json.NewDecoder(rec.Body).Decode(&comments)
+	if len(comments) != 1 || comments[0].Body != "Photos done" || comments[0].UpdatedAt.IsZero() {
+		t.Errorf("Unexpected thread %+v", comments)
+	}
+
+	if rec = serve(http.MethodDelete, "/api/v1/todos/1/comments/"+c.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		code              int
+	}{
+		{http.MethodPost, "/api/v1/todos/1/comments", `{"author": "Ana", "body": ""}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos/1/comments", `{"body": "anonymous"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos/2/comments", `{"author": "Ana", "body": "hi"}`, http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/todos/1/comments/" + c.ID, `{"body": "gone"}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/todos/1/comments/" + c.ID, "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/todos/1/comments", "", http.StatusMethodNotAllowed},
+	} {
+		if rec := serve(tc.method, tc.url, tc.body); rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.url, tc.code, rec.Code)
+		}
+	}
+}
+
+func TestItemPageHandler_Comments(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Renew passport", Due: "01-01-2026"}})
+	dispatch(todo.Command{Action: todo.OpComment, ID: 1, Comment: todo.Comment{ID: "c1", Author: "<Ana>", Body: "Bring *two*<script>x</script>"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/todos/{id}", ItemPageHandler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "&lt;Ana&gt;") || !strings.Contains(body, "<em>two</em>") || strings.Contains(body, "<script>x") {
+		t.Errorf("Expected an escaped author and a sanitised comment, got:\n%s", body)
+	}
+}
+
+func TestCommentHandlers_Accounts(t *testing.T) {
+	startTestStore(t)
+	ana := auth.User{ID: "1", Username: "ana"}
+	bob := auth.User{ID: "2", Username: "bob"}
+	anaCtx := auth.WithUser(context.Background(), ana)
+	dispatch(todo.Command{Action: todo.OpAdd, Ctx: anaCtx, Item: todo.Item{Name: "Renew passport", Due: "01-01-2026"}})
+	dispatch(todo.Command{Action: todo.OpShare, Ctx: anaCtx, ID: 1, Share: todo.Share{UserID: bob.ID, Role: todo.RoleEditor}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/comments", CommentsHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/comments/{comment}", CommentHandler)
+	serve := func(u auth.User, method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), u))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Comments are posted by the logged-in user, whatever the body says.
+	var bobs, anas todo.Comment
+	json.NewDecoder(serve(bob, http.MethodPost, "/api/v1/todos/1/comments", `{"author": "ana", "body": "Booked"}`).Body).Decode(&bobs)
+	if bobs.Author != "bob" || bobs.AuthorID != bob.ID {
+		t.Errorf("Expected bob's comment, got %+v", bobs)
+	}
+	json.NewDecoder(serve(ana, http.MethodPost, "/api/v1/todos/1/comments", `{"body": "Thanks"}`).Body).Decode(&anas)
+
+	// Editors can only change their own comments; the item's owner can change any.
+	if rec := serve(bob, http.MethodPatch, "/api/v1/todos/1/comments/"+anas.ID, `{"body": "Hijacked"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bob editing ana's comment, got %d", rec.Code)
+	}
+	if rec := serve(bob, http.MethodDelete, "/api/v1/todos/1/comments/"+anas.ID, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bob deleting ana's comment, got %d", rec.Code)
+	}
+	if rec := serve(bob, http.MethodPatch, "/api/v1/todos/1/comments/"+bobs.ID, `{"body": "Booked for Monday"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected bob to edit his comment, got %d", rec.Code)
+	}
+	if rec := serve(ana, http.MethodDelete, "/api/v1/todos/1/comments/"+bobs.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected ana to delete a comment on her item, got %d", rec.Code)
+	}
+}
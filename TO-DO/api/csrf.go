@@ -0,0 +1,84 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Where pages send the CSRF token: the header for fetch calls, the form field for HTML forms.
+const (
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+)
+
+// CSRFMiddleware turns away cross-site requests that would change something, so another site can't make a
+// logged-in browser act for it. It must run after AuthMiddleware.
+//
+// Safe methods (GET, HEAD, OPTIONS) always pass. For the rest, a browser saying the request came from another
+// site, through Sec-Fetch-Site or Origin, gets 403. Requests with a session cookie must also send the session's
+// CSRF token (see auth.CSRFToken) in the CSRFHeader or the CSRFField; pages get it from csrfToken.
+// Requests authenticated with an API token don't need one, since browsers never send those by themselves.
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := auth.TokenFrom(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !sameOrigin(r) {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Cross-site request rejected.", "path", r.URL.Path,
+				"origin", r.Header.Get("Origin"), "sec_fetch_site", r.Header.Get("Sec-Fetch-Site"))
+			http.Error(w, "Forbidden: cross-site request", http.StatusForbidden)
+			return
+		}
+		if _, ok := auth.UserFrom(r.Context()); ok {
+			want := csrfToken(r)
+			got := r.Header.Get(CSRFHeader)
+			// Only plain forms are parsed for the field; parsing a multipart body would leave none for the handler.
+			if got == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+				got = r.PostFormValue(CSRFField)
+			}
+			if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Request without a valid CSRF token.", "path", r.URL.Path)
+				http.Error(w, "Forbidden: missing or invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether a request may have come from this site. Browsers say where requests come from in
+// Sec-Fetch-Site, or failing that in Origin; requests with neither are from scripts, not browsers, and pass.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none": // "none" is the user's own doing, such as a bookmark
+		return true
+	case "":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// csrfToken returns the CSRF token for the request's session, for pages to send back; "" if it has no session.
+func csrfToken(r *http.Request) string {
+	c, err := r.Cookie(SessionCookie)
+	if err != nil || c.Value == "" {
+		return ""
+	}
+	return auth.CSRFToken(c.Value)
+}
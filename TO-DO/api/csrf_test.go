@@ -0,0 +1,97 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	ana, _ := a.Register("ana", "correct horse", context.Background())
+	session, _ := a.StartSession(ana)
+	_, secret, _ := a.CreateToken(ana, "ci", []auth.Scope{auth.ScopeWrite}, time.Now().Add(time.Hour), context.Background())
+	token := auth.CSRFToken(session.Token)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/v1/auth/login", LoginHandler(a))
+	mux.HandleFunc("/api/v1/auth/me", MeHandler)
+	handler := AuthMiddleware(a, CSRFMiddleware(mux))
+
+	type request struct {
+		method, path, body string
+		headers            map[string]string
+		cookie             bool
+	}
+	do := func(req request) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+		r.Host = "todo.example.com"
+		for k, v := range req.headers {
+			r.Header.Set(k, v)
+		}
+		if req.cookie {
+			r.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Token})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  request
+		want int
+	}{
+		{"safe method", request{method: http.MethodGet, path: "/list", cookie: true, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}}, http.StatusOK},
+		{"session without token", request{method: http.MethodPost, path: "/create", cookie: true}, http.StatusForbidden},
+		{"session with wrong token", request{method: http.MethodPatch, path: "/update", cookie: true, headers: map[string]string{CSRFHeader: "nope"}}, http.StatusForbidden},
+		{"session with token", request{method: http.MethodDelete, path: "/delete", cookie: true, headers: map[string]string{CSRFHeader: token}}, http.StatusOK},
+		{"session with token in form", request{method: http.MethodPost, path: "/logout", body: CSRFField + "=" + token, cookie: true,
+			headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}}, http.StatusOK},
+		{"token from another site", request{method: http.MethodPost, path: "/create", cookie: true,
+			headers: map[string]string{CSRFHeader: token, "Sec-Fetch-Site": "cross-site"}}, http.StatusForbidden},
+		{"same site isn't same origin", request{method: http.MethodPost, path: "/create", cookie: true,
+			headers: map[string]string{CSRFHeader: token, "Sec-Fetch-Site": "same-site"}}, http.StatusForbidden},
+		{"same origin", request{method: http.MethodPost, path: "/create", cookie: true,
+			headers: map[string]string{CSRFHeader: token, "Sec-Fetch-Site": "same-origin", "Origin": "https://todo.example.com"}}, http.StatusOK},
+		{"other origin", request{method: http.MethodPost, path: "/create", cookie: true,
+			headers: map[string]string{CSRFHeader: token, "Origin": "https://evil.example"}}, http.StatusForbidden},
+		{"null origin", request{method: http.MethodPost, path: "/create", cookie: true, headers: map[string]string{CSRFHeader: token, "Origin": "null"}}, http.StatusForbidden},
+		{"login from another site", request{method: http.MethodPost, path: "/api/v1/auth/login", body: `{"username": "ana", "password": "correct horse"}`,
+			headers: map[string]string{"Origin": "https://evil.example"}}, http.StatusForbidden},
+		{"API token", request{method: http.MethodPost, path: "/create", headers: map[string]string{"Authorization": "Bearer " + secret}}, http.StatusOK},
+	} {
+		if rec := do(tc.req); rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.want, rec.Code, rec.Body)
+		}
+	}
+
+	// Scripts logging in with a session get the token to send back, and can ask for it again.
+	rec := do(request{method: http.MethodPost, path: "/api/v1/auth/login", body: `{"username": "ana", "password": "correct horse"}`})
+	var login SessionResponse
+	json.NewDecoder(rec.Body).Decode(&login)
+	if login.CSRFToken == "" || login.CSRFToken == token {
+		t.Errorf("Expected a CSRF token for the new session, got %q", login.CSRFToken)
+	}
+	var me MeResponse
+	json.NewDecoder(do(request{method: http.MethodGet, path: "/api/v1/auth/me", cookie: true}).Body).Decode(&me)
+	if me.CSRFToken != token || me.Username != "ana" {
+		t.Errorf("Expected ana's CSRF token, got %+v", me)
+	}
+	me = MeResponse{}
+	json.NewDecoder(do(request{method: http.MethodGet, path: "/api/v1/auth/me", headers: map[string]string{"Authorization": "Bearer " + secret}}).Body).Decode(&me)
+	if me.CSRFToken != "" {
+		t.Errorf("Expected no CSRF token for an API token, got %q", me.CSRFToken)
+	}
+}
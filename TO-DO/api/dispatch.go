@@ -0,0 +1,22 @@
+package api
+
+import "GoAcademy/TO-DO/todo"
+
+// dispatch sends cmd to the actor and waits for it to reply on either the Result or the ErrChan channel.
+// Result and ErrChan are created here if the caller hasn't supplied them.
+func dispatch(cmd todo.Command) (any, error) {
+	if cmd.Result == nil {
+		cmd.Result = make(chan any)
+	}
+	if cmd.ErrChan == nil {
+		cmd.ErrChan = make(chan error)
+	}
+	todo.InstanceFrom(cmd.Ctx).Store <- cmd
+
+	select {
+	case result := <-cmd.Result:
+		return result, nil
+	case err := <-cmd.ErrChan:
+		return nil, err
+	}
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize limits the size of an uploaded import file.
+const maxImportSize = 10 << 20 // 10 MB
+
+// exportFormat describes how to write the list in one file format.
+// The query parameters are passed on for format-specific options; check, if set, validates them
+// before anything is written so that a bad option can still be answered with 400.
+type exportFormat struct {
+	contentType string
+	filename    string
+	write       func(w io.Writer, items []todo.Item, query url.Values) error
+	check       func(query url.Values) error
+}
+
+// exportFormats are the values accepted by the format query parameter of the export endpoint.
+var exportFormats = map[string]exportFormat{
+	"csv": {"text/csv; charset=utf-8", "todos.csv",
+		func(w io.Writer, items []todo.Item, _ url.Values) error { return todo.WriteCSV(w, items) }, nil},
+	"ics": {"text/calendar; charset=utf-8", "todos.ics",
+		func(w io.Writer, items []todo.Item, _ url.Values) error { return todo.WriteICS(w, items) }, nil},
+	"todotxt": {"text/plain; charset=utf-8", "todo.txt",
+		func(w io.Writer, items []todo.Item, _ url.Values) error { return todo.WriteTodoTxt(w, items) }, nil},
+	"markdown": {"text/markdown; charset=utf-8", "todos.md",
+		func(w io.Writer, items []todo.Item, query url.Values) error {
+			return todo.WriteMarkdown(w, items, query.Get("group"))
+		}, checkMarkdownGroup},
+}
+
+// checkMarkdownGroup validates the group query parameter of a Markdown export.
+func checkMarkdownGroup(query url.Values) error {
+	switch g := query.Get("group"); g {
+	case todo.MarkdownGroupNone, todo.MarkdownGroupCompletion, todo.MarkdownGroupDue:
+		return nil
+	default:
+		return fmt.Errorf("group must be %q or %q, got %q", todo.MarkdownGroupCompletion, todo.MarkdownGroupDue, g)
+	}
+}
+
+// importFormat parses an uploaded file. The query parameters are passed on for format-specific options.
+type importFormat func(r io.Reader, query url.Values) ([]todo.Item, []todo.RowError, error)
+
+// importFormats are the values accepted by the format query parameter of the import endpoint.
+var importFormats = map[string]importFormat{
+	"csv":      readCSV,
+	"ics":      func(r io.Reader, _ url.Values) ([]todo.Item, []todo.RowError, error) { return todo.ReadICS(r) },
+	"todotxt":  func(r io.Reader, _ url.Values) ([]todo.Item, []todo.RowError, error) { return todo.ReadTodoTxt(r) },
+	"markdown": func(r io.Reader, _ url.Values) ([]todo.Item, []todo.RowError, error) { return todo.ReadMarkdown(r) },
+}
+
+// importContentTypes lets clients pick the import format with a Content-Type header instead of ?format=.
+var importContentTypes = map[string]string{
+	"text/csv":      "csv",
+	"text/calendar": "ics",
+	"text/markdown": "markdown",
+}
+
+// readCSV parses a CSV import, applying any map=<header>:<field> query parameters.
+func readCSV(r io.Reader, query url.Values) ([]todo.Item, []todo.RowError, error) {
+	mapping := make(map[string]string)
+	for _, m := range query["map"] {
+		header, field, ok := strings.Cut(m, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("map %q must be of the form <header>:<field>", m)
+		}
+		mapping[header] = field
+	}
+	return todo.ReadCSV(r, mapping)
+}
+
+// ImportReport is the response to an import request.
+type ImportReport struct {
+	DryRun   bool            `json:"dry_run"`
+	Imported int             `json:"imported"`
+	Items    []todo.Item     `json:"items"`
+	Errors   []todo.RowError `json:"errors,omitempty"`
+}
+
+// ExportHandler serves GET /api/v1/todos/export?format=csv|ics|todotxt|markdown, streaming every item as a downloadable file.
+// For markdown, group=completion|due groups the checklist under headings.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received EXPORT request for to-do list.", "format", r.URL.Query().Get("format"))
+	exportList(w, r, r.URL.Query().Get("format"))
+}
+
+// ICalFeedHandler serves GET /api/v1/todos.ics, a calendar feed that calendar apps can subscribe to.
+func ICalFeedHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received iCalendar feed request.")
+	exportList(w, r, "ics")
+}
+
+func exportList(w http.ResponseWriter, r *http.Request, format string) {
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/export endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if format == "" {
+		format = "csv"
+	}
+	exporter, ok := exportFormats[format]
+	if !ok {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Unsupported export format.", "format", format)
+		http.Error(w, "Bad Request: unsupported format "+strconv.Quote(format), http.StatusBadRequest)
+		return
+	}
+	if exporter.check != nil {
+		if err := exporter.check(r.URL.Query()); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid export options.", "format", format, "error", err)
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := result.([]todo.Item)
+
+	w.Header().Set("Content-Type", exporter.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exporter.filename+`"`)
+	if err := exporter.write(w, items, r.URL.Query()); err != nil {
+		// Headers have already been sent, so all we can do is log it.
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to write export.", "format", format, "error", err)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Export sent to client.", "format", format, "items_count", len(items))
+}
+
+// ImportHandler serves POST /api/v1/todos/import, adding the items in the request body.
+//
+// Query parameters:
+//   - format=csv|ics|todotxt|markdown picks the file format. Without it the Content-Type decides, defaulting to CSV.
+//   - dry_run=true validates the file and reports what would be imported without changing anything.
+//   - map=<header>:<field> (CSV only) maps a column onto "name", "due", "completed" or "ignore"; it may be repeated.
+//
+// An import is all or nothing: if any row is invalid, no items are added and the errors are returned with 422.
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received IMPORT request for to-do list.")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/import endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+	format := query.Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format = importContentTypes[mediaType]
+	}
+	if format == "" {
+		format = "csv"
+	}
+	importer, ok := importFormats[format]
+	if !ok {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Unsupported import format.", "format", format)
+		http.Error(w, "Bad Request: unsupported format "+strconv.Quote(format), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	defer r.Body.Close()
+	items, rowErrs, err := importer(r.Body, query)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to parse import.", "error", err)
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := ImportReport{DryRun: dryRun, Items: items, Errors: rowErrs}
+	if report.Items == nil {
+		report.Items = []todo.Item{}
+	}
+	if len(rowErrs) > 0 {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Import has invalid rows.", "errors_count", len(rowErrs), "dry_run", dryRun)
+		if !dryRun {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if dryRun {
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Dry-run import validated.", "items_count", len(items))
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	result, err := dispatch(todo.Command{Action: todo.OpImport, Ctx: r.Context(), Items: items})
+	if err != nil {
+		if errors.Is(err, todo.ErrInvalidField) {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if accessError(w, err) {
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Items = result.([]todo.Item)
+	report.Imported = len(report.Items)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Import applied.", "items_count", report.Imported)
+}
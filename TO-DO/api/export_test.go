@@ -0,0 +1,178 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getItems reads the actor's current items.
+func getItems(t *testing.T) []todo.Item {
+	t.Helper()
+	result, err := dispatch(todo.Command{Action: todo.OpGet})
+	if err != nil {
+		t.Fatalf("OpGet failed: %v", err)
+	}
+	return result.([]todo.Item)
+}
+
+func TestImportHandler(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Existing", Due: "01-01-2026"}})
+
+	csv := "Task,Deadline,Done\nFirst,02-01-2026,no\nSecond,03-01-2026,yes\n"
+
+	// Dry run reports what would happen but changes nothing.
+	rec := httptest.NewRecorder()
+	ImportHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/todos/import?dry_run=true", strings.NewReader(csv)))
+	var report ImportReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || !report.DryRun || len(report.Items) != 2 || report.Imported != 0 {
+		t.Errorf("Unexpected dry-run response %d: %+v", rec.Code, report)
+	}
+	if n := len(getItems(t)); n != 1 {
+		t.Fatalf("Dry run changed the store: %d items", n)
+	}
+
+	// A real import assigns IDs following on from the existing items.
+	rec = httptest.NewRecorder()
+	ImportHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/todos/import", strings.NewReader(csv)))
+	report = ImportReport{}
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusCreated || report.Imported != 2 {
+		t.Fatalf("Unexpected import response %d: %+v", rec.Code, report)
+	}
+	if report.Items[0].ID != 2 || report.Items[1].ID != 3 || !report.Items[1].Completed {
+		t.Errorf("Unexpected imported items: %+v", report.Items)
+	}
+	if n := len(getItems(t)); n != 3 {
+		t.Errorf("Expected 3 items after import, got %d", n)
+	}
+}
+
+func TestImportHandler_InvalidRowsRejectWholeFile(t *testing.T) {
+	startTestStore(t)
+
+	csv := "Name,Due\nGood,01-01-2026\nBad,tomorrow\n"
+	rec := httptest.NewRecorder()
+	ImportHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/todos/import", strings.NewReader(csv)))
+	var report ImportReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusUnprocessableEntity || len(report.Errors) != 1 || report.Errors[0].Row != 2 {
+		t.Errorf("Unexpected response %d: %+v", rec.Code, report)
+	}
+	if n := len(getItems(t)); n != 0 {
+		t.Errorf("Expected nothing to be imported, got %d items", n)
+	}
+}
+
+func TestImportHandler_ColumnMapping(t *testing.T) {
+	startTestStore(t)
+
+	csv := "Summary,When\nMapped,01-01-2026\n"
+	rec := httptest.NewRecorder()
+	ImportHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/todos/import?map=Summary:name&map=When:due", strings.NewReader(csv)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if items := getItems(t); len(items) != 1 || items[0].Name != "Mapped" {
+		t.Errorf("Unexpected items: %+v", items)
+	}
+}
+
+func TestExportHandler_CSV(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Export me", Due: "01-01-2026"}})
+
+	rec := httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/export?format=csv", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if want := "ID,Name,Due,Completed\n1,Export me,01-01-2026,false\n"; rec.Body.String() != want {
+		t.Errorf("Expected %q, got %q", want, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/export?format=xls", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown format, got %d", rec.Code)
+	}
+}
+
+func TestICalFeedAndImport(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Calendar me", Due: "01-01-2026", Priority: 2}})
+
+	rec := httptest.NewRecorder()
+	ICalFeedHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos.ics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("Unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	feed := rec.Body.String()
+	if !strings.Contains(feed, "SUMMARY:Calendar me\r\n") || !strings.Contains(feed, "PRIORITY:2\r\n") {
+		t.Errorf("Unexpected feed:\n%s", feed)
+	}
+
+	// The feed can be imported again; the Content-Type picks the format.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/todos/import", strings.NewReader(feed))
+	req.Header.Set("Content-Type", "text/calendar")
+	rec = httptest.NewRecorder()
+	ImportHandler(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	items := getItems(t)
+	if len(items) != 2 || items[1].Name != "Calendar me" || items[1].Due != "01-01-2026" || items[1].Priority != 2 {
+		t.Errorf("Unexpected items after import: %+v", items)
+	}
+}
+
+func TestTodoTxtExportAndImport(t *testing.T) {
+	startTestStore(t)
+
+	body := "(A) 2025-12-01 Call the garage +Car @phone due:2026-01-05\n"
+	rec := httptest.NewRecorder()
+	ImportHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/todos/import?format=todotxt", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/export?format=todotxt", nil))
+	if rec.Body.String() != body {
+		t.Errorf("Expected export to match the import\n got %q\nwant %q", rec.Body.String(), body)
+	}
+}
+
+func TestMarkdownExportAndImport(t *testing.T) {
+	startTestStore(t)
+
+	body := "# Notes\n\n- [ ] Book flights (due 01-01-2026)\n- [x] Pay rent (due 05-01-2026)\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/todos/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/markdown")
+	rec := httptest.NewRecorder()
+	ImportHandler(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if items := getItems(t); len(items) != 2 || items[0].Completed || !items[1].Completed {
+		t.Fatalf("Expected checkbox state to be preserved, got %+v", items)
+	}
+
+	rec = httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/export?format=markdown&group=completion", nil))
+	want := "## Open\n\n- [ ] Book flights (due 01-01-2026)\n\n## Done\n\n- [x] Pay rent (due 05-01-2026)\n\n"
+	if rec.Body.String() != want {
+		t.Errorf("Unexpected export\n got %q\nwant %q", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	ExportHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/export?format=markdown&group=nope", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown group, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fieldFilterPrefix marks the query parameters that filter items by custom field, e.g. ?field.customer=Acme.
+const fieldFilterPrefix = "field."
+
+// fieldFilters collects the custom field filters from a query. Empty values are ignored, so an empty filter form
+// field doesn't hide every item.
+func fieldFilters(q url.Values) map[string]string {
+	var filters map[string]string
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, fieldFilterPrefix)
+		if !ok || name == "" || strings.TrimSpace(values[0]) == "" {
+			continue
+		}
+		if filters == nil {
+			filters = map[string]string{}
+		}
+		filters[name] = values[0]
+	}
+	return filters
+}
+
+// filterByFields keeps the items that have every one of the filtered values.
+func filterByFields(items []todo.Item, filters map[string]string) []todo.Item {
+	if len(filters) == 0 {
+		return items
+	}
+	filtered := []todo.Item{}
+	for _, item := range items {
+		if todo.MatchFields(item, filters) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// listFields fetches the custom field definitions of every list.
+func listFields(r *http.Request) (map[string][]todo.FieldDef, error) {
+	result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+	if err != nil {
+		return nil, err
+	}
+	return result.(todo.Snapshot).Fields, nil
+}
+
+// FieldsHandler serves GET /api/v1/fields, the custom fields of every list that has any.
+func FieldsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list custom fields.")
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/fields endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	fields, err := listFields(r)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if fields == nil {
+		fields = map[string][]todo.FieldDef{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fields)
+}
+
+// ListFieldsHandler serves /api/v1/fields/{list}, where the list is a project name.
+// GET returns the list's custom fields; PUT replaces them with the JSON array in the body; DELETE removes them all.
+// PUT and DELETE fail with 409 Conflict while items of the list have values the new fields don't allow.
+func ListFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	list := r.PathValue("list")
+
+	var cmd todo.Command
+	switch r.Method {
+	case http.MethodGet:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for custom fields.", "list", list)
+		fields, err := listFields(r)
+		if err != nil {
+			fieldError(w, r, err)
+			return
+		}
+		defs := fields[list]
+		if defs == nil {
+			defs = []todo.FieldDef{}
+		}
+		json.NewEncoder(w).Encode(defs)
+		return
+
+	case http.MethodPut:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to set custom fields.", "list", list)
+		r.Body = http.MaxBytesReader(w, r.Body, 256<<10)
+		defer r.Body.Close()
+		var defs []todo.FieldDef
+		if err := json.NewDecoder(r.Body).Decode(&defs); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode custom fields.", "error", err)
+			http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := todo.ValidateFieldDefs(defs); err != nil {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cmd = todo.Command{Action: todo.OpSetFields, Ctx: r.Context(), List: list, FieldDefs: defs}
+
+	case http.MethodDelete:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to remove custom fields.", "list", list)
+		cmd = todo.Command{Action: todo.OpSetFields, Ctx: r.Context(), List: list}
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/fields/{list} endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET, PUT or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := dispatch(cmd)
+	if err != nil {
+		fieldError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func fieldError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, todo.ErrInvalidField):
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, todo.ErrFieldsInUse):
+		http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+	case errors.Is(err, todo.ErrNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, todo.ErrForbidden):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	default:
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
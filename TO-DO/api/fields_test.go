@@ -0,0 +1,109 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFieldHandlers(t *testing.T) {
+	startTestStore(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fields", FieldsHandler)
+	mux.HandleFunc("/api/v1/fields/{list}", ListFieldsHandler)
+	mux.HandleFunc("/create", CreateHandler)
+	mux.HandleFunc("/update", UpdateHandler)
+	mux.HandleFunc("/get", GetHandler)
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+
+	defs := `[{"name": "customer", "type": "text"}, {"name": "env", "type": "enum", "options": ["staging", "production"]}]`
+	if rec := serve(http.MethodPut, "/api/v1/fields/Support", defs); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec := serve(http.MethodGet, "/api/v1/fields", "")
+	var all map[string][]todo.FieldDef
+	json.NewDecoder(rec.Body).Decode(&all)
+	if len(all["Support"]) != 2 || all["Support"][1].Type != todo.FieldEnum {
+		t.Errorf("Unexpected fields %+v", all)
+	}
+
+	serve(http.MethodPost, "/create", `{"Name": "Outage", "Due": "01-01-2026", "Projects": ["Support"], "Fields": {"customer": "Acme", "env": "production"}}`)
+	serve(http.MethodPost, "/create", `{"Name": "Upgrade", "Due": "01-01-2026", "Projects": ["Support"], "Fields": {"customer": "Globex"}}`)
+	if rec := serve(http.MethodPatch, "/update", `{"id": 2, "fields": {"env": "staging"}}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the update to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(http.MethodGet, "/get?field.customer=acme", "")
+	var items []todo.Item
+	json.NewDecoder(rec.Body).Decode(&items)
+	if len(items) != 1 || items[0].Name != "Outage" {
+		t.Errorf("Expected only Acme's item, got %+v", items)
+	}
+	rec = serve(http.MethodGet, "/get?field.env=staging&field.customer=", "")
+	items = nil
+	json.NewDecoder(rec.Body).Decode(&items)
+	if len(items) != 1 || items[0].Name != "Upgrade" {
+		t.Errorf("Expected only the staging item, got %+v", items)
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		code              int
+	}{
+		{http.MethodPost, "/create", `{"Name": "Bad", "Due": "01-01-2026", "Projects": ["Support"], "Fields": {"env": "dev"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/create", `{"Name": "Bad", "Due": "01-01-2026", "Fields": {"customer": "Acme"}}`, http.StatusBadRequest},
+		{http.MethodPatch, "/update", `{"id": 1, "fields": {"seats": "3"}}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/fields/Support", `[{"name": "customer", "type": "text"}]`, http.StatusConflict},
+		{http.MethodDelete, "/api/v1/fields/Support", "", http.StatusConflict},
+		{http.MethodPut, "/api/v1/fields/Support", `[{"name": "x", "type": "colour"}]`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/fields/Support", `{"name": "x"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/fields", "", http.StatusMethodNotAllowed},
+	} {
+		if rec := serve(tc.method, tc.url, tc.body); rec.Code != tc.code {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tc.method, tc.url, tc.body, tc.code, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := serve(http.MethodGet, "/api/v1/fields/Other", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected no fields for another list, got %s", rec.Body)
+	}
+}
+
+func TestItemAndListPages_Fields(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	startTestStore(t)
+	defs := []todo.FieldDef{
+		{Name: "env", Label: "Environment", Type: todo.FieldEnum, Options: []string{"staging", "production"}},
+		{Name: "ticket", Type: todo.FieldURL},
+	}
+	dispatch(todo.Command{Action: todo.OpSetFields, List: "Support", FieldDefs: defs})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Outage", Due: "01-01-2026", Projects: []string{"Support"},
+		Fields: map[string]string{"env": "production", "ticket": "https://tracker.example.com/T-1"}}})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Unrelated", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/todos/{id}", ItemPageHandler)
+	mux.HandleFunc("/list", ListHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Environment") || !strings.Contains(body, `<option value="production" selected>`) || !strings.Contains(body, `href="https://tracker.example.com/T-1"`) {
+		t.Errorf("Expected the custom fields form, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/list?field.env=production", nil))
+	body = rec.Body.String()
+	if !strings.Contains(body, "Outage") || strings.Contains(body, "Unrelated") || !strings.Contains(body, `href="/list?field.env=production"`) {
+		t.Errorf("Expected the list filtered by env, got:\n%s", body)
+	}
+}
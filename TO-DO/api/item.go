@@ -0,0 +1,111 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// itemTmpl is parsed on first use, like listTmpl.
+var itemTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/item.html"))
+})
+
+// itemPage is the data the item template is rendered with.
+type itemPage struct {
+	Item         todo.Item
+	NotesHTML    template.HTML // Item.Notes rendered from Markdown and sanitised
+	MaxNotesSize int
+	Comments     []renderedComment
+	Fields       []itemField // the custom fields of the item's list, in the list's order
+	CSRFToken    string      // see csrfToken
+}
+
+// itemField is a custom field of the item's list with the item's value for it, if any.
+type itemField struct {
+	todo.FieldDef
+	Value string
+}
+
+// renderedComment is a comment with its body rendered from Markdown and sanitised, like the notes.
+type renderedComment struct {
+	todo.Comment
+	BodyHTML template.HTML
+}
+
+// getItem fetches one item from the actor. ok is false if there is no item with that ID.
+func getItem(r *http.Request, id int) (item todo.Item, ok bool, err error) {
+	result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+	if err != nil {
+		return todo.Item{}, false, err
+	}
+	for _, item := range result.([]todo.Item) {
+		if item.ID == id {
+			return item, true, nil
+		}
+	}
+	return todo.Item{}, false, nil
+}
+
+// ItemPageHandler serves GET /todos/{id}, an HTML page showing one item with its notes
+// and comments rendered from Markdown.
+func ItemPageHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for item page.", "id", r.PathValue("id"))
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /todos/{id} page.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+
+	item, ok, err := getItem(r, id)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error for item page.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+		return
+	}
+
+	notes, err := renderNotes(item.Notes)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render notes.", "id", id, "error", err)
+		http.Error(w, "Internal Server Error: could not render notes", http.StatusInternalServerError)
+		return
+	}
+	fields, err := listFields(r)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error for item page.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page := itemPage{Item: item, NotesHTML: notes, MaxNotesSize: todo.MaxNotesSize, CSRFToken: csrfToken(r)}
+	for _, def := range todo.FieldsFor(fields, item) {
+		page.Fields = append(page.Fields, itemField{FieldDef: def, Value: item.Fields[def.Name]})
+	}
+	for _, c := range item.Comments {
+		body, err := renderNotes(c.Body)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to render comment.", "id", id, "comment_id", c.ID, "error", err)
+			http.Error(w, "Internal Server Error: could not render comments", http.StatusInternalServerError)
+			return
+		}
+		page.Comments = append(page.Comments, renderedComment{Comment: c, BodyHTML: body})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := itemTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render item template.", "error", err)
+		http.Error(w, "Internal Server Error: could not render page", http.StatusInternalServerError)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Item page successfully rendered and sent to client.", "id", id)
+}
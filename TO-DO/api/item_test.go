@@ -0,0 +1,101 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderNotes_Sanitises(t *testing.T) {
+	tests := []struct {
+		notes   string
+		want    []string
+		notWant []string
+	}{
+		{"## Plan\n\n- [x] **book** taxi\n- [ ] pack", []string{"<h2", "<strong>book</strong>", `type="checkbox"`, "checked"}, nil},
+		{"<script>alert(1)</script>", nil, []string{"<script", "alert(1)</script>"}},
+		{`<img src=x onerror="alert(1)">`, nil, []string{"onerror"}},
+		{"[click](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"[docs](https://example.com)", []string{`href="https://example.com"`, `rel="nofollow"`}, nil},
+		{"| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}, nil},
+	}
+	for _, tt := range tests {
+		html, err := renderNotes(tt.notes)
+		if err != nil {
+			t.Fatalf("renderNotes(%q) failed: %v", tt.notes, err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(string(html), w) {
+				t.Errorf("renderNotes(%q) = %q, expected it to contain %q", tt.notes, html, w)
+			}
+		}
+		for _, nw := range tt.notWant {
+			if strings.Contains(string(html), nw) {
+				t.Errorf("renderNotes(%q) = %q, must not contain %q", tt.notes, html, nw)
+			}
+		}
+	}
+}
+
+func TestItemPageHandler(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Renew <passport>", Due: "01-01-2026", Notes: "Bring **two** photos<script>x</script>"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/todos/{id}", ItemPageHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, body)
+	}
+	if !strings.Contains(body, "Renew &lt;passport&gt;") || !strings.Contains(body, "<strong>two</strong>") || strings.Contains(body, "<script>x") {
+		t.Errorf("Expected an escaped name and sanitised notes, got:\n%s", body)
+	}
+
+	for url, code := range map[string]int{"/todos/2": http.StatusNotFound, "/todos/abc": http.StatusBadRequest} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", url, code, rec.Code)
+		}
+	}
+}
+
+func TestUpdateHandler_NotesSizeLimit(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Task", Due: "01-01-2026"}})
+
+	body := `{"id": 1, "notes": "` + strings.Repeat("x", todo.MaxNotesSize+1) + `"}`
+	rec := httptest.NewRecorder()
+	UpdateHandler(rec, httptest.NewRequest(http.MethodPatch, "/update", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for oversized notes, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateHandler(rec, httptest.NewRequest(http.MethodPatch, "/update", strings.NewReader(`{"id": 1, "notes": "Some *notes*"}`)))
+	if items := getItems(t); rec.Code != http.StatusCreated || items[0].Notes != "Some *notes*" {
+		t.Errorf("Expected notes to be saved, got %d %+v", rec.Code, items)
+	}
+}
+
+func TestUpdateHandler_InvalidPriority(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Task", Due: "01-01-2026"}})
+
+	for body, code := range map[string]int{`{"id": 1, "priority": 10}`: http.StatusBadRequest, `{"id": 1, "priority": -1}`: http.StatusBadRequest, `{"id": 1, "priority": 3}`: http.StatusCreated} {
+		rec := httptest.NewRecorder()
+		UpdateHandler(rec, httptest.NewRequest(http.MethodPatch, "/update", strings.NewReader(body)))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d %s", body, code, rec.Code, rec.Body)
+		}
+	}
+	if items := getItems(t); items[0].Priority != 3 {
+		t.Errorf("Expected priority 3, got %+v", items)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// notesMarkdown converts notes to HTML. GitHub Flavored Markdown is enabled (tables, task lists,
+// strikethrough, autolinks); raw HTML in the notes is dropped rather than passed through.
+var notesMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// notesPolicy sanitises the rendered HTML as a second line of defence: only markup that Markdown
+// itself produces survives, links get rel="nofollow", and URLs are limited to safe schemes.
+var notesPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Task list items are rendered as disabled checkboxes.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// renderNotes returns notes as sanitised HTML that is safe to insert into a page.
+func renderNotes(notes string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := notesMarkdown.Convert([]byte(notes), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(notesPolicy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/oidc"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SSOName is what the login page calls the single sign-on provider in its "Log in with ..." link.
+// Empty, the default, means single sign-on isn't set up and the link isn't shown.
+var SSOName string
+
+// oidcCookie holds the state of a single sign-on login between leaving for the provider and coming back.
+const oidcCookie = "todo_oidc"
+
+// oidcLoginTimeout is how long the user has to log in at the provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+// oidcLogin is what oidcCookie holds. It only ever goes to the browser that started the login, so a value
+// someone plants in another browser can't be completed with their code.
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// OIDCLoginHandler serves GET /auth/oidc/login, which sends the browser to the provider to log in,
+// remembering where to go afterwards (the next parameter).
+func OIDCLoginHandler(p *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to log in with single sign-on.")
+		if r.Method != http.MethodGet {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /auth/oidc/login endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		login := oidcLogin{Next: safeNext(r.URL.Query().Get("next"))}
+		var challenge string
+		var err error
+		if login.State, err = oidc.RandomString(); err == nil {
+			if login.Nonce, err = oidc.RandomString(); err == nil {
+				login.Verifier, challenge, err = oidc.NewPKCE()
+			}
+		}
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to start single sign-on.", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		value, _ := json.Marshal(login)
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcCookie,
+			Value:    base64.RawURLEncoding.EncodeToString(value),
+			Path:     "/auth/oidc/",
+			MaxAge:   int(oidcLoginTimeout.Seconds()),
+			HttpOnly: true,
+			Secure:   isHTTPS(r),
+			// Lax, not Strict: the provider sends the browser back with a top-level navigation from its own site.
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, p.AuthCodeURL(login.State, login.Nonce, challenge), http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler serves GET /auth/oidc/callback, where the provider sends the browser back with a code.
+// The code is exchanged for an ID token, which is checked, and the user it names is logged in, getting an account
+// first if they haven't logged in before (see auth.Accounts.ProvisionUser). Failures show the login page.
+func OIDCCallbackHandler(a *auth.Accounts, p *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received single sign-on callback.")
+		if r.Method != http.MethodGet {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /auth/oidc/callback endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		fail := func(status int, msg string, kv ...any) {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Single sign-on failed.", append([]any{"reason", msg}, kv...)...)
+			renderLogin(w, r, loginPage{CanRegister: canRegister(a, r), Next: "/list", Error: "Single sign-on failed: " + msg}, status)
+		}
+
+		// The login state can only be used once.
+		http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r), SameSite: http.SameSiteLaxMode})
+		var login oidcLogin
+		c, err := r.Cookie(oidcCookie)
+		if err == nil {
+			var value []byte
+			if value, err = base64.RawURLEncoding.DecodeString(c.Value); err == nil {
+				err = json.Unmarshal(value, &login)
+			}
+		}
+		q := r.URL.Query()
+		switch {
+		case err != nil || login.State == "":
+			fail(http.StatusBadRequest, "the login expired or was started in another browser; please try again")
+			return
+		case subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(login.State)) != 1:
+			fail(http.StatusBadRequest, "the login state doesn't match; please try again")
+			return
+		case q.Get("error") != "":
+			fail(http.StatusUnauthorized, "the provider said "+q.Get("error"), "description", q.Get("error_description"))
+			return
+		case q.Get("code") == "":
+			fail(http.StatusBadRequest, "the provider sent no code")
+			return
+		}
+
+		rawIDToken, err := p.Exchange(q.Get("code"), login.Verifier, r.Context())
+		if err != nil {
+			fail(http.StatusBadGateway, "the code couldn't be exchanged", "error", err)
+			return
+		}
+		claims, err := p.Verify(rawIDToken, login.Nonce, r.Context())
+		if err != nil {
+			fail(http.StatusUnauthorized, "the provider's ID token was rejected", "error", err)
+			return
+		}
+		suggested := claims.PreferredUsername
+		if suggested == "" {
+			suggested = claims.Email
+		}
+		u, created, err := a.ProvisionUser(claims.Issuer, claims.Subject, suggested, r.Context())
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to provision single sign-on user.", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := startSession(w, r, a, u); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to start session.", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "User logged in with single sign-on.", "user_id", u.ID, "new_account", created)
+		http.Redirect(w, r, login.Next, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/oidc"
+	"GoAcademy/TO-DO/oidc/oidctest"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	t.Chdir("..")
+	ctx := context.Background()
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, ctx)
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+
+	// The app's URL is the redirect URL, so its server is started before the handlers exist.
+	mux := http.NewServeMux()
+	app := httptest.NewServer(AuthMiddleware(a, mux))
+	t.Cleanup(app.Close)
+	fake, provider, err := oidctest.NewServer("todo")
+	if err != nil {
+		t.Fatalf("Starting the fake provider failed: %v", err)
+	}
+	t.Cleanup(provider.Close)
+	fake.User = oidctest.Identity{Subject: "ana-123", PreferredUsername: "ana", Email: "ana@example.com"}
+	p, err := oidc.Discover(oidc.Config{Issuer: fake.Issuer, ClientID: "todo", RedirectURL: app.URL + "/auth/oidc/callback"}, ctx)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	mux.HandleFunc("/auth/oidc/login", OIDCLoginHandler(p))
+	mux.HandleFunc("/auth/oidc/callback", OIDCCallbackHandler(a, p))
+	mux.HandleFunc("/api/v1/auth/me", MeHandler)
+	mux.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("the list")) })
+
+	// Logging in goes to the provider and back, creates ana's account and ends up where the user was going.
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(app.URL + "/auth/oidc/login?next=/list")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "the list" {
+		t.Fatalf("Expected to end up on the list, got %d %s", resp.StatusCode, body)
+	}
+	resp, _ = client.Get(app.URL + "/api/v1/auth/me")
+	var me auth.User
+	json.NewDecoder(resp.Body).Decode(&me)
+	resp.Body.Close()
+	if me.Username != "ana" || me.Subject != "ana-123" || me.Issuer != fake.Issuer {
+		t.Errorf("Unexpected user %+v", me)
+	}
+
+	// A callback that this browser didn't start, such as one forged by another site, is refused.
+	resp, _ = client.Get(app.URL + "/auth/oidc/callback?code=stolen&state=forged")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "Single sign-on failed") {
+		t.Errorf("Expected a callback without a login to be refused, got %d %s", resp.StatusCode, body)
+	}
+
+	// Logging in again finds the same account.
+	client.Get(app.URL + "/auth/oidc/login")
+	if a.Count() != 1 {
+		t.Errorf("Expected one account, got %d", a.Count())
+	}
+}
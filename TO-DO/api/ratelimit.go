@@ -0,0 +1,120 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/ratelimit"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrustProxy makes RateLimitMiddleware take the client's address from the last X-Forwarded-For entry, as added by
+// a reverse proxy in front of the server. Leave it off otherwise, or clients could pick their own address.
+var TrustProxy bool
+
+// AuthLimiter, when set, limits by client address the requests that could be guessing a password, an API token or
+// a session: every attempt at logging in or registering, and every request whose token or session is refused.
+// Once an address has used up its ratelimit.ClassAuth bucket, its requests with credentials are refused with 429
+// before they are checked. AuthMiddleware applies it, since RateLimitMiddleware only sees requests that got past it.
+var AuthLimiter *ratelimit.Limiter
+
+// RateLimitMiddleware gives every client a token bucket per class of route (see ratelimit.Limiter), so that no one
+// client can keep the actor busy. Clients are told apart by their API token, else their user, else their address,
+// so it must run after AuthMiddleware. Reads are GET and HEAD requests; anything else, and the WebSocket endpoint,
+// is a write. Limited responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers, and a request over the limit gets 429 Too Many Requests with Retry-After.
+func RateLimitMiddleware(l *ratelimit.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := rateClass(r)
+		if !l.Limits(class) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := clientKey(r)
+		if !rateLimited(w, r, l.Allow(class, key), key, class) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// rateLimited sets the rate limit headers from res and, if the request isn't allowed, answers 429 and reports true.
+func rateLimited(w http.ResponseWriter, r *http.Request, res ratelimit.Result, key string, class ratelimit.Class) bool {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(res.Limit)+";w="+ceilSeconds(res.Window))
+	if res.Allowed {
+		return false
+	}
+	slog.Default().Log(r.Context(), slog.LevelWarn, "Request over the rate limit.", "client", key, "class", class, "path", r.URL.Path)
+	h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+	http.Error(w, "Too Many Requests: slow down", http.StatusTooManyRequests)
+	return true
+}
+
+// authThrottled answers 429 and reports true if the client's address has no attempts at authenticating left (see
+// AuthLimiter). With take, the request uses up an attempt; otherwise it is only checked, and authFailed takes one
+// if it turns out to be wrong.
+func authThrottled(w http.ResponseWriter, r *http.Request, take bool) bool {
+	if AuthLimiter == nil || !AuthLimiter.Limits(ratelimit.ClassAuth) {
+		return false
+	}
+	key := "ip:" + clientIP(r)
+	res := AuthLimiter.Peek(ratelimit.ClassAuth, key)
+	if take {
+		res = AuthLimiter.Allow(ratelimit.ClassAuth, key)
+	}
+	return rateLimited(w, r, res, key, ratelimit.ClassAuth)
+}
+
+// authFailed uses up one of the client's attempts at authenticating, for a request whose credentials were refused.
+func authFailed(r *http.Request) {
+	if AuthLimiter != nil {
+		AuthLimiter.Allow(ratelimit.ClassAuth, "ip:"+clientIP(r))
+	}
+}
+
+// rateClass returns the class of route a request is counted against.
+func rateClass(r *http.Request) ratelimit.Class {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.URL.Path == "/api/v1/ws" {
+		return ratelimit.ClassWrite
+	}
+	return ratelimit.ClassRead
+}
+
+// clientKey identifies the client making a request: "token:<id>", "user:<id>" or "ip:<address>".
+func clientKey(r *http.Request) string {
+	if t, ok := auth.TokenFrom(r.Context()); ok {
+		return "token:" + t.ID
+	}
+	if u, ok := auth.UserFrom(r.Context()); ok {
+		return "user:" + u.ID
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the address a request came from; see TrustProxy.
+func clientIP(r *http.Request) string {
+	if TrustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ceilSeconds formats d as a whole number of seconds, rounded up, as the rate limit headers want.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/ratelimit"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	ana, _ := a.Register("ana", "correct horse", context.Background())
+	session, _ := a.StartSession(ana)
+	_, secret, _ := a.CreateToken(ana, "ci", []auth.Scope{auth.ScopeWrite}, time.Now().Add(time.Hour), context.Background())
+
+	l := ratelimit.New(ratelimit.Config{Rates: map[ratelimit.Class]ratelimit.Rate{
+		ratelimit.ClassRead:  {PerSecond: 1.0 / 3600, Burst: 2},
+		ratelimit.ClassWrite: {PerSecond: 1.0 / 3600, Burst: 1},
+	}})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	handler := AuthMiddleware(a, RateLimitMiddleware(l, mux))
+
+	do := func(method, path, remoteAddr string, cookie bool, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		if cookie {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Token})
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := do(http.MethodGet, "/get", "192.0.2.1:1234", true, "")
+		if rec.Code != want {
+			t.Fatalf("Read %d: expected %d, got %d", i, want, rec.Code)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Policy") != "2;w=7200" {
+			t.Errorf("Read %d: unexpected headers %v", i, rec.Header())
+		}
+	}
+	rec := do(http.MethodGet, "/get", "192.0.2.1:1234", true, "")
+	if rec.Header().Get("Retry-After") != "3600" || rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("RateLimit-Reset") != "7200" {
+		t.Errorf("Expected to be told to wait an hour, got %v", rec.Header())
+	}
+
+	// Writes have a bucket of their own, and the same user with an API token is a different client.
+	if rec := do(http.MethodPost, "/create", "192.0.2.1:1234", true, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the first write to be allowed, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/create", "192.0.2.1:1234", true, ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second write to be rejected, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/ws", "192.0.2.1:1234", false, secret); rec.Code != http.StatusOK {
+		t.Errorf("Expected the token's first write to be allowed, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/create", "192.0.2.1:1234", false, secret); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the token's second write to be rejected, got %d", rec.Code)
+	}
+
+	// Clients that aren't logged in are told apart by their address.
+	if rec := do(http.MethodPost, "/api/v1/auth/login", "192.0.2.1:1234", false, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the first login to be allowed, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/auth/login", "192.0.2.1:5678", false, ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a login from the same address to be rejected, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/auth/login", "198.51.100.7:1234", false, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected a login from another address to be allowed, got %d", rec.Code)
+	}
+
+	if got := l.Metrics().Get("rejected.write.token").String(); got != "1" {
+		t.Errorf("Expected 1 write rejected by token, got %s", got)
+	}
+}
+
+func TestAuthMiddleware_LimitsFailedAuthentication(t *testing.T) {
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	ana, _ := a.Register("ana", "correct horse", context.Background())
+	_, secret, _ := a.CreateToken(ana, "ci", []auth.Scope{auth.ScopeRead}, time.Now().Add(time.Hour), context.Background())
+
+	AuthLimiter = ratelimit.New(ratelimit.Config{Rates: map[ratelimit.Class]ratelimit.Rate{
+		ratelimit.ClassAuth: {PerSecond: 1.0 / 3600, Burst: 3},
+	}})
+	t.Cleanup(func() { AuthLimiter = nil })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	handler := AuthMiddleware(a, mux)
+
+	do := func(method, path, remoteAddr, token, cookie string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A good token doesn't use up any attempts; a wrong token, a wrong session and a login each use one.
+	for range 5 {
+		if code := do(http.MethodGet, "/get", "192.0.2.1:1234", secret, ""); code != http.StatusOK {
+			t.Fatalf("Expected the right token to be allowed, got %d", code)
+		}
+	}
+	for i, code := range []int{
+		do(http.MethodGet, "/get", "192.0.2.1:1234", "todo_wrong", ""),
+		do(http.MethodGet, "/get", "192.0.2.1:1234", "", "wrong"),
+		do(http.MethodPost, "/api/v1/auth/login", "192.0.2.1:1234", "", ""),
+	} {
+		if code == http.StatusTooManyRequests {
+			t.Errorf("Attempt %d: expected to be allowed, got %d", i, code)
+		}
+	}
+	// With no attempts left, even the right token isn't checked, and neither is a login.
+	if code := do(http.MethodGet, "/get", "192.0.2.1:1234", secret, ""); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the token to be refused once the attempts are used up, got %d", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/auth/login", "192.0.2.1:5678", "", ""); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the login to be refused once the attempts are used up, got %d", code)
+	}
+	// Other addresses, and requests without credentials, aren't affected.
+	if code := do(http.MethodGet, "/get", "198.51.100.7:1234", secret, ""); code != http.StatusOK {
+		t.Errorf("Expected another address to be allowed, got %d", code)
+	}
+	if code := do(http.MethodGet, "/login", "192.0.2.1:1234", "", ""); code != http.StatusOK {
+		t.Errorf("Expected the login page to be shown, got %d", code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/get", nil)
+	r.RemoteAddr = "10.0.0.1:4321"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 192.0.2.44")
+	if got := clientIP(r); got != "10.0.0.1" {
+		t.Errorf("Expected X-Forwarded-For to be ignored, got %s", got)
+	}
+	TrustProxy = true
+	defer func() { TrustProxy = false }()
+	if got := clientIP(r); got != "192.0.2.44" {
+		t.Errorf("Expected the address the proxy saw, got %s", got)
+	}
+}
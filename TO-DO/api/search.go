@@ -0,0 +1,65 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultSearchLimit is the number of results returned when the request doesn't set limit.
+const defaultSearchLimit = 50
+
+// SearchHandler serves GET /api/v1/todos/search?q=<terms>&limit=<n>, returning the matching items best first.
+// Every word of q must match the start of a word in the item, ignoring case, so "rep gar" finds "Repair the garage".
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received SEARCH request for to-do list.", "q", query)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/search endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(query) == "" {
+		http.Error(w, "Bad Request: q is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			http.Error(w, "Bad Request: limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, err := search(r, query)
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	json.NewEncoder(w).Encode(results)
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Search results sent to client.", "q", query, "results_count", len(results))
+}
+
+// search asks the actor to run a full-text search.
+func search(r *http.Request, query string) ([]todo.SearchResult, error) {
+	result, err := dispatch(todo.Command{Action: todo.OpSearch, Ctx: r.Context(), Query: query})
+	if err != nil {
+		return nil, err
+	}
+	results := result.([]todo.SearchResult)
+	if results == nil {
+		results = []todo.SearchResult{}
+	}
+	return results, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearchHandler(t *testing.T) {
+	startTestStore(t)
+	for _, name := range []string{"Repair the garage door", "Write quarterly report", "Buy milk"} {
+		dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: name, Due: "01-01-2026"}})
+	}
+
+	rec := httptest.NewRecorder()
+	SearchHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/search?q=Rep", nil))
+	var results []todo.SearchResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(results) != 2 || results[0].Item.Name != "Write quarterly report" {
+		t.Errorf("Unexpected response %d: %+v", rec.Code, results)
+	}
+
+	rec = httptest.NewRecorder()
+	SearchHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/search?q=rep&limit=1", nil))
+	results = nil
+	json.NewDecoder(rec.Body).Decode(&results)
+	if len(results) != 1 {
+		t.Errorf("Expected limit to cut the results to 1, got %d", len(results))
+	}
+
+	rec = httptest.NewRecorder()
+	SearchHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/todos/search?q=nothing", nil))
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected an empty array, got %s", rec.Body)
+	}
+
+	for _, url := range []string{"/api/v1/todos/search", "/api/v1/todos/search?q=a&limit=0"} {
+		rec = httptest.NewRecorder()
+		SearchHandler(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rec.Code)
+		}
+	}
+}
+
+func TestListTemplate_SearchBox(t *testing.T) {
+	tmpl := template.Must(template.ParseFiles("../web/templates/list.html"))
+
+	var out strings.Builder
+	page := listPage{Query: `"garage"`}
+	if err := tmpl.Execute(&out, page); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `value="&#34;garage&#34;"`) || !strings.Contains(out.String(), "No to-do items match") {
+		t.Errorf("Expected the escaped query and a no-results message, got:\n%s", out.String())
+	}
+}
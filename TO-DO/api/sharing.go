@@ -0,0 +1,175 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ShareRequest is the JSON body for sharing an item or list with a user.
+type ShareRequest struct {
+	Role todo.Role `json:"role"`
+}
+
+// ShareEntry is one user's role on an item or list.
+type ShareEntry struct {
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	Role     todo.Role `json:"role"`
+}
+
+// SharesResponse describes who owns an item or list and who it is shared with. Owner is nil for items from
+// before there were accounts, and for lists nobody has claimed; those are open to everyone.
+type SharesResponse struct {
+	Owner  *ShareEntry  `json:"owner"`
+	Shares []ShareEntry `json:"shares"`
+}
+
+// accessError writes 404, 403 or 409 for errors from the actor's permission and quota checks, and reports whether it did.
+// Items the caller can't see come back from the actor as not found, so the response never reveals they exist.
+func accessError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, todo.ErrNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, todo.ErrForbidden):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	case errors.Is(err, todo.ErrItemQuotaExceeded):
+		http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
+// SharesHandler serves GET /api/v1/todos/{id}/shares and GET /api/v1/lists/{list}/shares, the owner of an item
+// or list and the users it is shared with.
+func SharesHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for shares endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for shares.", "path", r.URL.Path)
+
+		var (
+			owner  string
+			shares map[string]todo.Role
+		)
+		if list := r.PathValue("list"); list != "" {
+			result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+			if err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			access := result.(todo.Snapshot).Lists[list]
+			owner, shares = access.Owner, access.Shares
+		} else {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+				return
+			}
+			result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+			if err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			items := result.([]todo.Item)
+			i := slices.IndexFunc(items, func(item todo.Item) bool { return item.ID == id })
+			if i < 0 {
+				http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+				return
+			}
+			owner, shares = items[i].Owner, items[i].Shares
+		}
+
+		resp := SharesResponse{Shares: []ShareEntry{}}
+		if owner != "" {
+			resp.Owner = &ShareEntry{UserID: owner, Username: username(a, owner), Role: todo.RoleOwner}
+		}
+		for userID, role := range shares {
+			resp.Shares = append(resp.Shares, ShareEntry{UserID: userID, Username: username(a, userID), Role: role})
+		}
+		slices.SortFunc(resp.Shares, func(x, y ShareEntry) int { return strings.Compare(x.Username, y.Username) })
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ShareHandler serves /api/v1/todos/{id}/shares/{username} and /api/v1/lists/{list}/shares/{username}.
+// PUT gives the user the role in a ShareRequest and DELETE takes their share away. Only owners may share.
+func ShareHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		cmd := todo.Command{Action: todo.OpShare, Ctx: r.Context(), List: r.PathValue("list")}
+		if cmd.List == "" {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+				return
+			}
+			cmd.ID = id
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			r.Body = http.MaxBytesReader(w, r.Body, 4096)
+			defer r.Body.Close()
+			var req ShareRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode share request.", "error", err)
+				http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+				return
+			}
+			role, err := todo.ParseRole(string(req.Role))
+			if err != nil {
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			cmd.Share.Role = role
+		case http.MethodDelete:
+		default:
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for share endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use PUT or DELETE", http.StatusMethodNotAllowed)
+			return
+		}
+
+		u, ok := a.Lookup(r.PathValue("username"))
+		if !ok {
+			http.Error(w, "Not Found: no user named "+r.PathValue("username"), http.StatusNotFound)
+			return
+		}
+		cmd.Share.UserID = u.ID
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to change sharing.", "id", cmd.ID, "list", cmd.List, "user_id", u.ID, "role", cmd.Share.Role)
+
+		result, err := dispatch(cmd)
+		if err != nil {
+			if accessError(w, err) {
+				return
+			}
+			if errors.Is(err, todo.ErrInvalidRole) {
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// username returns the name of the user with the given ID, or "" for a deleted account.
+func username(a *auth.Accounts, userID string) string {
+	u, _ := a.User(userID)
+	return u.Username
+}
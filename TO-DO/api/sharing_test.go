@@ -0,0 +1,120 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSharing(t *testing.T) {
+	startTestStore(t)
+	a, err := auth.NewAccounts(auth.Config{BcryptCost: bcrypt.MinCost}, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/create", CreateHandler)
+	mux.HandleFunc("/get", GetHandler)
+	mux.HandleFunc("/update", UpdateHandler)
+	mux.HandleFunc("/delete", DeleteHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/shares", SharesHandler(a))
+	mux.HandleFunc("/api/v1/todos/{id}/shares/{username}", ShareHandler(a))
+	mux.HandleFunc("/api/v1/lists/{list}/shares", SharesHandler(a))
+	mux.HandleFunc("/api/v1/lists/{list}/shares/{username}", ShareHandler(a))
+	handler := AuthMiddleware(a, mux)
+
+	cookies := map[string]*http.Cookie{}
+	for _, name := range []string{"ana", "bob"} {
+		u, _ := a.Register(name, "correct horse", context.Background())
+		s, _ := a.StartSession(u)
+		cookies[name] = &http.Cookie{Name: SessionCookie, Value: s.Token}
+	}
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(cookies[user])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("ana", http.MethodPost, "/create", `{"Name": "Paint the fence", "Due": "01-05-2026", "Projects": ["Home"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Create failed: %d %s", rec.Code, rec.Body)
+	}
+
+	// To bob, ana's item doesn't exist.
+	var items []todo.Item
+	json.NewDecoder(do("bob", http.MethodGet, "/get", "").Body).Decode(&items)
+	if len(items) != 0 {
+		t.Errorf("Expected bob to see nothing, got %+v", items)
+	}
+	for _, tc := range [][2]string{{http.MethodPatch, "/update"}, {http.MethodDelete, "/delete?id=1"}, {http.MethodGet, "/api/v1/todos/1/shares"}} {
+		if rec := do("bob", tc[0], tc[1], `{"id": 1, "name": "Mine now"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tc[0], tc[1], rec.Code)
+		}
+	}
+
+	// Once shared as a viewer, bob sees it but is forbidden to change it.
+	if rec := do("ana", http.MethodPut, "/api/v1/lists/Home/shares/BOB", `{"role": "viewer"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Share failed: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("bob", http.MethodPatch, "/update", `{"id": 1, "name": "Mine now"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+	if rec := do("bob", http.MethodPut, "/api/v1/todos/1/shares/bob", `{"role": "owner"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected bob not to share ana's item, got %d", rec.Code)
+	}
+	var shares SharesResponse
+	json.NewDecoder(do("bob", http.MethodGet, "/api/v1/lists/Home/shares", "").Body).Decode(&shares)
+	if shares.Owner == nil || shares.Owner.Username != "ana" || len(shares.Shares) != 1 || shares.Shares[0].Role != todo.RoleViewer {
+		t.Errorf("Unexpected shares %+v", shares)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		code       int
+	}{
+		{"/api/v1/todos/1/shares/nobody", `{"role": "viewer"}`, http.StatusNotFound},
+		{"/api/v1/todos/1/shares/bob", `{"role": "boss"}`, http.StatusBadRequest},
+		{"/api/v1/todos/1/shares/ana", `{"role": "viewer"}`, http.StatusForbidden}, // the owner's own role
+	} {
+		if rec := do("ana", http.MethodPut, tc.path, tc.body); rec.Code != tc.code {
+			t.Errorf("PUT %s %s: expected %d, got %d", tc.path, tc.body, tc.code, rec.Code)
+		}
+	}
+
+	// Taking the share away hides the item again.
+	if rec := do("ana", http.MethodDelete, "/api/v1/lists/Home/shares/bob", ""); rec.Code != http.StatusOK {
+		t.Errorf("Unshare failed: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("bob", http.MethodPatch, "/update", `{"id": 1, "name": "Mine now"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after unsharing, got %d", rec.Code)
+	}
+}
+
+func TestAccessError(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		handled bool
+		code    int
+	}{
+		{fmt.Errorf("item with id 1 %w", todo.ErrNotFound), true, http.StatusNotFound},
+		{fmt.Errorf("%w: item 1 needs the owner role", todo.ErrForbidden), true, http.StatusForbidden},
+		{fmt.Errorf("%w: at most 10 items are allowed", todo.ErrItemQuotaExceeded), true, http.StatusConflict},
+		// Only the sentinel means not found, whatever other errors say.
+		{errors.New("open todos.json: file not found"), false, 0},
+	} {
+		rec := httptest.NewRecorder()
+		if handled := accessError(rec, tc.err); handled != tc.handled || (handled && rec.Code != tc.code) {
+			t.Errorf("%v: expected %v %d, got %v %d", tc.err, tc.handled, tc.code, handled, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/tenant"
+	"GoAcademy/TO-DO/todo"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// TenantMiddleware sends the commands of every request for a tenant to that tenant's store, starting it if need be
+// (see tenant.Registry). It must run after AuthMiddleware: only the tenant's members get its store. An invalid
+// tenant name is 400 Bad Request, an unknown tenant 404 Not Found, and a tenant the user isn't a member of
+// 403 Forbidden. Requests without a tenant, and requests without a user (the login and registration pages, where
+// accounts are shared by every tenant), are passed on unchanged and use the default store.
+func TenantMiddleware(reg *tenant.Registry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, err := reg.Resolve(r)
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid tenant.", "error", err)
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		u, loggedIn := auth.UserFrom(r.Context())
+		if name == "" || !loggedIn {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := reg.Authorize(name, u); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Tenant refused.", "tenant", name, "error", err)
+			if errors.Is(err, tenant.ErrUnknownTenant) {
+				http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+			}
+			return
+		}
+		inst, release, err := reg.Acquire(name, r.Context())
+		if err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to start tenant store.", "tenant", name, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		// The store stays running at least until the request, or WebSocket connection, is done with it.
+		defer release()
+		next.ServeHTTP(w, r.WithContext(todo.WithInstance(r.Context(), inst)))
+	})
+}
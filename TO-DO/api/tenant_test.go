@@ -0,0 +1,87 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/tenant"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	startTestStore(t)
+	dir := t.TempDir()
+	reg := tenant.NewRegistry(tenant.Config{Dir: dir, MaxItems: 1,
+		Members: map[string][]string{"acme": {"ana"}, "globex": {"ana", "bob"}}})
+	t.Cleanup(func() { reg.Stop(context.Background()) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/create", CreateHandler)
+	mux.HandleFunc("/get", GetHandler)
+	handler := TenantMiddleware(reg, mux)
+
+	ana := auth.User{ID: "1", Username: "ana"}
+	bob := auth.User{ID: "2", Username: "bob"}
+	doAs := func(u *auth.User, tenantName, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if tenantName != "" {
+			req.Header.Set(tenant.Header, tenantName)
+		}
+		if u != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), *u))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	do := func(tenantName, method, path, body string) *httptest.ResponseRecorder {
+		return doAs(&ana, tenantName, method, path, body)
+	}
+
+	if rec := do("acme", http.MethodPost, "/create", `{"Name": "Acme's task", "Due": "01-05-2026"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Create failed: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("acme", http.MethodPost, "/create", `{"Name": "Over quota", "Due": "01-05-2026"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected the quota to be 409, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("", http.MethodPost, "/create", `{"Name": "Default task", "Due": "01-05-2026"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Create in the default store failed: %d %s", rec.Code, rec.Body)
+	}
+
+	for tenantName, want := range map[string]string{"acme": "Acme's task", "": "Default task"} {
+		var items []todo.Item
+		json.NewDecoder(do(tenantName, http.MethodGet, "/get", "").Body).Decode(&items)
+		if len(items) != 1 || items[0].Name != want {
+			t.Errorf("Tenant %q: expected only %q, got %+v", tenantName, want, items)
+		}
+	}
+	var items []todo.Item
+	json.NewDecoder(do("globex", http.MethodGet, "/get", "").Body).Decode(&items)
+	if len(items) != 0 {
+		t.Errorf("Expected a new tenant to start empty, got %+v", items)
+	}
+
+	if rec := do("Not/A Tenant", http.MethodGet, "/get", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid tenant to be 400, got %d", rec.Code)
+	}
+
+	// Only members get a tenant's store, and only tenants that are configured exist.
+	if rec := doAs(&bob, "acme", http.MethodGet, "/get", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a non-member to be 403, got %d", rec.Code)
+	}
+	if rec := do("initech", http.MethodGet, "/get", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown tenant to be 404, got %d", rec.Code)
+	}
+	// Requests without a user never start a store, whatever tenant they name.
+	if rec := doAs(nil, "initech", http.MethodGet, "/get", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected a request without a user to reach the handler, got %d", rec.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "initech")); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory for an unknown tenant, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimerRequest is the JSON body for starting or stopping a timer. User is only needed without accounts;
+// otherwise it is the logged-in user, and naming anyone else is 403.
+type TimerRequest struct {
+	User string `json:"user"`
+	Note string `json:"note"`
+}
+
+// TimeEntryRequest is the JSON body for entering time by hand. Start and End are RFC 3339 timestamps; User is as
+// in TimerRequest.
+type TimeEntryRequest struct {
+	User  string    `json:"user"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Note  string    `json:"note"`
+}
+
+// maxTimeRequestSize bounds the JSON bodies of the time tracking endpoints.
+const maxTimeRequestSize = 8 << 10 // 8 KB
+
+// TimerStartHandler serves POST /api/v1/todos/{id}/timer, which starts a timer for the logged-in user on the item.
+// A user can only have one timer running; starting another one answers 409 Conflict.
+func TimerStartHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/timer endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+	var req TimerRequest
+	if !decodeTimeRequest(w, r, &req) {
+		return
+	}
+	user, ok := timeUser(w, r, req.User)
+	if !ok {
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to start timer.", "id", id, "user", user)
+
+	e := todo.TimeEntry{ID: uuid.New().String(), User: user, Start: time.Now().UTC(), Note: req.Note}
+	if err := todo.ValidateTimeEntry(e); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpStartTimer, Ctx: r.Context(), ID: id, TimeEntry: e})
+	if err != nil {
+		timeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// TimerHandler serves /api/v1/timer. GET shows the logged-in user's running timer; POST stops it.
+// Both answer 404 when the user has no timer running. Naming another user, with ?user= or {"user"}, is 403.
+func TimerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		user, ok := timeUser(w, r, r.URL.Query().Get("user"))
+		if !ok {
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for running timer.", "user", user)
+		result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+		if err != nil {
+			timeError(w, r, err)
+			return
+		}
+		running, ok := todo.RunningTimer(result.([]todo.Item), user)
+		if !ok {
+			http.Error(w, "Not Found: no timer is running for "+user, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(running)
+
+	case http.MethodPost:
+		var req TimerRequest
+		if !decodeTimeRequest(w, r, &req) {
+			return
+		}
+		user, ok := timeUser(w, r, req.User)
+		if !ok {
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to stop timer.", "user", user)
+		result, err := dispatch(todo.Command{Action: todo.OpStopTimer, Ctx: r.Context(), TimeEntry: todo.TimeEntry{User: user}})
+		if err != nil {
+			timeError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/timer endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// TimeEntriesHandler serves /api/v1/todos/{id}/time-entries.
+// GET lists the item's time entries; POST adds a finished entry entered by hand, for the logged-in user.
+func TimeEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list time entries.", "id", id)
+		item, ok, err := getItem(r, id)
+		if err != nil {
+			timeError(w, r, err)
+			return
+		}
+		if !ok {
+			http.Error(w, "Not Found: no item with id "+strconv.Itoa(id), http.StatusNotFound)
+			return
+		}
+		entries := item.TimeEntries
+		if entries == nil {
+			entries = []todo.TimeEntry{}
+		}
+		json.NewEncoder(w).Encode(entries)
+
+	case http.MethodPost:
+		var req TimeEntryRequest
+		if !decodeTimeRequest(w, r, &req) {
+			return
+		}
+		user, ok := timeUser(w, r, req.User)
+		if !ok {
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to add time entry.", "id", id, "user", user)
+		e := todo.TimeEntry{ID: uuid.New().String(), User: user, Start: req.Start.UTC(), End: req.End.UTC(), Note: req.Note}
+		if err := todo.ValidateTimeEntry(e); err != nil || e.Running() {
+			if err == nil {
+				err = errors.New("end is required")
+			}
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := dispatch(todo.Command{Action: todo.OpAddTimeEntry, Ctx: r.Context(), ID: id, TimeEntry: e})
+		if err != nil {
+			timeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/time-entries endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// TimeEntryHandler serves DELETE /api/v1/todos/{id}/time-entries/{entry}.
+func TimeEntryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodDelete {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/time-entries/{entry} endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+	entryID := r.PathValue("entry")
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to delete time entry.", "id", id, "entry_id", entryID)
+	if _, err := dispatch(todo.Command{Action: todo.OpDeleteTimeEntry, Ctx: r.Context(), ID: id, TimeEntry: todo.TimeEntry{ID: entryID}}); err != nil {
+		timeError(w, r, err)
+		return
+	}
+	w.Write([]byte(`{"status": "success","message":"Time entry deleted successfully."}`))
+}
+
+// TimeReportHandler serves GET /api/v1/reports/time, the time tracked per item, project or context over a date range.
+//
+// Query parameters:
+//   - from and to are dates (YYYY-MM-DD or DD-MM-YYYY, in UTC); both days are included. They default to the
+//     first day of the current month and today.
+//   - group_by=item|project|context picks the grouping, item by default.
+//   - user limits the report to one user's entries.
+//   - format=json|csv picks the output, JSON by default.
+func TimeReportHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received time report request.", "query", r.URL.RawQuery)
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/reports/time endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, err := parseReportDate(q.Get("from"), today.AddDate(0, 0, 1-today.Day()))
+	if err != nil {
+		http.Error(w, "Bad Request: from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseReportDate(q.Get("to"), today)
+	if err != nil {
+		http.Error(w, "Bad Request: to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "Bad Request: unsupported format "+strconv.Quote(format), http.StatusBadRequest)
+		return
+	}
+
+	result, err := dispatch(todo.Command{Action: todo.OpGet, Ctx: r.Context()})
+	if err != nil {
+		timeError(w, r, err)
+		return
+	}
+	// to is a whole day, so the range ends at the start of the next one.
+	report, err := todo.BuildTimeReport(result.([]todo.Item), from, to.AddDate(0, 0, 1), q.Get("group_by"), q.Get("user"), now)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="time-report.csv"`)
+		if err := todo.WriteTimeReportCSV(w, report); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelError, "Failed to write time report.", "error", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseReportDate parses a report date, accepting both ISO dates and the DD-MM-YYYY layout used for due dates.
+func parseReportDate(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(todo.DueLayout, s)
+	if err != nil {
+		return time.Time{}, errors.New("dates must be YYYY-MM-DD or DD-MM-YYYY")
+	}
+	return t, nil
+}
+
+// timeUser returns the user whose time a request is about: the logged-in user. A request naming someone else
+// gets 403. Without accounts, as in tests, the requested user is taken at their word.
+func timeUser(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	if u, ok := auth.UserFrom(r.Context()); ok {
+		if requested != "" && auth.NormalizeUsername(requested) != u.Username {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Time tracking request for another user.", "user", requested)
+			http.Error(w, "Forbidden: you can only track your own time", http.StatusForbidden)
+			return "", false
+		}
+		return u.Username, true
+	}
+	if requested == "" {
+		http.Error(w, "Bad Request: user is required", http.StatusBadRequest)
+		return "", false
+	}
+	return requested, true
+}
+
+func decodeTimeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTimeRequestSize)
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode time tracking request.", "error", err)
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func timeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, todo.ErrTimerRunning):
+		http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+	case errors.Is(err, todo.ErrNoTimer), errors.Is(err, todo.ErrNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, todo.ErrForbidden):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	default:
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
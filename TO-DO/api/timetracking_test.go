@@ -0,0 +1,117 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeTrackingHandlers(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Write invoice", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/timer", TimerStartHandler)
+	mux.HandleFunc("/api/v1/timer", TimerHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/time-entries", TimeEntriesHandler)
+	mux.HandleFunc("/api/v1/todos/{id}/time-entries/{entry}", TimeEntryHandler)
+	mux.HandleFunc("/api/v1/reports/time", TimeReportHandler)
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/api/v1/todos/1/timer", `{"user": "ana"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/api/v1/todos/1/timer", `{"user": "ana"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second timer, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/v1/timer?user=ana", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"item_id":1`) {
+		t.Errorf("Expected the running timer, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/api/v1/timer", `{"user": "ana"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"end"`) {
+		t.Errorf("Expected the stopped timer, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/api/v1/timer", `{"user": "ana"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with no timer running, got %d", rec.Code)
+	}
+
+	rec := serve(http.MethodPost, "/api/v1/todos/1/time-entries", `{"user": "ana", "start": "2026-03-02T09:00:00Z", "end": "2026-03-02T10:30:00Z", "note": "drafting"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var manual todo.ItemTimeEntry
+	json.NewDecoder(rec.Body).Decode(&manual)
+	if rec := serve(http.MethodPost, "/api/v1/todos/1/time-entries", `{"user": "ana", "start": "2026-03-02T09:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an entry without an end, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodGet, "/api/v1/reports/time?from=2026-03-01&to=2026-03-31", "")
+	var report todo.TimeReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || report.TotalSeconds != 5400 || len(report.Totals) != 1 || report.Totals[0].Name != "Write invoice" {
+		t.Errorf("Unexpected report %d: %+v", rec.Code, report)
+	}
+	rec = serve(http.MethodGet, "/api/v1/reports/time?from=01-03-2026&to=31-03-2026&format=csv", "")
+	if rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" || !strings.Contains(rec.Body.String(), "item,1,Write invoice,5400,1.50,1,false") {
+		t.Errorf("Unexpected CSV report:\n%s", rec.Body)
+	}
+	for _, url := range []string{"/api/v1/reports/time?from=tomorrow", "/api/v1/reports/time?group_by=colour", "/api/v1/reports/time?format=xml", "/api/v1/reports/time?from=2026-03-02&to=2026-03-01"} {
+		if rec := serve(http.MethodGet, url, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rec.Code)
+		}
+	}
+
+	if rec := serve(http.MethodDelete, "/api/v1/todos/1/time-entries/"+manual.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodDelete, "/api/v1/todos/1/time-entries/"+manual.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted entry, got %d", rec.Code)
+	}
+}
+
+func TestTimeTrackingHandlers_OwnTimeOnly(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Write invoice", Due: "01-01-2026"}})
+	dispatch(todo.Command{Action: todo.OpStartTimer, ID: 1, TimeEntry: todo.TimeEntry{ID: "t1", User: "ana", Start: time.Now().UTC()}})
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), auth.User{ID: "2", Username: "bob"}))
+		rec := httptest.NewRecorder()
+		TimerHandler(rec, req)
+		return rec
+	}
+	if rec := serve(http.MethodGet, "/api/v1/timer?user=ana", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bob looking at ana's timer, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/api/v1/timer", `{"user": "ana"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bob stopping ana's timer, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/api/v1/timer", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for bob, who has no timer running, got %d", rec.Code)
+	}
+
+	// Time is logged for the logged-in user, who can't log it for anyone else.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/todos/1/time-entries", strings.NewReader(`{"user": "ana", "start": "2026-03-02T09:00:00Z", "end": "2026-03-02T10:00:00Z"}`))
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+	TimeEntriesHandler(rec, req.WithContext(auth.WithUser(req.Context(), auth.User{ID: "2", Username: "bob"})))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bob logging ana's time, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/todos/1/timer", strings.NewReader(`{}`))
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+	TimerStartHandler(rec, req.WithContext(auth.WithUser(req.Context(), auth.User{ID: "2", Username: "bob"})))
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"user":"bob"`) {
+		t.Errorf("Expected a timer for bob, got %d: %s", rec.Code, rec.Body)
+	}
+}
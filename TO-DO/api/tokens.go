@@ -0,0 +1,105 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultTokenLifetimeDays is how long a token lasts when the request doesn't say.
+const DefaultTokenLifetimeDays = 90
+
+// CreateTokenRequest is the JSON body for creating an API token.
+type CreateTokenRequest struct {
+	Name          string       `json:"name"`
+	Scopes        []auth.Scope `json:"scopes"`
+	ExpiresInDays int          `json:"expires_in_days,omitempty"`
+}
+
+// CreateTokenResponse is the created token, along with the token itself. This is the only time it is shown.
+type CreateTokenResponse struct {
+	auth.Token
+	Secret string `json:"token"`
+}
+
+// TokensHandler serves /api/v1/auth/tokens, the logged-in user's API tokens.
+// GET lists them (without the tokens themselves); POST creates one from a CreateTokenRequest.
+func TokensHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		u, ok := auth.UserFrom(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized: log in first", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list API tokens.")
+			tokens := a.Tokens(u.ID)
+			if tokens == nil {
+				tokens = []auth.Token{}
+			}
+			json.NewEncoder(w).Encode(tokens)
+
+		case http.MethodPost:
+			slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to create API token.")
+			var req CreateTokenRequest
+			r.Body = http.MaxBytesReader(w, r.Body, 4096)
+			defer r.Body.Close()
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Failed to decode request body", "error", err)
+				http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if req.ExpiresInDays == 0 {
+				req.ExpiresInDays = DefaultTokenLifetimeDays
+			}
+			expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			t, secret, err := a.CreateToken(u, req.Name, req.Scopes, expiresAt, r.Context())
+			if err != nil {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "API token rejected.", "error", err)
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(CreateTokenResponse{Token: t.Public(), Secret: secret})
+
+		default:
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/auth/tokens endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// TokenHandler serves /api/v1/auth/tokens/{id}. DELETE revokes one of the logged-in user's tokens.
+func TokenHandler(a *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/auth/tokens/{id} endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use DELETE", http.StatusMethodNotAllowed)
+			return
+		}
+		u, ok := auth.UserFrom(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized: log in first", http.StatusUnauthorized)
+			return
+		}
+
+		id := r.PathValue("id")
+		if err := a.RevokeToken(u.ID, id, r.Context()); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to revoke API token.", "id", id, "error", err)
+			if errors.Is(err, auth.ErrTokenNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Write([]byte(`{"status": "success","message":"Token revoked successfully."}`))
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokensHandler(t *testing.T) {
+	a, handler := newAuthServer(t, auth.Config{})
+	ana, _ := a.Register("ana", "correct horse", context.Background())
+	session, _ := a.StartSession(ana)
+	cookie := &http.Cookie{Name: SessionCookie, Value: session.Token}
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, body := range []string{`{"name": "", "scopes": ["read"]}`, `{"name": "ci", "scopes": ["root"]}`, `{"name": "ci", "scopes": ["read"], "expires_in_days": 1000}`, `not json`} {
+		if rec := do(http.MethodPost, "/api/v1/auth/tokens", body, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+
+	rec := do(http.MethodPost, "/api/v1/auth/tokens", `{"name": "ci", "scopes": ["read"]}`, "")
+	var created CreateTokenResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Secret == "" || created.Hash != "" || created.ExpiresAt.IsZero() {
+		t.Fatalf("Expected a token, got %d %+v", rec.Code, created)
+	}
+
+	// A read token can read, but not write or manage tokens.
+	if rec := do(http.MethodGet, "/api/v1/auth/me", "", created.Secret); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ana"`) {
+		t.Errorf("Expected the token to authenticate, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(http.MethodPost, "/api/v1/auth/register", `{"username": "bob", "password": "long enough"}`, created.Secret)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Header().Get("WWW-Authenticate"), `scope="write"`) {
+		t.Errorf("Expected 403 for a write, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := do(http.MethodGet, "/api/v1/auth/tokens", "", created.Secret); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for listing tokens, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/auth/tokens", "", "")
+	var tokens []auth.Token
+	json.NewDecoder(rec.Body).Decode(&tokens)
+	if len(tokens) != 1 || tokens[0].ID != created.ID || tokens[0].LastUsedAt.IsZero() || strings.Contains(rec.Body.String(), created.Secret) {
+		t.Errorf("Expected the token without its secret, got %s", rec.Body)
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/auth/tokens/nope", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/v1/auth/tokens/"+created.ID, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the token to be revoked, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, "/api/v1/auth/me", "", created.Secret)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), "invalid_token") {
+		t.Errorf("Expected a revoked token to be rejected, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestAuthMiddleware_Admin(t *testing.T) {
+	a, _ := newAuthServer(t, auth.Config{})
+	handler := AuthMiddleware(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ctx := context.Background()
+	ana, _ := a.Register("ana", "correct horse", ctx)
+	bob, _ := a.Register("bob", "correct horse", ctx)
+	_, bobToken, _ := a.CreateToken(bob, "ci", []auth.Scope{auth.ScopeAdmin}, time.Now().Add(time.Hour), ctx)
+
+	do := func(path string, u auth.User, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			session, _ := a.StartSession(u)
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Token})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Only administrators may use the administrative routes, with a session or with an admin token.
+	for _, path := range []string{"/api/v1/admin/backup", "/api/v1/webhooks", "/debug/vars"} {
+		if code := do(path, ana, ""); code != http.StatusOK {
+			t.Errorf("%s: expected the administrator to get through, got %d", path, code)
+		}
+		if code := do(path, bob, ""); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for another user's session, got %d", path, code)
+		}
+		if code := do(path, bob, bobToken); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for another user's admin token, got %d", path, code)
+		}
+	}
+	// Everyone manages their own tokens.
+	if code := do("/api/v1/auth/tokens", bob, bobToken); code != http.StatusOK {
+		t.Errorf("Expected bob to list their own tokens, got %d", code)
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		scope        auth.Scope
+	}{
+		{http.MethodGet, "/get", auth.ScopeRead},
+		{http.MethodHead, "/list", auth.ScopeRead},
+		{http.MethodPost, "/create", auth.ScopeWrite},
+		{http.MethodDelete, "/delete", auth.ScopeWrite},
+		{http.MethodGet, "/api/v1/ws", auth.ScopeWrite},
+		{http.MethodGet, "/api/v1/admin/backup", auth.ScopeAdmin},
+		{http.MethodGet, "/api/v1/webhooks/deliveries", auth.ScopeAdmin},
+		{http.MethodGet, "/api/v1/auth/tokens", auth.ScopeAdmin},
+		{http.MethodGet, "/debug/vars", auth.ScopeAdmin},
+	} {
+		if got := requiredScope(httptest.NewRequest(tc.method, tc.path, nil)); got != tc.scope {
+			t.Errorf("%s %s: expected %s, got %s", tc.method, tc.path, tc.scope, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"GoAcademy/TO-DO/webhook"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// WebhooksHandler serves /api/v1/webhooks.
+// GET lists the subscriptions (without their secrets); POST creates a subscription from a JSON body
+// of the form {"url": "...", "events": ["created", "completed"], "secret": "..."}.
+// Both only deal with the logged-in user's subscriptions in the request's tenant, and a subscription is only sent
+// the events its owner may see.
+func WebhooksHandler(d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+			slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list webhooks.")
+			subs := slices.DeleteFunc(d.Subscriptions(), func(s webhook.Subscription) bool { return !ownSubscription(r, s) })
+			for i := range subs {
+				subs[i].Secret = "" // Secrets are write-only.
+			}
+			json.NewEncoder(w).Encode(subs)
+
+		case http.MethodPost:
+			slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to create webhook.")
+			var s webhook.Subscription
+			if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelError, "Failed to decode request body", "error", err)
+				http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+				return
+			}
+			defer r.Body.Close()
+
+			s.Tenant = todo.InstanceFrom(r.Context()).Tenant
+			u, _ := auth.UserFrom(r.Context())
+			s.Owner = u.ID
+			created, err := d.Add(s, r.Context())
+			if err != nil {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Webhook subscription rejected.", "error", err)
+				http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Default().Log(r.Context(), slog.LevelInfo, "Webhook created.", "id", created.ID, "url", created.URL)
+			created.Secret = ""
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+
+		default:
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/webhooks endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use GET or POST", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// WebhookHandler serves /api/v1/webhooks/{id}. DELETE removes the subscription.
+func WebhookHandler(d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/webhooks/{id} endpoint.", "method", r.Method)
+			http.Error(w, "Method Not Allowed. Use DELETE", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.PathValue("id")
+		// Other users' and other tenants' subscriptions don't exist as far as this user is concerned.
+		if !slices.ContainsFunc(d.Subscriptions(), func(s webhook.Subscription) bool { return s.ID == id && ownSubscription(r, s) }) {
+			http.Error(w, "webhook with id "+id+" not found", http.StatusNotFound)
+			return
+		}
+		if err := d.Remove(id, r.Context()); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to delete webhook.", "id", id, "error", err)
+			if errors.Is(err, webhook.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Webhook deleted.", "id", id)
+		w.Write([]byte(`{"status": "success","message":"Webhook deleted successfully."}`))
+	}
+}
+
+// WebhookDeliveriesHandler serves GET /api/v1/webhooks/deliveries, the log of recent deliveries to the user's webhooks.
+func WebhookDeliveriesHandler(d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeDeliveries(w, r, d.Deliveries())
+	}
+}
+
+// WebhookDeadLettersHandler serves GET /api/v1/webhooks/dead-letters, the deliveries to the user's webhooks that
+// failed every attempt.
+func WebhookDeadLettersHandler(d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeDeliveries(w, r, d.DeadLetters())
+	}
+}
+
+func writeDeliveries(w http.ResponseWriter, r *http.Request, deliveries []webhook.Delivery) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for webhook delivery endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant := todo.InstanceFrom(r.Context()).Tenant
+	u, _ := auth.UserFrom(r.Context())
+	deliveries = slices.DeleteFunc(deliveries, func(d webhook.Delivery) bool { return d.Tenant != tenant || d.Owner != u.ID })
+	if deliveries == nil {
+		deliveries = []webhook.Delivery{}
+	}
+	json.NewEncoder(w).Encode(deliveries)
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Sent webhook deliveries to client.", "count", len(deliveries))
+}
+
+// ownSubscription reports whether s belongs to the logged-in user and the request's tenant.
+func ownSubscription(r *http.Request, s webhook.Subscription) bool {
+	u, _ := auth.UserFrom(r.Context())
+	return s.Tenant == todo.InstanceFrom(r.Context()).Tenant && s.Owner == u.ID
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/webhook"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhooksHandler_CreateListDelete(t *testing.T) {
+	d, err := webhook.NewDispatcher(webhook.Config{}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/webhooks", WebhooksHandler(d))
+	mux.HandleFunc("/api/v1/webhooks/{id}", WebhookHandler(d))
+
+	// Create
+	body := `{"url": "https://example.com/hook", "events": ["completed"], "secret": "s3cret"}`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID == "" || created.Secret != "" {
+		t.Errorf("Expected an ID and no secret in the response, got %+v", created)
+	}
+
+	// Invalid subscriptions are rejected.
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"url": "not a url"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid url, got %d", rec.Code)
+	}
+
+	// List hides secrets.
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/webhooks", nil))
+	var subs []webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&subs)
+	if len(subs) != 1 || subs[0].Secret != "" {
+		t.Errorf("Expected one subscription without its secret, got %+v", subs)
+	}
+
+	// Delete, then deleting again is a 404.
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/webhooks/"+created.ID, nil))
+		if rec.Code != want {
+			t.Errorf("Expected %d deleting webhook, got %d", want, rec.Code)
+		}
+	}
+}
+
+func TestWebhooksHandler_OwnSubscriptionsOnly(t *testing.T) {
+	d, err := webhook.NewDispatcher(webhook.Config{}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/webhooks", WebhooksHandler(d))
+	mux.HandleFunc("/api/v1/webhooks/{id}", WebhookHandler(d))
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), auth.User{ID: user, Username: user}))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var created webhook.Subscription
+	json.NewDecoder(do("ana", http.MethodPost, "/api/v1/webhooks", `{"url": "https://example.com/hook"}`).Body).Decode(&created)
+	if created.Owner != "ana" {
+		t.Fatalf("Expected ana to own the webhook, got %+v", created)
+	}
+
+	var subs []webhook.Subscription
+	json.NewDecoder(do("bob", http.MethodGet, "/api/v1/webhooks", "").Body).Decode(&subs)
+	if len(subs) != 0 {
+		t.Errorf("Expected bob to see no webhooks, got %+v", subs)
+	}
+	if rec := do("bob", http.MethodDelete, "/api/v1/webhooks/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for bob deleting ana's webhook, got %d", rec.Code)
+	}
+	if rec := do("ana", http.MethodDelete, "/api/v1/webhooks/"+created.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected ana to delete her webhook, got %d", rec.Code)
+	}
+}
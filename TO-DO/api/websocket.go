@@ -0,0 +1,197 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second    // Time allowed to write a single message to the client.
+	wsPongWait   = 60 * time.Second    // Time allowed between pongs before the connection is considered dead.
+	wsPingPeriod = wsPongWait * 9 / 10 // Pings must be sent more often than pongs are expected.
+	wsEventQueue = 64                  // Buffer of actor events waiting to be pushed to a single client.
+)
+
+// upgrader turns an HTTP request into a WebSocket connection.
+// The default CheckOrigin rejects cross-origin browser requests, which is what we want for a state-changing endpoint.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// WSRequest is a command sent by a client over the WebSocket.
+// ID is chosen by the client and echoed back on the matching response so that several requests can be in flight at once.
+type WSRequest struct {
+	ID     string     `json:"id"`
+	Op     string     `json:"op"` // one of "add", "update", "delete", "get"
+	ItemID int        `json:"item_id,omitempty"`
+	Item   *todo.Item `json:"item,omitempty"`
+	Update *WSUpdate  `json:"update,omitempty"`
+}
+
+// WSUpdate holds the fields of an "update" request; as with /update, nil fields are left unchanged.
+type WSUpdate struct {
+	Name      *string  `json:"name,omitempty"`
+	Due       *string  `json:"due,omitempty"`
+	Completed *bool    `json:"completed,omitempty"`
+	Priority  *int     `json:"priority,omitempty"`
+	Notes     *string  `json:"notes,omitempty"`
+	Estimate  *float64 `json:"estimate,omitempty"`
+	// Fields sets custom field values by name; "" clears one.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// WSMessage is anything the server sends to a client: a response to a WSRequest ("result" or "error"),
+// or a change notification pushed from the actor ("event").
+type WSMessage struct {
+	Type   string      `json:"type"`
+	ID     string      `json:"id,omitempty"`
+	Result any         `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Event  *todo.Event `json:"event,omitempty"`
+}
+
+// WebSocketHandler upgrades the connection and then serves two streams over it:
+// commands from the client are forwarded to the actor and answered with correlated responses,
+// and every event published by the actor is pushed to the client as it happens.
+func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received WebSocket connection request.")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response to the client.
+		slog.Default().Log(r.Context(), slog.LevelWarn, "WebSocket upgrade failed.", "error", err)
+		return
+	}
+	defer conn.Close()
+	slog.Default().Log(r.Context(), slog.LevelInfo, "WebSocket connection established.", "remote", r.RemoteAddr)
+
+	// Subscribe before reading any commands so the client sees the events caused by its own commands.
+	events, unsubscribe := todo.Events.Subscribe(wsEventQueue)
+	defer unsubscribe()
+	// Only events about items the user may see, from the request's tenant, are pushed to them.
+	user, hasUser := auth.UserFrom(r.Context())
+	tenant := todo.InstanceFrom(r.Context()).Tenant
+
+	// A websocket.Conn supports one concurrent writer, so every outgoing message goes through this channel
+	// and is written by the writer goroutine below.
+	out := make(chan WSMessage, wsEventQueue)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			var msg WSMessage
+			select {
+			case msg = <-out:
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Tenant != tenant || hasUser && !e.VisibleTo(user.ID) {
+					continue
+				}
+				msg = WSMessage{Type: "event", Event: &e}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+				continue
+			case <-done:
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to write WebSocket message.", "error", err)
+				conn.Close() // Unblocks the reader loop below.
+				return
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	// Reader loop: one request at a time is sent to the actor, which processes commands in order anyway.
+	for {
+		var req WSRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.Default().Log(r.Context(), slog.LevelWarn, "WebSocket read failed.", "error", err)
+			} else {
+				slog.Default().Log(r.Context(), slog.LevelInfo, "WebSocket connection closed.")
+			}
+			return
+		}
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received WebSocket request.", "id", req.ID, "op", req.Op)
+
+		result, err := handleWSRequest(r, req)
+		msg := WSMessage{Type: "result", ID: req.ID, Result: result}
+		if err != nil {
+			msg = WSMessage{Type: "error", ID: req.ID, Error: err.Error()}
+		}
+
+		select {
+		case out <- msg:
+		case <-time.After(wsWriteWait):
+			slog.Default().Log(r.Context(), slog.LevelWarn, "WebSocket client is not draining responses, closing.")
+			return
+		}
+	}
+}
+
+// handleWSRequest maps a WSRequest onto a todo.Command, validating it the same way the HTTP endpoints do.
+func handleWSRequest(r *http.Request, req WSRequest) (any, error) {
+	cmd := todo.Command{Ctx: r.Context(), ID: req.ItemID}
+
+	switch req.Op {
+	case "get":
+		cmd.Action = todo.OpGet
+	case "add":
+		if req.Item == nil {
+			return nil, errors.New("add requires an item")
+		}
+		if err := todo.ValidateItem(*req.Item); err != nil {
+			return nil, err
+		}
+		cmd.Action = todo.OpAdd
+		cmd.Item = *req.Item
+	case "update":
+		if req.Update == nil {
+			return nil, errors.New("update requires an update payload")
+		}
+		if req.Update.Due != nil {
+			if _, err := time.Parse(todo.DueLayout, *req.Update.Due); err != nil {
+				return nil, errors.New("due date must be in DD-MM-YYYY format")
+			}
+		}
+		cmd.Action = todo.OpUpdate
+		cmd.UpdatePayload = todo.ItemUpdate{
+			Name:      req.Update.Name,
+			Due:       req.Update.Due,
+			Completed: req.Update.Completed,
+			Priority:  req.Update.Priority,
+			Notes:     req.Update.Notes,
+			Estimate:  req.Update.Estimate,
+			Fields:    req.Update.Fields,
+		}
+	case "delete":
+		cmd.Action = todo.OpDelete
+	default:
+		return nil, fmt.Errorf("unknown op %q", req.Op)
+	}
+
+	return dispatch(cmd)
+}
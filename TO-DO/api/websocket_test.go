@@ -0,0 +1,149 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startTestStore starts a fresh actor backed by a file in a temporary directory.
+func startTestStore(t *testing.T) {
+	t.Helper()
+	todo.Store = make(chan todo.Command)
+	todo.StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(todo.Store) })
+}
+
+// dialWS starts a test server running WebSocketHandler and connects a client to it.
+func dialWS(t *testing.T) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(WebSocketHandler))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil reads messages until match returns true, failing the test on timeout.
+func readUntil(t *testing.T, conn *websocket.Conn, match func(WSMessage) bool) WSMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read WebSocket message: %v", err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+func TestWebSocket_AddReturnsCorrelatedResultAndEvent(t *testing.T) {
+	startTestStore(t)
+	conn := dialWS(t)
+
+	req := WSRequest{ID: "req-1", Op: "add", Item: &todo.Item{Name: "Socket task", Due: "01-01-2026"}}
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	// The response and the pushed event may arrive in either order.
+	var gotResult, gotEvent bool
+	readUntil(t, conn, func(msg WSMessage) bool {
+		switch msg.Type {
+		case "result":
+			if msg.ID != "req-1" {
+				t.Errorf("Expected result for req-1, got %q", msg.ID)
+			}
+			gotResult = true
+		case "event":
+			if msg.Event.Type != todo.EventCreated || msg.Event.Item.Name != "Socket task" {
+				t.Errorf("Unexpected event: %+v", msg.Event)
+			}
+			gotEvent = true
+		default:
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+		return gotResult && gotEvent
+	})
+}
+
+func TestWebSocket_GetUpdateDelete(t *testing.T) {
+	startTestStore(t)
+	conn := dialWS(t)
+
+	isResponse := func(id string) func(WSMessage) bool {
+		return func(msg WSMessage) bool { return msg.ID == id }
+	}
+
+	conn.WriteJSON(WSRequest{ID: "a", Op: "add", Item: &todo.Item{Name: "First", Due: "01-01-2026"}})
+	readUntil(t, conn, isResponse("a"))
+
+	completed := true
+	conn.WriteJSON(WSRequest{ID: "u", Op: "update", ItemID: 1, Update: &WSUpdate{Completed: &completed}})
+	if msg := readUntil(t, conn, isResponse("u")); msg.Type != "result" {
+		t.Fatalf("Expected update to succeed, got %+v", msg)
+	}
+
+	conn.WriteJSON(WSRequest{ID: "g", Op: "get"})
+	msg := readUntil(t, conn, isResponse("g"))
+	items, ok := msg.Result.([]any)
+	if !ok || len(items) != 1 {
+		t.Fatalf("Expected 1 item from get, got %+v", msg.Result)
+	}
+	if item := items[0].(map[string]any); item["Completed"] != true {
+		t.Errorf("Expected item to be completed, got %+v", item)
+	}
+
+	conn.WriteJSON(WSRequest{ID: "d", Op: "delete", ItemID: 1})
+	if msg := readUntil(t, conn, isResponse("d")); msg.Type != "result" {
+		t.Fatalf("Expected delete to succeed, got %+v", msg)
+	}
+}
+
+func TestWebSocket_Errors(t *testing.T) {
+	startTestStore(t)
+	conn := dialWS(t)
+
+	tests := []WSRequest{
+		{ID: "unknown", Op: "explode"},
+		{ID: "invalid", Op: "add", Item: &todo.Item{Name: "", Due: "01-01-2026"}},
+		{ID: "missing", Op: "delete", ItemID: 42},
+	}
+	for _, req := range tests {
+		conn.WriteJSON(req)
+		msg := readUntil(t, conn, func(msg WSMessage) bool { return msg.ID == req.ID })
+		if msg.Type != "error" || msg.Error == "" {
+			t.Errorf("Expected an error response for %q, got %+v", req.ID, msg)
+		}
+	}
+}
+
+func TestWebSocket_PushesEventsFromOtherClients(t *testing.T) {
+	startTestStore(t)
+	listener := dialWS(t)
+	writer := dialWS(t)
+
+	// Make sure the listener's subscription is in place before the writer changes anything.
+	listener.WriteJSON(WSRequest{ID: "ready", Op: "get"})
+	readUntil(t, listener, func(msg WSMessage) bool { return msg.ID == "ready" })
+
+	writer.WriteJSON(WSRequest{ID: "a", Op: "add", Item: &todo.Item{Name: "From elsewhere", Due: "01-01-2026"}})
+
+	msg := readUntil(t, listener, func(msg WSMessage) bool { return msg.Type == "event" })
+	if msg.Event.Item.Name != "From elsewhere" {
+		t.Errorf("Expected event for the other client's item, got %+v", msg.Event)
+	}
+}
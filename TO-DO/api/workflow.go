@@ -0,0 +1,238 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// boardTmpl is parsed on first use, like listTmpl.
+var boardTmpl = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFiles("web/templates/board.html"))
+})
+
+// TransitionRequest is the JSON body for moving an item to another status.
+type TransitionRequest struct {
+	Status string `json:"status"`
+}
+
+// WorkflowsResponse is the body of GET /api/v1/workflows: the default workflow and those of the lists that have their own.
+type WorkflowsResponse struct {
+	Default todo.Workflow            `json:"default"`
+	Lists   map[string]todo.Workflow `json:"lists"`
+}
+
+// boardCard is an item on the board, with the statuses it may be moved to.
+type boardCard struct {
+	todo.Item
+	Targets []string
+}
+
+// boardColumn is one status of the workflow and the items in it.
+type boardColumn struct {
+	Status string
+	Done   bool
+	Cards  []boardCard
+}
+
+// boardPage is the data the board template is rendered with.
+type boardPage struct {
+	List      string   // the list shown; "" is the items without a project
+	Lists     []string // every list with items or a workflow, for the picker
+	Custom    bool     // whether the list has its own workflow rather than the default
+	Columns   []boardColumn
+	CSRFToken string // see csrfToken
+}
+
+// TransitionHandler serves POST /api/v1/todos/{id}/transition, which moves an item to another status of its
+// list's workflow. The body is {"status": "..."}; the response is the moved item.
+func TransitionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid id", http.StatusBadRequest)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to change status.", "id", id)
+	if r.Method != http.MethodPost {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/todos/{id}/transition endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1024)
+	defer r.Body.Close()
+	var req TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode transition request.", "error", err)
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		return
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpTransition, Ctx: r.Context(), ID: id, Status: strings.TrimSpace(req.Status)})
+	if err != nil {
+		workflowError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// WorkflowsHandler serves GET /api/v1/workflows.
+func WorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to list workflows.")
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/workflows endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lists := result.(todo.Snapshot).Workflows
+	if lists == nil {
+		lists = map[string]todo.Workflow{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WorkflowsResponse{Default: todo.DefaultWorkflow, Lists: lists})
+}
+
+// WorkflowHandler serves /api/v1/workflows/{list}, where the list is a project name.
+// GET returns the list's workflow (the default one if it has none of its own); PUT replaces it; DELETE goes back
+// to the default. PUT and DELETE fail with 409 Conflict while items of the list are in a status the new workflow
+// doesn't have.
+func WorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	list := r.PathValue("list")
+
+	var cmd todo.Command
+	switch r.Method {
+	case http.MethodGet:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for workflow.", "list", list)
+		result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+		if err != nil {
+			workflowError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(todo.WorkflowFor(result.(todo.Snapshot).Workflows, todo.Item{Projects: []string{list}}))
+		return
+
+	case http.MethodPut:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to set workflow.", "list", list)
+		r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+		defer r.Body.Close()
+		var wf todo.Workflow
+		if err := json.NewDecoder(r.Body).Decode(&wf); err != nil {
+			slog.Default().Log(r.Context(), slog.LevelWarn, "Failed to decode workflow.", "error", err)
+			http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := wf.Validate(); err != nil {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cmd = todo.Command{Action: todo.OpSetWorkflow, Ctx: r.Context(), List: list, Workflow: &wf}
+
+	case http.MethodDelete:
+		slog.Default().Log(r.Context(), slog.LevelInfo, "Received request to remove workflow.", "list", list)
+		cmd = todo.Command{Action: todo.OpSetWorkflow, Ctx: r.Context(), List: list}
+
+	default:
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /api/v1/workflows/{list} endpoint.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET, PUT or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := dispatch(cmd)
+	if err != nil {
+		workflowError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func workflowError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, todo.ErrNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, todo.ErrUnknownStatus):
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, todo.ErrForbidden):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	case errors.Is(err, todo.ErrTransitionNotAllowed), errors.Is(err, todo.ErrWorkflowInUse):
+		http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+	default:
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// BoardPageHandler serves GET /board?list=<project>, a kanban board of the list's items with a column per status.
+// Cards are moved by dragging them to another column or with their buttons; either way the page calls
+// TransitionHandler.
+func BoardPageHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Received request for board page.", "query", r.URL.RawQuery)
+	if r.Method != http.MethodGet {
+		slog.Default().Log(r.Context(), slog.LevelWarn, "Invalid HTTP method for /board page.", "method", r.Method)
+		http.Error(w, "Method Not Allowed. Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := dispatch(todo.Command{Action: todo.OpSnapshot, Ctx: r.Context()})
+	if err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Actor returned an error.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	snap := result.(todo.Snapshot)
+	page := buildBoard(snap.Items, snap.Workflows, strings.TrimSpace(r.URL.Query().Get("list")))
+	page.CSRFToken = csrfToken(r)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := boardTmpl().Execute(w, page); err != nil {
+		slog.Default().Log(r.Context(), slog.LevelError, "Failed to render board template.", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	slog.Default().Log(r.Context(), slog.LevelInfo, "Board page successfully rendered and sent to client.")
+}
+
+// buildBoard groups the items of a list by status, in the order of the list's workflow.
+func buildBoard(items []todo.Item, workflows map[string]todo.Workflow, list string) boardPage {
+	wf := todo.WorkflowFor(workflows, todo.Item{Projects: []string{list}})
+	_, custom := workflows[list]
+	page := boardPage{List: list, Custom: custom && list != ""}
+
+	columns := make(map[string]int, len(wf.States))
+	for i, s := range wf.States {
+		page.Columns = append(page.Columns, boardColumn{Status: s.Name, Done: s.Done})
+		columns[s.Name] = i
+	}
+	lists := map[string]bool{}
+	for name := range workflows {
+		lists[name] = true
+	}
+	for _, item := range items {
+		lists[todo.ListOf(item)] = true
+		if todo.ListOf(item) != list {
+			continue
+		}
+		// The actor keeps every status within its list's workflow, so the lookup only guards against a broken file.
+		if i, ok := columns[item.Status]; ok {
+			page.Columns[i].Cards = append(page.Columns[i].Cards, boardCard{Item: item, Targets: wf.Targets(item.Status)})
+		}
+	}
+	for name := range lists {
+		if name != "" {
+			page.Lists = append(page.Lists, name)
+		}
+	}
+	slices.Sort(page.Lists)
+	return page
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWorkflowHandlers(t *testing.T) {
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Write report", Due: "01-01-2026", Projects: []string{"Work"}}})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Water plants", Due: "01-01-2026"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/todos/{id}/transition", TransitionHandler)
+	mux.HandleFunc("/api/v1/workflows", WorkflowsHandler)
+	mux.HandleFunc("/api/v1/workflows/{list}", WorkflowHandler)
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/v1/todos/1/transition", `{"status": "in_progress"}`)
+	var item todo.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	if rec.Code != http.StatusOK || item.Status != "in_progress" || item.Completed {
+		t.Errorf("Expected the item in progress, got %d %+v", rec.Code, item)
+	}
+
+	// Work gets a workflow of its own; the item is in_progress, which the first attempt doesn't have.
+	review := `{"states": [{"name": "todo"}, {"name": "doing"}, {"name": "shipped", "done": true}]}`
+	if rec = serve(http.MethodPut, "/api/v1/workflows/Work", review); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a workflow that strands an item, got %d: %s", rec.Code, rec.Body)
+	}
+	withProgress := `{"states": [{"name": "todo"}, {"name": "in_progress"}, {"name": "shipped", "done": true}],
+		"transitions": {"todo": ["in_progress"], "in_progress": ["todo", "shipped"], "shipped": ["todo"]}}`
+	if rec = serve(http.MethodPut, "/api/v1/workflows/Work", withProgress); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodGet, "/api/v1/workflows", "")
+	var all WorkflowsResponse
+	json.NewDecoder(rec.Body).Decode(&all)
+	if len(all.Lists) != 1 || len(all.Lists["Work"].States) != 3 || all.Default.Initial() != "todo" {
+		t.Errorf("Unexpected workflows %+v", all)
+	}
+
+	rec = serve(http.MethodPost, "/api/v1/todos/1/transition", `{"status": "shipped"}`)
+	json.NewDecoder(rec.Body).Decode(&item)
+	if rec.Code != http.StatusOK || !item.Completed || item.CompletedAt.IsZero() {
+		t.Errorf("Expected the item to be completed by a done state, got %d %+v", rec.Code, item)
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		code              int
+	}{
+		{http.MethodPost, "/api/v1/todos/1/transition", `{"status": "in_progress"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/todos/1/transition", `{"status": "in_review"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos/2/transition", `{"status": "shipped"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos/9/transition", `{"status": "done"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/todos/2/transition", `not json`, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/todos/2/transition", "", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/api/v1/workflows/Home", `{"states": [{"name": "done", "done": true}, {"name": "todo"}]}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/workflows/Work", "", http.StatusConflict},
+		{http.MethodPost, "/api/v1/workflows", "", http.StatusMethodNotAllowed},
+	} {
+		if rec := serve(tc.method, tc.url, tc.body); rec.Code != tc.code {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tc.method, tc.url, tc.body, tc.code, rec.Code, rec.Body)
+		}
+	}
+
+	// Once nothing is in a status only Work has, it can go back to the default workflow.
+	serve(http.MethodPost, "/api/v1/todos/1/transition", `{"status": "todo"}`)
+	if rec = serve(http.MethodDelete, "/api/v1/workflows/Work", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateHandler_UnknownStatus(t *testing.T) {
+	startTestStore(t)
+	rec := httptest.NewRecorder()
+	CreateHandler(rec, httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"Name": "Plan", "Due": "01-01-2026", "Status": "someday"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBoardPageHandler(t *testing.T) {
+	t.Chdir("..") // templates are loaded relative to the project root
+	startTestStore(t)
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Draft <slides>", Due: "01-01-2026", Projects: []string{"Talk"}}})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Rehearse", Due: "01-01-2026", Projects: []string{"Talk"}, Status: "in_review"}})
+	dispatch(todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Elsewhere", Due: "01-01-2026"}})
+
+	rec := httptest.NewRecorder()
+	BoardPageHandler(rec, httptest.NewRequest(http.MethodGet, "/board?list=Talk", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, body)
+	}
+	if !strings.Contains(body, "Draft &lt;slides&gt;") || strings.Contains(body, "Elsewhere") {
+		t.Errorf("Expected only the list's items, escaped, got:\n%s", body)
+	}
+	// Columns follow the workflow and cards carry the moves they're allowed.
+	if strings.Index(body, `data-status="todo"`) > strings.Index(body, `data-status="done"`) {
+		t.Errorf("Expected the columns in workflow order")
+	}
+	if !strings.Contains(body, `data-id="2" data-targets="in_progress done"`) {
+		t.Errorf("Expected the in_review card to list its targets, got:\n%s", body)
+	}
+}
+
+func TestBuildBoard(t *testing.T) {
+	items := []todo.Item{
+		{ID: 1, Name: "a", Projects: []string{"Home"}, Status: "todo"},
+		{ID: 2, Name: "b", Projects: []string{"Home", "Work"}, Status: "later"},
+		{ID: 3, Name: "c", Status: "todo"},
+	}
+	workflows := map[string]todo.Workflow{
+		"Home": {States: []todo.WorkflowState{{Name: "todo"}, {Name: "later"}, {Name: "done", Done: true}}},
+		"Shop": todo.DefaultWorkflow,
+	}
+	page := buildBoard(items, workflows, "Home")
+	if !page.Custom || len(page.Columns) != 3 || len(page.Columns[0].Cards) != 1 || len(page.Columns[1].Cards) != 1 {
+		t.Errorf("Unexpected board %+v", page)
+	}
+	// Without transitions any other state is a target.
+	if got := page.Columns[1].Cards[0].Targets; len(got) != 2 || got[0] != "todo" || got[1] != "done" {
+		t.Errorf("Unexpected targets %v", got)
+	}
+	if strings.Join(page.Lists, ",") != "Home,Shop" {
+		t.Errorf("Expected every list with items or a workflow, got %v", page.Lists)
+	}
+	if page = buildBoard(items, workflows, ""); page.Custom || len(page.Columns[0].Cards) != 1 {
+		t.Errorf("Expected the items without a project on the default workflow, got %+v", page)
+	}
+}
@@ -0,0 +1,365 @@
+// Package auth manages user accounts, their login sessions and their API tokens.
+//
+// Accounts are kept in a JSON file, with passwords hashed using bcrypt. Sessions live in memory only, so
+// restarting the server logs everyone out. A session is identified by a random token that the web layer
+// keeps in an HTTP-only cookie; it expires a fixed time after login, however active the user is.
+// API tokens (see tokens.go) are for scripts, and are kept hashed in a second file. Users who log in through
+// single sign-on get an account without a password the first time they do (see sso.go).
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var Filename string = "users.json"
+
+var TokenFilename string = "tokens.json"
+
+// Password limits. bcrypt only looks at the first 72 bytes, so longer passwords are refused rather than truncated.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var (
+	// ErrUserExists is returned when registering a username that is already taken.
+	ErrUserExists = errors.New("username is already taken")
+	// ErrInvalidCredentials is returned for an unknown username or a wrong password; which one is not revealed.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,31}$`)
+
+// User is a registered account.
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash,omitempty"` // bcrypt; never sent to clients
+	Issuer       string    `json:"issuer,omitempty"`        // the single sign-on provider the user logs in with, if any (see sso.go)
+	Subject      string    `json:"subject,omitempty"`       // the user's ID at that provider
+	CreatedAt    time.Time `json:"created_at"`
+	// Admin is set on the users Accounts returns, from Config.Admins; it is never stored.
+	Admin bool `json:"admin,omitempty"`
+}
+
+// Public returns the user without its password hash, for responses and templates.
+func (u User) Public() User {
+	u.PasswordHash = ""
+	return u
+}
+
+// Session is a logged-in user's session.
+type Session struct {
+	Token     string    `json:"-"` // only ever handed to the client that logged in
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NormalizeUsername trims and lower-cases a username, so that logins are case-insensitive.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateCredentials checks a new account's (normalized) username and its password.
+func ValidateCredentials(username, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("username must be 3 to 32 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must be between %d and %d bytes", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// Config controls the accounts. Zero values are replaced by the defaults below.
+type Config struct {
+	Filename      string        // where accounts are persisted; empty keeps them in memory only
+	TokenFilename string        // where API tokens are persisted; empty keeps them in memory only
+	SessionTTL    time.Duration // how long a session lasts after login
+	BcryptCost    int           // work factor for new password hashes
+	// OpenRegistration lets anyone create an account. Otherwise only the first account can be created without
+	// logging in, and further ones are created by an administrator.
+	OpenRegistration bool
+	// Admins are the usernames of the administrators. Without any, the first account is the administrator.
+	Admins []string
+}
+
+func (c Config) withDefaults() Config {
+	if c.SessionTTL <= 0 {
+		c.SessionTTL = 24 * time.Hour
+	}
+	if c.BcryptCost == 0 {
+		c.BcryptCost = bcrypt.DefaultCost
+	}
+	for i, name := range c.Admins {
+		c.Admins[i] = NormalizeUsername(name)
+	}
+	return c
+}
+
+// Accounts owns the registered users and the active sessions.
+type Accounts struct {
+	cfg Config
+	now func() time.Time // replaced in tests
+
+	mu       sync.Mutex
+	users    []User
+	sessions map[string]Session // by the SHA-256 of the token, so a leaked memory dump doesn't leak usable tokens
+	tokens   []Token
+
+	dummyHash []byte // compared against for unknown usernames, so that they take as long as wrong passwords
+}
+
+// NewAccounts creates the accounts and loads any persisted users.
+func NewAccounts(cfg Config, ctx context.Context) (*Accounts, error) {
+	cfg = cfg.withDefaults()
+	dummy, err := bcrypt.GenerateFromPassword([]byte("not a real password"), cfg.BcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bcrypt cost: %w", err)
+	}
+	a := &Accounts{cfg: cfg, now: time.Now, sessions: map[string]Session{}, dummyHash: dummy}
+	if err := load(cfg.Filename, &a.users, "users", ctx); err != nil {
+		return nil, err
+	}
+	if err := load(cfg.TokenFilename, &a.tokens, "tokens", ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// load reads a JSON file into v. A missing file (or an empty filename) leaves v empty.
+func load(filename string, v any, what string, ctx context.Context) error {
+	if filename == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			slog.Default().Log(ctx, slog.LevelInfo, "File not found, starting with no "+what+".", "file", filename)
+			return nil
+		}
+		return fmt.Errorf("could not read file %s: %w", filename, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("could not unmarshal %s: %w", what, err)
+		}
+	}
+	return nil
+}
+
+// RegistrationOpen reports whether anyone may create an account.
+func (a *Accounts) RegistrationOpen() bool {
+	return a.cfg.OpenRegistration
+}
+
+// Count returns the number of registered users.
+func (a *Accounts) Count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.users)
+}
+
+// Register creates an account. The username is normalized first.
+func (a *Accounts) Register(username, password string, ctx context.Context) (User, error) {
+	username = NormalizeUsername(username)
+	if err := ValidateCredentials(username, password); err != nil {
+		return User{}, err
+	}
+	// Hashing is slow on purpose, so it is done before taking the lock.
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.cfg.BcryptCost)
+	if err != nil {
+		return User{}, err
+	}
+	u := User{ID: uuid.New().String(), Username: username, PasswordHash: string(hash), CreatedAt: a.now().UTC()}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.find(username); ok {
+		return User{}, ErrUserExists
+	}
+	a.users = append(a.users, u)
+	if err := a.save(ctx); err != nil {
+		a.users = a.users[:len(a.users)-1]
+		return User{}, err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "User registered.", "user_id", u.ID, "username", u.Username)
+	return a.withRole(u), nil
+}
+
+// Authenticate checks a username and password and returns the user they belong to.
+func (a *Accounts) Authenticate(username, password string) (User, error) {
+	a.mu.Lock()
+	u, ok := a.find(NormalizeUsername(username))
+	a.mu.Unlock()
+	if !ok {
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+// User returns the user with the given ID.
+func (a *Accounts) User(id string) (User, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.userByID(id)
+}
+
+// Lookup returns the user with the given username, which needn't be normalized.
+func (a *Accounts) Lookup(username string) (User, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.find(NormalizeUsername(username))
+}
+
+// StartSession logs a user in, returning a new session with a fresh random token.
+func (a *Accounts) StartSession(u User) (Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Session{}, err
+	}
+	now := a.now().UTC()
+	s := Session{Token: base64.RawURLEncoding.EncodeToString(b), UserID: u.ID, CreatedAt: now, ExpiresAt: now.Add(a.cfg.SessionTTL)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Expired sessions are dropped here rather than by a background sweeper.
+	for key, existing := range a.sessions {
+		if !now.Before(existing.ExpiresAt) {
+			delete(a.sessions, key)
+		}
+	}
+	a.sessions[tokenKey(s.Token)] = s
+	return s, nil
+}
+
+// Session returns the user a session token belongs to, if the session exists and hasn't expired.
+func (a *Accounts) Session(token string) (User, Session, bool) {
+	if token == "" {
+		return User{}, Session{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := tokenKey(token)
+	s, ok := a.sessions[key]
+	if !ok {
+		return User{}, Session{}, false
+	}
+	if !a.now().Before(s.ExpiresAt) {
+		delete(a.sessions, key)
+		return User{}, Session{}, false
+	}
+	u, ok := a.userByID(s.UserID)
+	return u, s, ok
+}
+
+// EndSession logs a session out. Unknown tokens are ignored.
+func (a *Accounts) EndSession(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, tokenKey(token))
+}
+
+// find returns the user with the given normalized username. The caller must hold a.mu.
+func (a *Accounts) find(username string) (User, bool) {
+	for _, u := range a.users {
+		if u.Username == username {
+			return a.withRole(u), true
+		}
+	}
+	return User{}, false
+}
+
+// userByID returns the user with the given ID. The caller must hold a.mu.
+func (a *Accounts) userByID(id string) (User, bool) {
+	for _, u := range a.users {
+		if u.ID == id {
+			return a.withRole(u), true
+		}
+	}
+	return User{}, false
+}
+
+// withRole sets u.Admin: users named in Config.Admins are administrators or, if it names nobody, the first
+// account is. The caller must hold a.mu.
+func (a *Accounts) withRole(u User) User {
+	if len(a.cfg.Admins) > 0 {
+		u.Admin = slices.Contains(a.cfg.Admins, u.Username)
+	} else {
+		u.Admin = len(a.users) > 0 && a.users[0].ID == u.ID
+	}
+	return u
+}
+
+// save writes the users to disk. The caller must hold a.mu.
+func (a *Accounts) save(ctx context.Context) error {
+	return store(a.cfg.Filename, a.users, "Users", ctx)
+}
+
+// store writes v to a JSON file, unless the filename is empty.
+func store(filename string, v any, what string, ctx context.Context) error {
+	if filename == "" {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0600); err != nil { // 0600: the files contain password and token hashes
+		return err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, what+" saved to disk", "file", filename)
+	return nil
+}
+
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CSRFToken returns the token that pages of a session must send with requests that change something. It is derived
+// from the session token, so it changes with every login and needs no storing, yet gives the session token away no
+// more than tokenKey does.
+func CSRFToken(sessionToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type contextKey string
+
+const userKey contextKey = "User"
+
+// WithUser returns a copy of ctx carrying the logged-in user.
+func WithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userKey, u.Public())
+}
+
+// UserFrom returns the logged-in user carried by ctx, if any.
+func UserFrom(ctx context.Context) (User, bool) {
+	if ctx == nil {
+		return User{}, false
+	}
+	u, ok := ctx.Value(userKey).(User)
+	return u, ok
+}
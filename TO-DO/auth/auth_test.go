@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAccounts(t *testing.T, cfg Config) *Accounts {
+	t.Helper()
+	cfg.BcryptCost = bcrypt.MinCost
+	a, err := NewAccounts(cfg, context.Background())
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	return a
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "users.json")
+	a := newTestAccounts(t, Config{Filename: filename})
+	ctx := context.Background()
+
+	u, err := a.Register("  Ana ", "correct horse", ctx)
+	if err != nil || u.Username != "ana" || u.ID == "" || u.PasswordHash == "" {
+		t.Fatalf("Register failed: %+v (%v)", u, err)
+	}
+	if _, err := a.Register("ANA", "another password", ctx); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	for _, creds := range [][2]string{{"ab", "long enough"}, {"bad name", "long enough"}, {"bob", "short"}, {"bob", strings.Repeat("x", 73)}} {
+		if _, err := a.Register(creds[0], creds[1], ctx); err == nil {
+			t.Errorf("Expected %q / %q to be rejected", creds[0], creds[1])
+		}
+	}
+
+	if got, err := a.Authenticate("Ana", "correct horse"); err != nil || got.ID != u.ID {
+		t.Errorf("Expected to authenticate, got %+v (%v)", got, err)
+	}
+	if _, err := a.Authenticate("ana", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := a.Authenticate("nobody", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	// Accounts are saved, without the plain-text password, and loaded again.
+	data, _ := os.ReadFile(filename)
+	if strings.Contains(string(data), "correct horse") {
+		t.Errorf("Password stored in plain text: %s", data)
+	}
+	reloaded := newTestAccounts(t, Config{Filename: filename})
+	if _, err := reloaded.Authenticate("ana", "correct horse"); err != nil || reloaded.Count() != 1 {
+		t.Errorf("Expected the account to survive a restart, got %v", err)
+	}
+}
+
+func TestAdmins(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "users.json")
+	a := newTestAccounts(t, Config{Filename: filename})
+	ctx := context.Background()
+
+	// Without Config.Admins, the first account is the administrator.
+	ana, _ := a.Register("ana", "correct horse", ctx)
+	bob, _ := a.Register("bob", "correct horse", ctx)
+	if !ana.Admin || bob.Admin {
+		t.Errorf("Expected only the first account to be an administrator, got %v and %v", ana.Admin, bob.Admin)
+	}
+	if data, _ := os.ReadFile(filename); strings.Contains(string(data), "admin") {
+		t.Errorf("Expected the role not to be stored: %s", data)
+	}
+
+	a = newTestAccounts(t, Config{Filename: filename, Admins: []string{"Bob"}})
+	if u, _ := a.Lookup("ana"); u.Admin {
+		t.Errorf("Expected ana not to be an administrator once others are named")
+	}
+	session, _ := a.StartSession(bob)
+	if u, _, _ := a.Session(session.Token); !u.Admin {
+		t.Errorf("Expected bob's session to be an administrator's")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	a := newTestAccounts(t, Config{SessionTTL: time.Hour})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	u, _ := a.Register("ana", "correct horse", context.Background())
+
+	s, err := a.StartSession(u)
+	if err != nil || len(s.Token) < 40 || !s.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Unexpected session %+v (%v)", s, err)
+	}
+	if got, _, ok := a.Session(s.Token); !ok || got.ID != u.ID {
+		t.Errorf("Expected the session to be valid")
+	}
+	if _, _, ok := a.Session("forged"); ok {
+		t.Errorf("Expected an unknown token to be rejected")
+	}
+	other, _ := a.StartSession(u)
+	if other.Token == s.Token {
+		t.Errorf("Expected every session to get its own token")
+	}
+
+	a.EndSession(s.Token)
+	if _, _, ok := a.Session(s.Token); ok {
+		t.Errorf("Expected the session to end on logout")
+	}
+
+	now = now.Add(time.Hour)
+	if _, _, ok := a.Session(other.Token); ok {
+		t.Errorf("Expected the session to expire")
+	}
+}
+
+func TestUserContext(t *testing.T) {
+	if _, ok := UserFrom(context.Background()); ok {
+		t.Errorf("Expected no user in an empty context")
+	}
+	ctx := WithUser(context.Background(), User{ID: "1", Username: "ana", PasswordHash: "secret"})
+	if u, ok := UserFrom(ctx); !ok || u.Username != "ana" || u.PasswordHash != "" {
+		t.Errorf("Expected the user without its hash, got %+v", u)
+	}
+}
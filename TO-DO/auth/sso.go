@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// notUsername matches runs of characters that can't be in a username.
+var notUsername = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// ProvisionUser returns the user who logs in through single sign-on as subject at issuer, creating an account for
+// them the first time. The new account's username is made from the one the provider suggests (an e-mail address
+// works too), adjusted to be valid and not taken; it never takes over an existing account with the same name.
+// The account has no password, so it can only log in through the provider. created reports whether it is new.
+func (a *Accounts) ProvisionUser(issuer, subject, suggested string, ctx context.Context) (u User, created bool, err error) {
+	if issuer == "" || subject == "" {
+		return User{}, false, fmt.Errorf("an issuer and a subject are required")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, existing := range a.users {
+		if existing.Issuer == issuer && existing.Subject == subject {
+			return a.withRole(existing), false, nil
+		}
+	}
+
+	base := usernameFrom(suggested)
+	username := base
+	for n := 2; ; n++ {
+		if _, taken := a.find(username); !taken {
+			break
+		}
+		suffix := fmt.Sprintf("-%d", n)
+		username = base[:min(len(base), 32-len(suffix))] + suffix
+	}
+	u = User{ID: uuid.New().String(), Username: username, Issuer: issuer, Subject: subject, CreatedAt: a.now().UTC()}
+	a.users = append(a.users, u)
+	if err := a.save(ctx); err != nil {
+		a.users = a.users[:len(a.users)-1]
+		return User{}, false, err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "User provisioned through single sign-on.", "user_id", u.ID, "username", u.Username, "issuer", issuer)
+	return a.withRole(u), true, nil
+}
+
+// usernameFrom turns a suggested username or e-mail address into a valid username.
+func usernameFrom(suggested string) string {
+	name, _, _ := strings.Cut(NormalizeUsername(suggested), "@")
+	name = strings.TrimLeft(notUsername.ReplaceAllString(name, "-"), "._-")
+	name = name[:min(len(name), 32)]
+	if len(name) < 3 {
+		return "user"
+	}
+	return name
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestProvisionUser(t *testing.T) {
+	ctx := context.Background()
+	a, err := NewAccounts(Config{BcryptCost: bcrypt.MinCost}, ctx)
+	if err != nil {
+		t.Fatalf("NewAccounts failed: %v", err)
+	}
+	a.Register("ana", "correct horse", ctx)
+
+	// The first login creates an account, without taking over the existing "ana".
+	u, created, err := a.ProvisionUser("https://login.example.com", "123", "Ana", ctx)
+	if err != nil || !created || u.Username != "ana-2" || u.PasswordHash != "" {
+		t.Fatalf("Unexpected first login: %+v, %v, %v", u, created, err)
+	}
+	// Later logins find it again, even if the suggested username has changed.
+	again, created, err := a.ProvisionUser("https://login.example.com", "123", "someone-else", ctx)
+	if err != nil || created || again.ID != u.ID {
+		t.Errorf("Expected the same user again, got %+v, %v, %v", again, created, err)
+	}
+	// The same subject at another issuer is another person.
+	if other, created, _ := a.ProvisionUser("https://other.example.com", "123", "Ana", ctx); !created || other.ID == u.ID || other.Username != "ana-3" {
+		t.Errorf("Expected a new user for another issuer, got %+v", other)
+	}
+	// Provisioned users can't log in with a password.
+	if _, err := a.Authenticate("ana-2", ""); err == nil {
+		t.Errorf("Expected a password login to fail")
+	}
+
+	for suggested, want := range map[string]string{
+		"Jane.Doe@example.com": "jane.doe",
+		"Zoë Smith":            "zo-smith",
+		"__x":                  "user",
+		"":                     "user",
+	} {
+		if got := usernameFrom(suggested); got != want {
+			t.Errorf("usernameFrom(%q): expected %q, got %q", suggested, want, got)
+		}
+	}
+	if _, _, err := a.ProvisionUser("", "123", "ana", ctx); err == nil {
+		t.Errorf("Expected a missing issuer to be refused")
+	}
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Scope limits what an API token may do. Each scope includes the ones before it: write can read, and admin can
+// do anything, including managing backups, webhooks and tokens.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRank = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// TokenPrefix starts every API token, so that leaked tokens are easy to recognise (and to search for).
+const TokenPrefix = "todo_"
+
+// Token limits.
+const (
+	MaxTokenName     = 100
+	MaxTokenLifetime = 365 * 24 * time.Hour
+	// lastUsedInterval is how often a token's LastUsedAt is written to disk; in between it is only updated in memory.
+	lastUsedInterval = time.Minute
+)
+
+// ErrInvalidToken is returned for an unknown, revoked or expired API token.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// ErrTokenNotFound is returned when revoking a token that doesn't exist or belongs to another user.
+var ErrTokenNotFound = errors.New("not found")
+
+// Token is a personal access token. Only its SHA-256 is stored; the token itself is shown once, when it is created.
+type Token struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	Hint       string    `json:"hint"` // the first characters of the token, to tell tokens apart
+	Hash       string    `json:"hash,omitempty"`
+	Scopes     []Scope   `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+// Public returns the token without its hash, for responses.
+func (t Token) Public() Token {
+	t.Hash = ""
+	return t
+}
+
+// Allows reports whether the token has the scope needed, directly or through a wider one.
+func (t Token) Allows(needed Scope) bool {
+	return slices.ContainsFunc(t.Scopes, func(s Scope) bool { return scopeRank[s] >= scopeRank[needed] })
+}
+
+// ValidateToken checks a new token's name, scopes and expiry.
+func ValidateToken(name string, scopes []Scope, expiresAt, now time.Time) error {
+	if strings.TrimSpace(name) == "" || utf8.RuneCountInString(name) > MaxTokenName {
+		return fmt.Errorf("name must be between 1 and %d characters", MaxTokenName)
+	}
+	if len(scopes) == 0 {
+		return errors.New("a token needs at least one scope (read, write or admin)")
+	}
+	for _, s := range scopes {
+		if scopeRank[s] == 0 {
+			return fmt.Errorf("unknown scope %q (use read, write or admin)", s)
+		}
+	}
+	if !expiresAt.After(now) || expiresAt.Sub(now) > MaxTokenLifetime {
+		return fmt.Errorf("a token must expire in the future and within %d days", int(MaxTokenLifetime.Hours()/24))
+	}
+	return nil
+}
+
+// CreateToken creates an API token for a user and returns it along with the secret token itself, which the
+// user must copy now: it can't be recovered later.
+func (a *Accounts) CreateToken(u User, name string, scopes []Scope, expiresAt time.Time, ctx context.Context) (Token, string, error) {
+	now := a.now().UTC()
+	name = strings.TrimSpace(name)
+	if err := ValidateToken(name, scopes, expiresAt, now); err != nil {
+		return Token{}, "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Token{}, "", err
+	}
+	secret := TokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	t := Token{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Name:      name,
+		Hint:      secret[:len(TokenPrefix)+4],
+		Hash:      hashToken(secret),
+		Scopes:    slices.Compact(slices.Clone(scopes)),
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens = append(a.tokens, t)
+	if err := a.saveTokens(ctx); err != nil {
+		a.tokens = a.tokens[:len(a.tokens)-1]
+		return Token{}, "", err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "API token created.", "token_id", t.ID, "user_id", u.ID, "scopes", t.Scopes)
+	return t, secret, nil
+}
+
+// Tokens returns a user's tokens, without their hashes, oldest first.
+func (a *Accounts) Tokens(userID string) []Token {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var tokens []Token
+	for _, t := range a.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t.Public())
+		}
+	}
+	return tokens
+}
+
+// RevokeToken deletes one of a user's tokens. Other users' tokens are reported as not found.
+func (a *Accounts) RevokeToken(userID, id string, ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := slices.IndexFunc(a.tokens, func(t Token) bool { return t.ID == id && t.UserID == userID })
+	if i < 0 {
+		return fmt.Errorf("token with id %s %w", id, ErrTokenNotFound)
+	}
+	revoked := a.tokens[i]
+	a.tokens = slices.Delete(a.tokens, i, i+1)
+	if err := a.saveTokens(ctx); err != nil {
+		a.tokens = slices.Insert(a.tokens, i, revoked)
+		return err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "API token revoked.", "token_id", id, "user_id", userID)
+	return nil
+}
+
+// AuthenticateToken returns the user and token a secret token belongs to, and records that it was used.
+func (a *Accounts) AuthenticateToken(secret string, ctx context.Context) (User, Token, error) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return User{}, Token{}, ErrInvalidToken
+	}
+	hash := hashToken(secret)
+	now := a.now().UTC()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := slices.IndexFunc(a.tokens, func(t Token) bool { return t.Hash == hash })
+	if i < 0 || !now.Before(a.tokens[i].ExpiresAt) {
+		return User{}, Token{}, ErrInvalidToken
+	}
+	u, ok := a.userByID(a.tokens[i].UserID)
+	if !ok {
+		return User{}, Token{}, ErrInvalidToken
+	}
+	t := &a.tokens[i]
+	persist := now.Sub(t.LastUsedAt) >= lastUsedInterval
+	t.LastUsedAt = now
+	if persist {
+		// Losing a last-used time isn't worth failing the request for.
+		if err := a.saveTokens(ctx); err != nil {
+			slog.Default().Log(ctx, slog.LevelWarn, "Failed to save token last-used time.", "token_id", t.ID, "error", err)
+		}
+	}
+	return u, t.Public(), nil
+}
+
+// saveTokens writes the tokens to disk. The caller must hold a.mu.
+func (a *Accounts) saveTokens(ctx context.Context) error {
+	return store(a.cfg.TokenFilename, a.tokens, "API tokens", ctx)
+}
+
+// hashToken hashes an API token for storage. Tokens are random and long, so unlike passwords they don't need a
+// slow hash.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+const apiTokenKey contextKey = "Token"
+
+// WithToken returns a copy of ctx carrying the API token the request was authenticated with, without its hash.
+func WithToken(ctx context.Context, t Token) context.Context {
+	t.Hash = ""
+	return context.WithValue(ctx, apiTokenKey, t)
+}
+
+// TokenFrom returns the API token carried by ctx, if the request was authenticated with one.
+func TokenFrom(ctx context.Context) (Token, bool) {
+	if ctx == nil {
+		return Token{}, false
+	}
+	t, ok := ctx.Value(apiTokenKey).(Token)
+	return t, ok
+}
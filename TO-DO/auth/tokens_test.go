@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Filename: filepath.Join(dir, "users.json"), TokenFilename: filepath.Join(dir, "tokens.json")}
+	a := newTestAccounts(t, cfg)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+	ana, _ := a.Register("ana", "correct horse", ctx)
+	bob, _ := a.Register("bob", "correct horse", ctx)
+
+	tok, secret, err := a.CreateToken(ana, " ci ", []Scope{ScopeRead}, now.Add(time.Hour), ctx)
+	if err != nil || !strings.HasPrefix(secret, TokenPrefix) || tok.Name != "ci" || !strings.HasPrefix(secret, tok.Hint) {
+		t.Fatalf("Unexpected token %+v %q (%v)", tok, secret, err)
+	}
+
+	u, got, err := a.AuthenticateToken(secret, ctx)
+	if err != nil || u.ID != ana.ID || got.ID != tok.ID || !got.LastUsedAt.Equal(now) || got.Hash != "" {
+		t.Errorf("Expected the token to authenticate ana, got %+v %+v (%v)", u, got, err)
+	}
+	if _, _, err := a.AuthenticateToken(TokenPrefix+"forged", ctx); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for an unknown token, got %v", err)
+	}
+
+	// Only the hash is stored, and tokens survive a restart.
+	data, _ := os.ReadFile(cfg.TokenFilename)
+	if strings.Contains(string(data), secret) || !strings.Contains(string(data), hashToken(secret)) {
+		t.Errorf("Expected only the token's hash on disk: %s", data)
+	}
+	reloaded := newTestAccounts(t, cfg)
+	reloaded.now = a.now
+	if _, _, err := reloaded.AuthenticateToken(secret, ctx); err != nil {
+		t.Errorf("Expected the token to survive a restart, got %v", err)
+	}
+
+	// Users only see and revoke their own tokens.
+	if tokens := a.Tokens(bob.ID); len(tokens) != 0 {
+		t.Errorf("Expected bob to have no tokens, got %+v", tokens)
+	}
+	if err := a.RevokeToken(bob.ID, tok.ID, ctx); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected bob not to find ana's token, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, _, err := a.AuthenticateToken(secret, ctx); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the token to expire, got %v", err)
+	}
+	if err := a.RevokeToken(ana.ID, tok.ID, ctx); err != nil || len(a.Tokens(ana.ID)) != 0 {
+		t.Errorf("Expected the token to be revoked, got %v", err)
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		scopes  []Scope
+		expires time.Time
+		ok      bool
+	}{
+		{"ci", []Scope{ScopeRead, ScopeWrite}, now.Add(24 * time.Hour), true},
+		{"", []Scope{ScopeRead}, now.Add(time.Hour), false},
+		{strings.Repeat("x", MaxTokenName+1), []Scope{ScopeRead}, now.Add(time.Hour), false},
+		{"ci", nil, now.Add(time.Hour), false},
+		{"ci", []Scope{"delete"}, now.Add(time.Hour), false},
+		{"ci", []Scope{ScopeRead}, now, false},
+		{"ci", []Scope{ScopeRead}, now.Add(MaxTokenLifetime + time.Hour), false},
+	} {
+		if err := ValidateToken(tc.name, tc.scopes, tc.expires, now); (err == nil) != tc.ok {
+			t.Errorf("ValidateToken(%q, %v, %v): expected ok=%v, got %v", tc.name, tc.scopes, tc.expires, tc.ok, err)
+		}
+	}
+}
+
+func TestTokenAllows(t *testing.T) {
+	for _, tc := range []struct {
+		scopes []Scope
+		needed Scope
+		ok     bool
+	}{
+		{[]Scope{ScopeRead}, ScopeRead, true},
+		{[]Scope{ScopeRead}, ScopeWrite, false},
+		{[]Scope{ScopeWrite}, ScopeRead, true},
+		{[]Scope{ScopeWrite}, ScopeAdmin, false},
+		{[]Scope{ScopeRead, ScopeAdmin}, ScopeWrite, true},
+	} {
+		if got := (Token{Scopes: tc.scopes}).Allows(tc.needed); got != tc.ok {
+			t.Errorf("%v allows %s: expected %v, got %v", tc.scopes, tc.needed, tc.ok, got)
+		}
+	}
+}
@@ -3,3 +3,16 @@ module GoAcademy/TO-DO
 go 1.25.1
 
 require github.com/google/uuid v1.6.0
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/yuin/goldmark v1.7.17
+	golang.org/x/crypto v0.24.0
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+)
@@ -2,12 +2,24 @@ package main
 
 import (
 	"GoAcademy/TO-DO/api"
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/oidc"
+	"GoAcademy/TO-DO/oidc/oidctest"
+	"GoAcademy/TO-DO/ratelimit"
+	"GoAcademy/TO-DO/reminder"
+	"GoAcademy/TO-DO/tenant"
 	"GoAcademy/TO-DO/todo"
+	"GoAcademy/TO-DO/webhook"
+	"cmp"
 	"context"
+	"expvar"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -75,17 +87,238 @@ func main() {
 	// Start the actor goroutine. This runs in the background.
 	todo.StartStore(todo.Filename)
 
+	// Start delivering actor events to webhook subscribers in the background. WEBHOOK_ALLOW_PRIVATE=true lets
+	// webhooks be sent to loopback, private and link-local addresses, e.g. a receiver on the same host.
+	webhookCfg := webhook.Config{Filename: webhook.Filename, AllowPrivate: os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"}
+	webhooks, err := webhook.NewDispatcher(webhookCfg, ctx)
+	if err != nil {
+		slog.Default().Log(ctx, slog.LevelError, "Failed to load webhook subscriptions", "file", webhook.Filename, "error", err)
+		os.Exit(1)
+	}
+	webhooks.Start(ctx)
+
+	// Load the user accounts. Every page and endpoint except logging in and registering needs a session, or an
+	// API token sent as "Authorization: Bearer <token>" (create one with POST /api/v1/auth/tokens).
+	// AUTH_OPEN_REGISTRATION=true lets anyone create an account; otherwise only the first one can be created
+	// without logging in. AUTH_SESSION_TTL (e.g. "12h") sets how long a login lasts. AUTH_ADMINS (e.g. "ana,bob")
+	// names the administrators, who alone may create accounts and use backups, webhooks and /debug/; by default
+	// the first account is.
+	authCfg := auth.Config{Filename: auth.Filename, TokenFilename: auth.TokenFilename, OpenRegistration: os.Getenv("AUTH_OPEN_REGISTRATION") == "true"}
+	if admins := os.Getenv("AUTH_ADMINS"); admins != "" {
+		authCfg.Admins = strings.Split(admins, ",")
+	}
+	if ttl := os.Getenv("AUTH_SESSION_TTL"); ttl != "" {
+		if authCfg.SessionTTL, err = time.ParseDuration(ttl); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid AUTH_SESSION_TTL", "error", err)
+			os.Exit(1)
+		}
+	}
+	accounts, err := auth.NewAccounts(authCfg, ctx)
+	if err != nil {
+		slog.Default().Log(ctx, slog.LevelError, "Failed to load user accounts", "file", auth.Filename, "error", err)
+		os.Exit(1)
+	}
+
+	// Let users log in through an OpenID Connect provider when OIDC_ISSUER and OIDC_CLIENT_ID are set
+	// (and OIDC_CLIENT_SECRET, if the provider issued one). OIDC_REDIRECT_URL is this server's callback URL as the
+	// provider knows it, and OIDC_NAME what the login page calls the provider. OIDC_FAKE=true instead starts the
+	// fake provider from oidctest on OIDC_FAKE_ADDR (":9090" by default), for trying it out offline.
+	oidcCfg := oidc.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}
+	if oidcCfg.RedirectURL == "" {
+		oidcCfg.RedirectURL = "http://localhost" + ServerAddr + "/auth/oidc/callback"
+	}
+	if os.Getenv("OIDC_FAKE") == "true" {
+		addr := os.Getenv("OIDC_FAKE_ADDR")
+		if addr == "" {
+			addr = ":9090"
+		}
+		oidcCfg.Issuer, oidcCfg.ClientID = "http://localhost"+addr, "todo"
+		fake, err := oidctest.New(oidcCfg.Issuer, oidcCfg.ClientID)
+		if err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to create the fake OpenID Connect provider", "error", err)
+			os.Exit(1)
+		}
+		// Listening before carrying on means the provider is there to be discovered below.
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to start the fake OpenID Connect provider", "addr", addr, "error", err)
+			os.Exit(1)
+		}
+		go http.Serve(ln, fake)
+		slog.Default().Log(ctx, slog.LevelWarn, "Fake OpenID Connect provider started; anyone can log in as anyone. Never use it in production.", "issuer", oidcCfg.Issuer)
+	}
+	var oidcProvider *oidc.Provider
+	if oidcCfg.Issuer != "" {
+		if oidcProvider, err = oidc.Discover(oidcCfg, ctx); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to set up OpenID Connect", "issuer", oidcCfg.Issuer, "error", err)
+			os.Exit(1)
+		}
+		api.SSOName = os.Getenv("OIDC_NAME")
+		if api.SSOName == "" {
+			api.SSOName = "single sign-on"
+		}
+	}
+
+	// Give every tenant its own store, in TENANT_DIR/<tenant>. Requests pick their tenant with the X-Tenant header
+	// or, when TENANT_BASE_DOMAIN is set (e.g. "todo.example.com"), with the subdomain (acme.todo.example.com);
+	// other requests use the default store above. A tenant's store is started on its first request and stopped
+	// after TENANT_IDLE_TIMEOUT (e.g. "30m") without one. TENANT_MAX_ITEMS limits how many items each tenant may
+	// have, and TENANT_QUOTAS (e.g. "acme=5000,globex=100") sets the limit for particular tenants. Only the
+	// tenants in TENANT_MEMBERS (e.g. "acme=ana|bob,globex=carol") exist, and only for the users listed.
+	tenantCfg := tenant.Config{Dir: os.Getenv("TENANT_DIR"), BaseDomain: os.Getenv("TENANT_BASE_DOMAIN")}
+	if members := os.Getenv("TENANT_MEMBERS"); members != "" {
+		if tenantCfg.Members, err = tenant.ParseMembers(members); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid TENANT_MEMBERS", "error", err)
+			os.Exit(1)
+		}
+	}
+	if timeout := os.Getenv("TENANT_IDLE_TIMEOUT"); timeout != "" {
+		if tenantCfg.IdleTimeout, err = time.ParseDuration(timeout); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid TENANT_IDLE_TIMEOUT", "error", err)
+			os.Exit(1)
+		}
+	}
+	if maxItems := os.Getenv("TENANT_MAX_ITEMS"); maxItems != "" {
+		if tenantCfg.MaxItems, err = strconv.Atoi(maxItems); err != nil || tenantCfg.MaxItems < 0 {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid TENANT_MAX_ITEMS", "value", maxItems)
+			os.Exit(1)
+		}
+	}
+	if quotas := os.Getenv("TENANT_QUOTAS"); quotas != "" {
+		if tenantCfg.Quotas, err = tenant.ParseQuotas(quotas); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid TENANT_QUOTAS", "error", err)
+			os.Exit(1)
+		}
+	}
+	tenants := tenant.NewRegistry(tenantCfg)
+	tenants.Start(ctx)
+
+	// Limit how fast each client may send requests, so that no one client can keep the actor busy. RATE_LIMIT_READ
+	// and RATE_LIMIT_WRITE set the rate and burst of GET requests and of everything else (e.g. "20/s:40" or
+	// "600/m"), or "off" for no limit. RATE_LIMIT_AUTH does the same for each address's attempts at logging in and
+	// requests with a wrong token or session. RATE_LIMIT_TRUST_PROXY=true takes clients' addresses from X-Forwarded-For.
+	// The counts of allowed and rejected requests are published at /debug/vars, under "ratelimit".
+	rateCfg := ratelimit.Config{Rates: map[ratelimit.Class]ratelimit.Rate{}}
+	for class, def := range map[ratelimit.Class]string{ratelimit.ClassRead: "20/s:40", ratelimit.ClassWrite: "10/s:20", ratelimit.ClassAuth: "10/m"} {
+		env := "RATE_LIMIT_" + strings.ToUpper(string(class))
+		spec := cmp.Or(os.Getenv(env), def)
+		if spec == "off" {
+			continue
+		}
+		if rateCfg.Rates[class], err = ratelimit.ParseRate(spec); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid "+env, "error", err)
+			os.Exit(1)
+		}
+	}
+	if trust := os.Getenv("RATE_LIMIT_TRUST_PROXY"); trust != "" {
+		if api.TrustProxy, err = strconv.ParseBool(trust); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid RATE_LIMIT_TRUST_PROXY", "value", trust)
+			os.Exit(1)
+		}
+	}
+	limiter := ratelimit.New(rateCfg)
+	api.AuthLimiter = limiter
+	expvar.Publish("ratelimit", limiter.Metrics())
+
+	// Start the reminder scheduler alongside the actor. Reminders are always logged, and are also sent to a
+	// webhook and/or e-mailed when the REMINDER_* environment variables below are set. The scheduler also
+	// covers every tenant, reaching their stores through the registry.
+	reminderCfg := reminder.Config{Tenants: tenants.Tenants(), Acquire: tenants.Acquire}
+	if offsets := os.Getenv("REMINDER_OFFSETS"); offsets != "" { // e.g. "-24h,0s,24h"
+		if reminderCfg.Offsets, err = reminder.ParseOffsets(offsets); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Invalid REMINDER_OFFSETS", "error", err)
+			os.Exit(1)
+		}
+	}
+	notifiers := []reminder.Notifier{reminder.LogNotifier{}}
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, reminder.WebhookNotifier{URL: url, Secret: os.Getenv("REMINDER_WEBHOOK_SECRET")})
+	}
+	if addr := os.Getenv("REMINDER_SMTP_ADDR"); addr != "" {
+		notifiers = append(notifiers, reminder.SMTPNotifier{
+			Addr: addr,
+			From: os.Getenv("REMINDER_SMTP_FROM"),
+			To:   strings.Split(os.Getenv("REMINDER_SMTP_TO"), ","),
+		})
+	}
+	reminders := reminder.NewScheduler(reminderCfg, notifiers...)
+	if err := reminders.Start(ctx); err != nil {
+		slog.Default().Log(ctx, slog.LevelError, "Failed to start reminder scheduler", "error", err)
+		os.Exit(1)
+	}
+
 	// Set up signal handling to gracefully handle termination signals
 	// SIGINT is Ctrl+C. SIGTERM is a generic termination signal (e.g., from a 'kill' command).
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Set up HTTP handlers
+	// Accounts and sessions
+	http.HandleFunc("/login", api.LoginPageHandler(accounts))
+	http.HandleFunc("/register", api.RegisterPageHandler(accounts))
+	http.HandleFunc("/logout", api.LogoutHandler(accounts))
+	if oidcProvider != nil {
+		http.HandleFunc("/auth/oidc/login", api.OIDCLoginHandler(oidcProvider))
+		http.HandleFunc("/auth/oidc/callback", api.OIDCCallbackHandler(accounts, oidcProvider))
+	}
+	http.HandleFunc("/api/v1/auth/register", api.RegisterHandler(accounts))
+	http.HandleFunc("/api/v1/auth/login", api.LoginHandler(accounts))
+	http.HandleFunc("/api/v1/auth/logout", api.LogoutHandler(accounts))
+	http.HandleFunc("/api/v1/auth/me", api.MeHandler)
+	http.HandleFunc("/api/v1/auth/tokens", api.TokensHandler(accounts))
+	http.HandleFunc("/api/v1/auth/tokens/{id}", api.TokenHandler(accounts))
 	http.HandleFunc("/get", api.GetHandler)
 	http.HandleFunc("/create", api.CreateHandler)
 	http.HandleFunc("/update", api.UpdateHandler)
 	http.HandleFunc("/delete", api.DeleteHandler)
 	http.HandleFunc("/list", api.ListHandler)
+	http.HandleFunc("/todos/{id}", api.ItemPageHandler)
+	// WebSocket endpoint for issuing commands and receiving change events over one connection
+	http.HandleFunc("/api/v1/ws", api.WebSocketHandler)
+	// Bulk export and import of the whole list
+	http.HandleFunc("/api/v1/todos/export", api.ExportHandler)
+	http.HandleFunc("/api/v1/todos/import", api.ImportHandler)
+	http.HandleFunc("/api/v1/todos.ics", api.ICalFeedHandler)
+	// Full-text search over the list
+	http.HandleFunc("/api/v1/todos/search", api.SearchHandler)
+	http.HandleFunc("/api/v1/todos/{id}/attachments", api.AttachmentsHandler)
+	http.HandleFunc("/api/v1/todos/{id}/attachments/{attachment}", api.AttachmentHandler)
+	http.HandleFunc("/api/v1/todos/{id}/comments", api.CommentsHandler)
+	http.HandleFunc("/api/v1/todos/{id}/comments/{comment}", api.CommentHandler)
+	http.HandleFunc("/api/v1/todos/{id}/timer", api.TimerStartHandler)
+	http.HandleFunc("/api/v1/timer", api.TimerHandler)
+	http.HandleFunc("/api/v1/todos/{id}/time-entries", api.TimeEntriesHandler)
+	http.HandleFunc("/api/v1/todos/{id}/time-entries/{entry}", api.TimeEntryHandler)
+	http.HandleFunc("/api/v1/reports/time", api.TimeReportHandler)
+	http.HandleFunc("/api/v1/reports/burndown", api.BurndownHandler)
+	http.HandleFunc("/reports/burndown", api.BurndownPageHandler)
+	// Workflow statuses and the board
+	http.HandleFunc("/api/v1/todos/{id}/transition", api.TransitionHandler)
+	http.HandleFunc("/api/v1/todos/{id}/assignees", api.AssigneesHandler(accounts))
+	http.HandleFunc("/me/todos", api.MyTodosHandler)
+	http.HandleFunc("/api/v1/todos/{id}/shares", api.SharesHandler(accounts))
+	http.HandleFunc("/api/v1/todos/{id}/shares/{username}", api.ShareHandler(accounts))
+	http.HandleFunc("/api/v1/lists/{list}/shares", api.SharesHandler(accounts))
+	http.HandleFunc("/api/v1/lists/{list}/shares/{username}", api.ShareHandler(accounts))
+	http.HandleFunc("/api/v1/workflows", api.WorkflowsHandler)
+	http.HandleFunc("/api/v1/workflows/{list}", api.WorkflowHandler)
+	http.HandleFunc("/board", api.BoardPageHandler)
+	// Custom fields per list
+	http.HandleFunc("/api/v1/fields", api.FieldsHandler)
+	http.HandleFunc("/api/v1/fields/{list}", api.ListFieldsHandler)
+	// Backup and restore of the whole list
+	http.HandleFunc("/api/v1/admin/backup", api.BackupHandler)
+	http.HandleFunc("/api/v1/admin/restore", api.RestoreHandler)
+	// Webhook subscriptions and their delivery log
+	http.HandleFunc("/api/v1/webhooks", api.WebhooksHandler(webhooks))
+	http.HandleFunc("/api/v1/webhooks/{id}", api.WebhookHandler(webhooks))
+	http.HandleFunc("/api/v1/webhooks/deliveries", api.WebhookDeliveriesHandler(webhooks))
+	http.HandleFunc("/api/v1/webhooks/dead-letters", api.WebhookDeadLettersHandler(webhooks))
 	// serve static files for the web frontend
 	http.Handle("/about/", http.StripPrefix("/about/", http.FileServer(http.Dir("web/static/about"))))
 
@@ -96,8 +329,13 @@ func main() {
 
 	// create an http.Server that listens on ServerAddr.
 	// Handler is the DefaultServeMux wrapped by traceIDMiddleware so each request
-	// gets a per-request TraceID placed into r.Context() and an X-Trace-ID header.
-	server := &http.Server{Addr: ServerAddr, Handler: traceIDMiddleware(http.DefaultServeMux)}
+	// gets a per-request TraceID placed into r.Context() and an X-Trace-ID header,
+	// then by the auth middleware, which puts the logged-in user there too or turns the request away,
+	// then by the CSRF middleware, which turns away requests from other sites that would change something,
+	// then by the rate limit middleware, which turns away clients sending too many requests,
+	// and then by the tenant middleware, which points the request's commands at its tenant's store.
+	handler := api.CSRFMiddleware(api.RateLimitMiddleware(limiter, api.TenantMiddleware(tenants, http.DefaultServeMux)))
+	server := &http.Server{Addr: ServerAddr, Handler: traceIDMiddleware(api.AuthMiddleware(accounts, handler))}
 
 	// Start the server in a separate goroutine so the main goroutine can continue
 	// (for example, to wait for OS signals). ListenAndServe blocks while serving.
@@ -132,6 +370,15 @@ func main() {
 			"error", err)
 	}
 
+	// Stop the background workers before the actor goes away; pending webhook retries are abandoned.
+	reminders.Stop()
+	webhooks.Stop()
+
+	// Save and stop the tenants' stores, then the default one.
+	if err := tenants.Stop(ctx); err != nil {
+		slog.Default().Log(ctx, slog.LevelError, "Failed to save tenant data", "error", err)
+	}
+
 	// Send the shutdown command to the actor. This ensures it processes any remaining items, saves, and exits.
 	slog.Default().Log(ctx, slog.LevelInfo, "Sending shutdown command to actor.")
 	shutdownCmd := todo.Command{
@@ -0,0 +1,394 @@
+// Package oidc logs users in through an OpenID Connect provider, using the authorization code flow with PKCE.
+//
+// A Provider is created from the issuer's discovery document. The web layer sends the browser to AuthCodeURL,
+// trades the code the provider sends back for an ID token with Exchange, and checks that token with Verify:
+// its RS256 signature against the issuer's published keys (JWKS), and its issuer, audience, expiry and nonce.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryPath is where an issuer publishes its Metadata, relative to the issuer URL.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+const (
+	// leeway is how far the provider's clock may be off from ours when checking expiry.
+	leeway = time.Minute
+	// keyRefreshInterval is how often, at most, the keys are fetched again because a token names an unknown one.
+	keyRefreshInterval = time.Minute
+	// maxResponseSize limits what is read from the provider.
+	maxResponseSize = 1 << 20
+)
+
+// ErrInvalidToken is returned for ID tokens that fail any check.
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// Config identifies this application to the provider. Zero values are replaced by the defaults below.
+type Config struct {
+	Issuer       string       // the provider's issuer URL, e.g. "https://login.example.com"
+	ClientID     string       // the client ID registered with the provider
+	ClientSecret string       // the client secret, if the provider issued one; PKCE is used either way
+	RedirectURL  string       // where the provider sends the browser back to, e.g. "https://todo.example.com/auth/oidc/callback"
+	Scopes       []string     // scopes to ask for; "openid" is always included
+	Client       *http.Client // used for every request to the provider
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "profile", "email"}
+	}
+	if !slices.Contains(c.Scopes, "openid") {
+		c.Scopes = append([]string{"openid"}, c.Scopes...)
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return c
+}
+
+// Metadata is the part of the provider's discovery document this package uses.
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported,omitempty"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// Claims are the claims of an ID token that this package checks or the application uses.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          Audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp,omitempty"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	EmailVerified     bool     `json:"email_verified,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Name              string   `json:"name,omitempty"`
+}
+
+// Audience is the "aud" claim, which may be a single string or an array of them.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Provider is a discovered OpenID Connect provider.
+type Provider struct {
+	cfg  Config
+	meta Metadata
+	now  func() time.Time // replaced in tests
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey // by key ID
+	fetchedAt time.Time
+}
+
+// Discover fetches the issuer's discovery document and returns the Provider it describes.
+// The document must be for the configured issuer and support PKCE with S256.
+func Discover(cfg Config, ctx context.Context) (*Provider, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("the issuer, client ID and redirect URL must all be set")
+	}
+	p := &Provider{cfg: cfg, now: time.Now}
+	if err := p.getJSON(ctx, strings.TrimSuffix(cfg.Issuer, "/")+DiscoveryPath, &p.meta); err != nil {
+		return nil, fmt.Errorf("could not discover %s: %w", cfg.Issuer, err)
+	}
+	switch {
+	case p.meta.Issuer != cfg.Issuer:
+		return nil, fmt.Errorf("discovery document is for issuer %q, not %q", p.meta.Issuer, cfg.Issuer)
+	case p.meta.AuthorizationEndpoint == "" || p.meta.TokenEndpoint == "" || p.meta.JWKSURI == "":
+		return nil, errors.New("discovery document lacks an authorization, token or JWKS endpoint")
+	case len(p.meta.CodeChallengeMethods) > 0 && !slices.Contains(p.meta.CodeChallengeMethods, "S256"):
+		return nil, errors.New("provider doesn't support PKCE with S256")
+	}
+	return p, nil
+}
+
+// Metadata returns the provider's discovery document.
+func (p *Provider) Metadata() Metadata {
+	return p.meta
+}
+
+// AuthCodeURL returns the URL to send the browser to for logging in. state and nonce are random values the caller
+// keeps until the callback; challenge is the S256 challenge of the PKCE verifier it keeps (see NewPKCE).
+func (p *Provider) AuthCodeURL(state, nonce, challenge string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.meta.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// tokenResponse is the token endpoint's reply.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades an authorization code, and the PKCE verifier it was requested with, for the raw ID token.
+// The token still has to be checked with Verify.
+func (p *Provider) Exchange(code, verifier string, ctx context.Context) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&tr); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s and no valid JSON: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return "", errors.New("token endpoint returned no ID token")
+	}
+	return tr.IDToken, nil
+}
+
+// Verify checks an ID token's signature against the provider's keys, and that it was issued by the provider, for
+// this client, with the given nonce, and hasn't expired. It returns the token's claims.
+func (p *Provider) Verify(rawIDToken, nonce string, ctx context.Context) (Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("%w: bad header: %v", ErrInvalidToken, err)
+	}
+	// Only RS256 is accepted, whatever the token says, so "none" or an HMAC keyed with the public key can't get through.
+	if header.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	key, err := p.key(header.Kid, ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return Claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: bad claims: %v", ErrInvalidToken, err)
+	}
+	now := p.now()
+	switch {
+	case claims.Issuer != p.meta.Issuer:
+		return Claims{}, fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.cfg.ClientID):
+		return Claims{}, fmt.Errorf("%w: not meant for this client", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return Claims{}, fmt.Errorf("%w: authorized party is %q", ErrInvalidToken, claims.AuthorizedParty)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(leeway)):
+		return Claims{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)):
+		return Claims{}, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return Claims{}, fmt.Errorf("%w: nonce doesn't match", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// key returns the provider's public key with the given ID, fetching the keys again if it isn't known yet,
+// since providers rotate their keys.
+func (p *Provider) key(kid string, ctx context.Context) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if !p.fetchedAt.IsZero() && p.now().Sub(p.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	var set JWKS
+	if err := p.getJSON(ctx, p.meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("could not fetch the provider's keys: %w", err)
+	}
+	p.keys = set.rsaKeys()
+	p.fetchedAt = p.now()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// getJSON fetches a JSON document from the provider.
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// decodeSegment decodes one base64url-encoded JSON part of a JWT.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// JWKS is a JSON Web Key Set, as published at the provider's jwks_uri.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is one key of a JWKS. Only RSA signing keys are used.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewJWK returns the JWK of an RSA public key, for RS256 signatures.
+func NewJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// rsaKeys returns the set's RSA signing keys by key ID, skipping any it can't use.
+func (s JWKS) rsaKeys() map[string]*rsa.PublicKey {
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys
+}
+
+// RandomString returns a random, URL-safe string with 256 bits of entropy, for states, nonces and PKCE verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewPKCE returns a new PKCE verifier, to keep until the code is exchanged, and its S256 challenge, to send
+// with AuthCodeURL.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, Challenge(verifier), nil
+}
+
+// Challenge returns the S256 PKCE challenge of a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
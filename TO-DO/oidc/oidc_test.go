@@ -0,0 +1,149 @@
+package oidc_test
+
+import (
+	"GoAcademy/TO-DO/oidc"
+	"GoAcademy/TO-DO/oidc/oidctest"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startProvider starts a fake provider that logs in ana, and discovers it.
+func startProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	fake, srv, err := oidctest.NewServer("todo")
+	if err != nil {
+		t.Fatalf("Starting the fake provider failed: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	fake.User = oidctest.Identity{Subject: "ana-123", PreferredUsername: "ana", Email: "ana@example.com"}
+	p, err := oidc.Discover(oidc.Config{Issuer: fake.Issuer, ClientID: "todo", RedirectURL: "http://app.test/callback"}, context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	return fake, p
+}
+
+// authorize follows AuthCodeURL to the fake provider and returns the query it redirects back with.
+func authorize(t *testing.T, p *oidc.Provider, state, nonce, challenge string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, challenge))
+	if err != nil {
+		t.Fatalf("Authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), "http://app.test/callback?") {
+		t.Fatalf("Expected a redirect to the callback, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return loc.Query()
+}
+
+func TestFlow(t *testing.T) {
+	_, p := startProvider(t)
+	ctx := context.Background()
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatalf("NewPKCE failed: %v", err)
+	}
+	q := authorize(t, p, "the-state", "the-nonce", challenge)
+	if q.Get("state") != "the-state" || q.Get("code") == "" {
+		t.Fatalf("Unexpected callback parameters %v", q)
+	}
+
+	// The code is bound to the PKCE verifier, and can only be used once.
+	if _, err := p.Exchange(q.Get("code"), "not-the-verifier", ctx); err == nil {
+		t.Errorf("Expected the wrong verifier to be refused")
+	}
+	q = authorize(t, p, "the-state", "the-nonce", challenge)
+	rawIDToken, err := p.Exchange(q.Get("code"), verifier, ctx)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if _, err := p.Exchange(q.Get("code"), verifier, ctx); err == nil {
+		t.Errorf("Expected a used code to be refused")
+	}
+
+	claims, err := p.Verify(rawIDToken, "the-nonce", ctx)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.Subject != "ana-123" || claims.PreferredUsername != "ana" || claims.Issuer != p.Metadata().Issuer {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if _, err := p.Verify(rawIDToken, "another-nonce", ctx); !errors.Is(err, oidc.ErrInvalidToken) {
+		t.Errorf("Expected the wrong nonce to be refused, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fake, p := startProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+	valid := func() oidc.Claims {
+		return oidc.Claims{Issuer: fake.Issuer, Subject: "ana-123", Audience: oidc.Audience{"todo"}, Expiry: now.Add(time.Hour).Unix(), IssuedAt: now.Unix(), Nonce: "n"}
+	}
+	sign := func(claims oidc.Claims) string {
+		token, err := fake.Sign(claims)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return token
+	}
+	if _, err := p.Verify(sign(valid()), "n", ctx); err != nil {
+		t.Fatalf("Expected a valid token to pass, got %v", err)
+	}
+
+	for name, modify := range map[string]func(*oidc.Claims){
+		"other issuer":    func(c *oidc.Claims) { c.Issuer = "https://evil.example.com" },
+		"other audience":  func(c *oidc.Claims) { c.Audience = oidc.Audience{"someone-else"} },
+		"no azp":          func(c *oidc.Claims) { c.Audience = oidc.Audience{"todo", "someone-else"} },
+		"expired":         func(c *oidc.Claims) { c.Expiry = now.Add(-time.Hour).Unix() },
+		"from the future": func(c *oidc.Claims) { c.IssuedAt = now.Add(time.Hour).Unix() },
+		"no subject":      func(c *oidc.Claims) { c.Subject = "" },
+	} {
+		claims := valid()
+		modify(&claims)
+		if _, err := p.Verify(sign(claims), "n", ctx); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Errorf("%s: expected the token to be refused, got %v", name, err)
+		}
+	}
+
+	token := sign(valid())
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+fake.Issuer+`","sub":"admin","aud":"todo","exp":9999999999,"nonce":"n"}`)) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	for name, raw := range map[string]string{"tampered": tampered, "alg none": unsigned, "not a JWT": "abc"} {
+		if _, err := p.Verify(raw, "n", ctx); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Errorf("%s: expected the token to be refused, got %v", name, err)
+		}
+	}
+
+	// A token signed with a key the provider doesn't publish is refused too.
+	other, otherSrv, err := oidctest.NewServer("todo")
+	if err != nil {
+		t.Fatalf("Starting another provider failed: %v", err)
+	}
+	otherSrv.Close()
+	otherToken, _ := other.Sign(valid())
+	if _, err := p.Verify(otherToken, "n", ctx); err == nil {
+		t.Errorf("Expected a token signed with an unknown key to be refused")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	fake, _ := startProvider(t)
+	if _, err := oidc.Discover(oidc.Config{Issuer: fake.Issuer + "/", ClientID: "todo", RedirectURL: "http://app.test/callback"}, context.Background()); err == nil {
+		t.Errorf("Expected a document for another issuer to be refused")
+	}
+	if _, err := oidc.Discover(oidc.Config{Issuer: fake.Issuer}, context.Background()); err == nil {
+		t.Errorf("Expected a missing client ID to be refused")
+	}
+}
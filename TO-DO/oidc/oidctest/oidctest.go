@@ -0,0 +1,281 @@
+// Package oidctest is a fake OpenID Connect provider, for tests and for trying single sign-on offline.
+//
+// It implements discovery, the authorization code flow with PKCE (S256 only) and a JWKS, and signs ID tokens
+// with a key it generates on start. It logs in whoever it is told to: the User field if set, or otherwise
+// anyone who types a username into its login form. Never use it for real logins.
+package oidctest
+
+import (
+	"GoAcademy/TO-DO/oidc"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths of the provider's endpoints, relative to the issuer URL.
+const (
+	AuthorizePath = "/authorize"
+	TokenPath     = "/token"
+	JWKSPath      = "/jwks"
+)
+
+// codeLifetime is how long an authorization code can be exchanged for.
+const codeLifetime = time.Minute
+
+// Identity is a user the fake provider logs in.
+type Identity struct {
+	Subject           string
+	PreferredUsername string
+	Email             string
+	Name              string
+}
+
+// identityFor makes up an identity for a username typed into the login form.
+func identityFor(username string) Identity {
+	return Identity{Subject: "fake|" + username, PreferredUsername: username, Email: username + "@example.com", Name: username}
+}
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	clientID, redirectURI, challenge, nonce string
+	user                                    Identity
+	expires                                 time.Time
+}
+
+// Provider is the fake provider. It is an http.Handler serving every endpoint under the issuer URL's path.
+type Provider struct {
+	Issuer       string // the issuer URL; set by NewServer, or by whoever serves the provider
+	ClientID     string
+	ClientSecret string // checked if set
+	// User is logged in straight away by the authorization endpoint. If it is empty, a login form asks for a username.
+	User Identity
+	Now  func() time.Time // the time tokens are issued at
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// New returns a fake provider with a fresh signing key.
+func New(issuer, clientID string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{Issuer: issuer, ClientID: clientID, Now: time.Now, key: key, kid: kid[:8], codes: map[string]grant{}}, nil
+}
+
+// NewServer starts a fake provider on a local test server, whose URL is the issuer. Close the server when done.
+func NewServer(clientID string) (*Provider, *httptest.Server, error) {
+	p, err := New("", clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := httptest.NewServer(p)
+	p.Issuer = srv.URL
+	return p, srv, nil
+}
+
+// ServeHTTP routes requests to the provider's endpoints.
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := ""
+	if u, err := url.Parse(p.Issuer); err == nil {
+		prefix = strings.TrimSuffix(u.Path, "/")
+	}
+	switch strings.TrimPrefix(r.URL.Path, prefix) {
+	case oidc.DiscoveryPath:
+		writeJSON(w, http.StatusOK, oidc.Metadata{
+			Issuer:                p.Issuer,
+			AuthorizationEndpoint: p.Issuer + AuthorizePath,
+			TokenEndpoint:         p.Issuer + TokenPath,
+			JWKSURI:               p.Issuer + JWKSPath,
+			CodeChallengeMethods:  []string{"S256"},
+			SigningAlgs:           []string{"RS256"},
+		})
+	case JWKSPath:
+		writeJSON(w, http.StatusOK, oidc.JWKS{Keys: []oidc.JWK{oidc.NewJWK(p.kid, &p.key.PublicKey)}})
+	case AuthorizePath:
+		p.authorize(w, r)
+	case TokenPath:
+		p.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// loginForm asks for the username to log in as, keeping the authorization request's parameters.
+var loginForm = template.Must(template.New("login").Parse(`<!doctype html>
+<html lang="en">
+<head><meta charset="utf-8" /><title>Fake OpenID Connect provider</title></head>
+<body>
+    <h1>Fake OpenID Connect provider</h1>
+    <p>For testing only: you are logged in as whoever you say you are.</p>
+    <form method="get">
+        {{ range $name, $values := . }}{{ range $values }}<input type="hidden" name="{{ $name }}" value="{{ . }}" />{{ end }}{{ end }}
+        <input type="text" name="username" placeholder="Username" required autofocus aria-label="Username" />
+        <button type="submit">Log in</button>
+    </form>
+</body>
+</html>
+`))
+
+// authorize serves the authorization endpoint. Errors about the client or redirect URI are shown rather than
+// redirected, as a real provider must, so a bad request can't bounce the browser anywhere.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != p.ClientID || q.Get("redirect_uri") == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := func(params url.Values) {
+		params.Set("state", q.Get("state"))
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	}
+	switch {
+	case q.Get("response_type") != "code":
+		back(url.Values{"error": {"unsupported_response_type"}})
+		return
+	case !strings.Contains(" "+q.Get("scope")+" ", " openid "):
+		back(url.Values{"error": {"invalid_scope"}, "error_description": {"the openid scope is required"}})
+		return
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		back(url.Values{"error": {"invalid_request"}, "error_description": {"PKCE with S256 is required"}})
+		return
+	}
+
+	user := p.User
+	if user.Subject == "" {
+		username := strings.TrimSpace(q.Get("username"))
+		if username == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			loginForm.Execute(w, q)
+			return
+		}
+		user = identityFor(username)
+	}
+
+	code, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        user,
+		expires:     p.Now().Add(codeLifetime),
+	}
+	p.mu.Unlock()
+	back(url.Values{"code": {code}})
+}
+
+// token serves the token endpoint, exchanging a code and its PKCE verifier for a signed ID token.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, secret, hasBasic := r.BasicAuth()
+	if hasBasic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != p.ClientID || (p.ClientSecret != "" && secret != p.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// Codes can only be used once, whether or not the exchange succeeds.
+	p.mu.Lock()
+	g, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	switch {
+	case !ok || p.Now().After(g.expires) || g.clientID != clientID:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown or expired code"})
+		return
+	case r.PostFormValue("redirect_uri") != g.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri doesn't match"})
+		return
+	case oidc.Challenge(r.PostFormValue("code_verifier")) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := p.Now()
+	idToken, err := p.Sign(oidc.Claims{
+		Issuer:            p.Issuer,
+		Subject:           g.user.Subject,
+		Audience:          oidc.Audience{clientID},
+		Expiry:            now.Add(time.Hour).Unix(),
+		IssuedAt:          now.Unix(),
+		Nonce:             g.nonce,
+		Email:             g.user.Email,
+		EmailVerified:     g.user.Email != "",
+		PreferredUsername: g.user.PreferredUsername,
+		Name:              g.user.Name,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	accessToken, _ := oidc.RandomString()
+	writeJSON(w, http.StatusOK, map[string]any{"access_token": accessToken, "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+}
+
+// Sign returns an RS256 JWT of the claims, signed with the provider's key, so tests can make tokens of their own.
+func (p *Provider) Sign(claims any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": p.kid, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
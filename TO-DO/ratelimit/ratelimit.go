@@ -0,0 +1,190 @@
+// Package ratelimit limits how fast each client may send requests, so that no one client can keep the actor busy.
+//
+// Every client has a token bucket per class of route: each request takes a token, and tokens come back at a steady
+// rate up to the bucket's size, its burst. A request finding the bucket empty is rejected until a token is back.
+// The Limiter counts allowed and rejected requests in an expvar.Map, for publishing with expvar.Publish.
+package ratelimit
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class is a class of routes with its own rate.
+type Class string
+
+const (
+	ClassRead  Class = "read"  // requests that only read
+	ClassWrite Class = "write" // requests that change something
+	ClassAuth  Class = "auth"  // attempts at logging in, and requests that fail authentication
+)
+
+// sweepInterval is how often buckets that have filled up again are forgotten.
+const sweepInterval = time.Minute
+
+// Rate is a token bucket's refill rate and size.
+type Rate struct {
+	PerSecond float64 // tokens added per second
+	Burst     int     // the most tokens the bucket holds, and so the most requests that can be sent at once
+}
+
+// ParseRate parses a rate of the form "<requests>/<s|m|h>", optionally followed by ":<burst>", e.g. "10/s",
+// "600/m" or "5/s:20". Without a burst, the bucket holds one unit's worth of requests.
+func ParseRate(s string) (Rate, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	count, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q is not of the form requests/unit", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("rate %q must have a positive number of requests", s)
+	}
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return Rate{}, fmt.Errorf("rate %q must be per s, m or h", s)
+	}
+	r := Rate{PerSecond: float64(n) / per.Seconds(), Burst: n}
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(burstSpec); err != nil || r.Burst <= 0 {
+			return Rate{}, fmt.Errorf("rate %q must have a positive burst", s)
+		}
+	}
+	return r, nil
+}
+
+// Result is the outcome of a request, and the state of its bucket afterwards, for the RateLimit-* headers.
+type Result struct {
+	Allowed    bool
+	Limit      int           // the bucket's size
+	Remaining  int           // whole tokens left
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a rejected request may be sent again; 0 if Allowed
+	Window     time.Duration // how long an empty bucket takes to fill
+}
+
+// Config sets the rate of each class. A class without a rate isn't limited.
+type Config struct {
+	Rates map[Class]Rate
+}
+
+// Limiter owns the buckets of every client.
+type Limiter struct {
+	cfg Config
+	now func() time.Time // replaced in tests
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+
+	metrics *expvar.Map
+}
+
+type bucketKey struct {
+	class Class
+	key   string
+}
+
+// bucket holds tokens as of last; more have come back since.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter.
+func New(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg, now: time.Now, buckets: map[bucketKey]*bucket{}, metrics: new(expvar.Map).Init()}
+}
+
+// Limits reports whether the class has a rate.
+func (l *Limiter) Limits(class Class) bool {
+	_, ok := l.cfg.Rates[class]
+	return ok
+}
+
+// Allow takes a token from the client's bucket for the class, and reports whether there was one.
+// key identifies the client; the part before the first ':' (such as "user" in "user:42") is counted in the metrics.
+func (l *Limiter) Allow(class Class, key string) Result {
+	return l.check(class, key, true)
+}
+
+// Peek reports whether the client's bucket for the class has a token, like Allow, but leaves it there.
+// It is for requests that only use up a token if they fail, such as ones with a wrong password.
+func (l *Limiter) Peek(class Class, key string) Result {
+	return l.check(class, key, false)
+}
+
+func (l *Limiter) check(class Class, key string, take bool) Result {
+	rate, ok := l.cfg.Rates[class]
+	if !ok {
+		return Result{Allowed: true}
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	l.sweep(now)
+	b, ok := l.buckets[bucketKey{class, key}]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), last: now}
+		l.buckets[bucketKey{class, key}] = b
+	}
+	b.tokens = min(float64(rate.Burst), b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+	b.last = now
+	res := Result{Limit: rate.Burst, Window: seconds(float64(rate.Burst) / rate.PerSecond)}
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate.PerSecond)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(rate.Burst) - b.tokens) / rate.PerSecond)
+	l.mu.Unlock()
+
+	kind, _, _ := strings.Cut(key, ":")
+	if res.Allowed {
+		if take {
+			l.metrics.Add("allowed."+string(class), 1)
+		}
+	} else {
+		l.metrics.Add("rejected."+string(class), 1)
+		l.metrics.Add("rejected."+string(class)+"."+kind, 1)
+	}
+	return res
+}
+
+// sweep forgets the buckets that have filled up again, since a new bucket is the same as a full one.
+// The caller must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		rate := l.cfg.Rates[k.class]
+		if b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond >= float64(rate.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Metrics returns the counts of allowed and rejected requests: "allowed.<class>", "rejected.<class>" and
+// "rejected.<class>.<kind of key>".
+func (l *Limiter) Metrics() *expvar.Map {
+	return l.metrics
+}
+
+// seconds converts a number of seconds to a Duration.
+func seconds(s float64) time.Duration {
+	if math.IsInf(s, 0) || math.IsNaN(s) {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
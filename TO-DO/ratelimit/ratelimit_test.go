@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, want := range map[string]Rate{
+		"10/s":   {PerSecond: 10, Burst: 10},
+		"600/m":  {PerSecond: 10, Burst: 600},
+		"5/s:20": {PerSecond: 5, Burst: 20},
+		"36/h:1": {PerSecond: 0.01, Burst: 1},
+	} {
+		if got, err := ParseRate(s); err != nil || got != want {
+			t.Errorf("ParseRate(%q): expected %+v, got %+v, %v", s, want, got, err)
+		}
+	}
+	for _, s := range []string{"", "10", "0/s", "-1/s", "10/d", "ten/s", "10/s:0", "10/s:many"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := New(Config{Rates: map[Class]Rate{ClassWrite: {PerSecond: 2, Burst: 3}}})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	// Reads have no rate, so they are never limited.
+	for range 10 {
+		if !l.Allow(ClassRead, "user:1").Allowed {
+			t.Fatal("Expected reads to be unlimited")
+		}
+	}
+	if l.Limits(ClassRead) || !l.Limits(ClassWrite) {
+		t.Error("Expected only writes to be limited")
+	}
+
+	// The burst is allowed at once, and then the client must wait for a token to come back.
+	for i := range 3 {
+		if res := l.Allow(ClassWrite, "user:1"); !res.Allowed || res.Remaining != 2-i || res.Limit != 3 {
+			t.Fatalf("Request %d: expected to be allowed with %d left, got %+v", i, 2-i, res)
+		}
+	}
+	res := l.Allow(ClassWrite, "user:1")
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond || res.Window != 1500*time.Millisecond {
+		t.Fatalf("Expected to be rejected for half a second, got %+v", res)
+	}
+	// Other clients have buckets of their own.
+	if !l.Allow(ClassWrite, "token:abc").Allowed {
+		t.Error("Expected another client to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res := l.Allow(ClassWrite, "user:1"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Expected a token to be back, got %+v", res)
+	}
+	if l.Allow(ClassWrite, "user:1").Allowed {
+		t.Error("Expected the bucket to be empty again")
+	}
+
+	m := l.Metrics()
+	if got := m.Get("allowed.write").String(); got != "5" {
+		t.Errorf("Expected 5 allowed writes, got %s", got)
+	}
+	if got := m.Get("rejected.write").String(); got != "2" {
+		t.Errorf("Expected 2 rejected writes, got %s", got)
+	}
+	if got := m.Get("rejected.write.user").String(); got != "2" {
+		t.Errorf("Expected 2 rejections by user, got %s", got)
+	}
+	if m.Get("rejected.write.token") != nil {
+		t.Error("Expected no rejections by token")
+	}
+
+	// Buckets that have filled up again are forgotten.
+	now = now.Add(time.Hour)
+	l.Allow(ClassWrite, "user:2")
+	if len(l.buckets) != 1 {
+		t.Errorf("Expected only the new bucket to be kept, got %d", len(l.buckets))
+	}
+}
+
+func TestLimiter_Peek(t *testing.T) {
+	l := New(Config{Rates: map[Class]Rate{ClassAuth: {PerSecond: 1, Burst: 2}}})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	// Peeking leaves the token in the bucket.
+	for range 5 {
+		if res := l.Peek(ClassAuth, "ip:192.0.2.1"); !res.Allowed || res.Remaining != 2 {
+			t.Fatalf("Expected a full bucket, got %+v", res)
+		}
+	}
+	l.Allow(ClassAuth, "ip:192.0.2.1")
+	l.Allow(ClassAuth, "ip:192.0.2.1")
+	if res := l.Peek(ClassAuth, "ip:192.0.2.1"); res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("Expected an empty bucket, got %+v", res)
+	}
+	if got := l.Metrics().Get("allowed.auth").String(); got != "2" {
+		t.Errorf("Expected only the 2 requests that took a token to be counted, got %s", got)
+	}
+}
@@ -0,0 +1,85 @@
+package reminder
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the scheduler's source of time, so that tests can control it.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is backed by the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time                         { return time.Now() }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// FakeClock only moves when Advance or Set is called, which makes scheduler tests deterministic.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the fake time once the clock has been advanced by d.
+// A non-positive d fires immediately.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing any waiters whose deadline has been reached.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.fire(now)
+}
+
+// Set moves the clock to t, firing any waiters whose deadline has been reached.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+	c.fire(t)
+}
+
+func (c *FakeClock) fire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.deadline.After(now) {
+			w.ch <- now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
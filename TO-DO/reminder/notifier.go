@@ -0,0 +1,97 @@
+package reminder
+
+import (
+	"GoAcademy/TO-DO/webhook"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// NotifierFunc adapts an ordinary function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, r Reminder) error
+
+func (f NotifierFunc) Notify(ctx context.Context, r Reminder) error { return f(ctx, r) }
+
+// LogNotifier writes reminders to the application log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, r Reminder) error {
+	slog.Default().Log(ctx, slog.LevelInfo, "Reminder", "tenant", r.Tenant, "kind", r.Kind, "id", r.ItemID, "name", r.Item.Name, "due", r.Item.Due)
+	return nil
+}
+
+// WebhookNotifier POSTs the reminder as JSON to URL, signed in the same way as webhook deliveries when Secret is set.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client // defaults to a client with a 10 second timeout
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.EventHeader, "reminder."+r.Kind)
+	if n.Secret != "" {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(n.Secret, body))
+	}
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPNotifier e-mails reminders through the SMTP server at Addr (host:port).
+// Auth may be nil for a local relay or test server.
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (n SMTPNotifier) Notify(ctx context.Context, r Reminder) error {
+	var subject string
+	switch r.Kind {
+	case KindUpcoming:
+		subject = fmt.Sprintf("Reminder: %q is due %s", r.Item.Name, r.Item.Due)
+	case KindDue:
+		subject = fmt.Sprintf("Due today: %q", r.Item.Name)
+	default:
+		subject = fmt.Sprintf("Overdue: %q was due %s", r.Item.Name, r.Item.Due)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "To-do #%d %q is due %s.\r\n", r.ItemID, r.Item.Name, r.Item.Due)
+	if r.Tenant != "" {
+		fmt.Fprintf(&msg, "Tenant: %s\r\n", r.Tenant)
+	}
+
+	return smtp.SendMail(n.Addr, n.Auth, n.From, n.To, []byte(msg.String()))
+}
@@ -0,0 +1,102 @@
+package reminder
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"GoAcademy/TO-DO/webhook"
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testReminder = Reminder{Kind: KindDue, ItemID: 4, Item: todo.Item{ID: 4, Name: "Feed the cat", Due: "15-10-2026"}}
+
+func TestWebhookNotifier(t *testing.T) {
+	var signature, event string
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(webhook.SignatureHeader)
+		event = r.Header.Get(webhook.EventHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	t.Cleanup(receiver.Close)
+
+	n := WebhookNotifier{URL: receiver.URL, Secret: "s3cret"}
+	if err := n.Notify(context.Background(), testReminder); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if event != "reminder.due" {
+		t.Errorf("Expected event header reminder.due, got %q", event)
+	}
+	if signature != webhook.Sign("s3cret", body) {
+		t.Errorf("Signature does not match body")
+	}
+}
+
+// fakeSMTP is a minimal local stand-in for an SMTP server. It accepts a single message and returns it on the channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		reply("220 localhost fake SMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				messages <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default: // MAIL FROM, RCPT TO, RSET...
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+
+	n := SMTPNotifier{Addr: addr, From: "todo@example.com", To: []string{"me@example.com"}}
+	if err := n.Notify(context.Background(), testReminder); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	msg := <-messages
+	if !strings.Contains(msg, `Subject: Due today: "Feed the cat"`) || !strings.Contains(msg, "To: me@example.com") {
+		t.Errorf("Unexpected message:\n%s", msg)
+	}
+}
@@ -0,0 +1,329 @@
+// Package reminder watches item due dates and sends reminders before, at and after the due time.
+//
+// The Scheduler keeps its own copy of the items: it takes a snapshot from the actor when it starts
+// and then follows the actor's change events (todo.Events). It sleeps until the next reminder is due,
+// so it never polls the actor. One scheduler serves the default store and every tenant's store: items are
+// kept by tenant and ID, and a tenant's items are loaded from its store when the scheduler starts and
+// again after the tenant restores a backup.
+package reminder
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of reminder, derived from the sign of the offset that fired.
+const (
+	KindUpcoming = "upcoming" // fired before the due time
+	KindDue      = "due"      // fired at the due time
+	KindOverdue  = "overdue"  // fired after the due time
+)
+
+// DefaultOffsets sends a reminder one day before an item is due, when it becomes due, and one day later if it is still open.
+// Offsets are relative to the start of the due date.
+var DefaultOffsets = []time.Duration{-24 * time.Hour, 0, 24 * time.Hour}
+
+// ParseOffsets parses a comma-separated list of durations such as "-24h,0s,24h".
+func ParseOffsets(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder offset %q: %w", field, err)
+		}
+		offsets = append(offsets, d)
+	}
+	return offsets, nil
+}
+
+// Reminder is sent to notifiers when an offset is reached for an open item.
+type Reminder struct {
+	Tenant string        `json:"tenant,omitempty"` // "" for the default store
+	Kind   string        `json:"kind"`
+	Offset time.Duration `json:"offset"`
+	ItemID int           `json:"item_id"`
+	Item   todo.Item     `json:"item"`
+	DueAt  time.Time     `json:"due_at"`
+	FireAt time.Time     `json:"fire_at"`
+}
+
+// Notifier delivers a reminder somewhere (the log, a webhook, an e-mail...).
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// Config controls the scheduler. Zero values are replaced with defaults.
+type Config struct {
+	Offsets  []time.Duration
+	Clock    Clock
+	Location *time.Location // time zone the DD-MM-YYYY due dates are interpreted in
+	// Tenants are loaded on Start, besides the default store, through Acquire (e.g. tenant.Registry.Acquire),
+	// which is also used to reload a tenant after a restore. Without Acquire only the default store is watched.
+	Tenants []string
+	Acquire func(tenant string, ctx context.Context) (todo.Instance, func(), error)
+}
+
+// itemKey identifies an item; IDs are only unique within a tenant.
+type itemKey struct {
+	tenant string
+	id     int
+}
+
+// firedKey identifies a reminder that has already been sent.
+// Due is part of the key so that moving an item's due date re-arms its reminders.
+type firedKey struct {
+	item   itemKey
+	due    string
+	offset time.Duration
+}
+
+// Scheduler tracks due dates and dispatches reminders to its notifiers.
+type Scheduler struct {
+	offsets   []time.Duration
+	clock     Clock
+	location  *time.Location
+	notifiers []Notifier
+	tenants   []string
+	acquire   func(tenant string, ctx context.Context) (todo.Instance, func(), error)
+
+	// Owned by the run goroutine.
+	items map[itemKey]todo.Item
+	fired map[firedKey]bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(cfg Config, notifiers ...Notifier) *Scheduler {
+	if len(cfg.Offsets) == 0 {
+		cfg.Offsets = DefaultOffsets
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = RealClock{}
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	offsets := append([]time.Duration(nil), cfg.Offsets...)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	return &Scheduler{
+		offsets:   offsets,
+		clock:     cfg.Clock,
+		location:  cfg.Location,
+		notifiers: notifiers,
+		tenants:   cfg.Tenants,
+		acquire:   cfg.Acquire,
+		items:     make(map[itemKey]todo.Item),
+		fired:     make(map[firedKey]bool),
+	}
+}
+
+// Start loads the current items from the actor and runs the scheduler in the background until Stop is called.
+// The actor must already be running.
+func (s *Scheduler) Start(ctx context.Context) error {
+	// Subscribe before taking the snapshot so no change can slip between the two.
+	events, unsubscribe := todo.Events.Subscribe(256)
+
+	if err := s.load(ctx, ""); err != nil {
+		unsubscribe()
+		return err
+	}
+	for _, tenant := range s.tenants {
+		if err := s.load(ctx, tenant); err != nil {
+			unsubscribe()
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "Reminder scheduler started.", "items_count", len(s.items), "offsets", s.offsets)
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer unsubscribe()
+		s.run(ctx, events)
+	}()
+	return nil
+}
+
+// load replaces the scheduler's copy of a tenant's items with its actor's current list.
+func (s *Scheduler) load(ctx context.Context, tenant string) error {
+	store := todo.Store
+	if tenant != "" {
+		if s.acquire == nil {
+			return fmt.Errorf("no way to reach the store of tenant %s", tenant)
+		}
+		inst, release, err := s.acquire(tenant, ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		store = inst.Store
+	}
+	cmd := todo.Command{Action: todo.OpGet, Ctx: ctx, Result: make(chan any), ErrChan: make(chan error)}
+	select {
+	case store <- cmd:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case result := <-cmd.Result:
+		for key := range s.items {
+			if key.tenant == tenant {
+				delete(s.items, key)
+			}
+		}
+		for _, item := range result.([]todo.Item) {
+			s.items[itemKey{tenant, item.ID}] = item
+		}
+		// Forget reminders for items that no longer exist; the fired key includes the due date,
+		// so items whose due date changed are re-armed anyway.
+		for key := range s.fired {
+			if _, ok := s.items[key.item]; !ok && key.item.tenant == tenant {
+				delete(s.fired, key)
+			}
+		}
+		return nil
+	case err := <-cmd.ErrChan:
+		return err
+	}
+}
+
+// Stop ends the scheduler goroutine and waits for it to exit.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context, events <-chan todo.Event) {
+	for {
+		// Apply any changes that are already queued so reminders aren't sent for stale items.
+		for drained := false; !drained; {
+			select {
+			case e := <-events:
+				s.apply(ctx, e)
+			default:
+				drained = true
+			}
+		}
+		s.check(ctx)
+
+		// Sleep until the next reminder is due, or until an item changes.
+		var wake <-chan time.Time
+		if next, ok := s.next(); ok {
+			wake = s.clock.After(next.Sub(s.clock.Now()))
+		}
+		select {
+		case e := <-events:
+			s.apply(ctx, e)
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply updates the scheduler's copy of the items from an actor event.
+func (s *Scheduler) apply(ctx context.Context, e todo.Event) {
+	key := itemKey{e.Tenant, e.ItemID}
+	if e.Type == todo.EventRestored {
+		if err := s.load(ctx, e.Tenant); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to reload items after a restore.", "tenant", e.Tenant, "error", err)
+		}
+		return
+	}
+	if e.Type == todo.EventDeleted {
+		delete(s.items, key)
+		for fired := range s.fired {
+			if fired.item == key {
+				delete(s.fired, fired)
+			}
+		}
+		return
+	}
+	s.items[key] = e.Item
+}
+
+// dueAt interprets the item's DD-MM-YYYY due date as the start of that day.
+func (s *Scheduler) dueAt(item todo.Item) (time.Time, bool) {
+	due, err := time.ParseInLocation(todo.DueLayout, item.Due, s.location)
+	return due, err == nil
+}
+
+// next returns the earliest fire time that hasn't been reached yet.
+func (s *Scheduler) next() (time.Time, bool) {
+	var next time.Time
+	now := s.clock.Now()
+	for key, item := range s.items {
+		due, ok := s.dueAt(item)
+		if item.Completed || !ok {
+			continue
+		}
+		for _, offset := range s.offsets {
+			at := due.Add(offset)
+			if s.fired[firedKey{key, item.Due, offset}] || !at.After(now) {
+				continue
+			}
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+			break // offsets are sorted, so later ones can't be earlier
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// check fires the reminders that have become due. If several offsets of one item have passed
+// (for example after a restart) only the latest is sent, so clients don't get a burst of stale reminders.
+func (s *Scheduler) check(ctx context.Context) {
+	now := s.clock.Now()
+	for key, item := range s.items {
+		due, ok := s.dueAt(item)
+		if item.Completed || !ok {
+			continue
+		}
+
+		var latest *Reminder
+		for _, offset := range s.offsets {
+			at := due.Add(offset)
+			fired := firedKey{key, item.Due, offset}
+			if s.fired[fired] || at.After(now) {
+				continue
+			}
+			s.fired[fired] = true
+			latest = &Reminder{Tenant: key.tenant, Kind: kind(offset), Offset: offset, ItemID: item.ID, Item: item, DueAt: due, FireAt: at}
+		}
+		if latest != nil {
+			s.notify(ctx, *latest)
+		}
+	}
+}
+
+func (s *Scheduler) notify(ctx context.Context, r Reminder) {
+	slog.Default().Log(ctx, slog.LevelInfo, "Firing reminder.", "tenant", r.Tenant, "kind", r.Kind, "id", r.ItemID, "due", r.Item.Due)
+	for _, n := range s.notifiers {
+		if err := n.Notify(ctx, r); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Reminder notifier failed.", "tenant", r.Tenant, "kind", r.Kind, "id", r.ItemID, "error", err)
+		}
+	}
+}
+
+func kind(offset time.Duration) string {
+	switch {
+	case offset < 0:
+		return KindUpcoming
+	case offset == 0:
+		return KindDue
+	default:
+		return KindOverdue
+	}
+}
@@ -0,0 +1,249 @@
+package reminder
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recorder is a Notifier that hands reminders to the test.
+type recorder chan Reminder
+
+func (r recorder) Notify(ctx context.Context, rem Reminder) error {
+	r <- rem
+	return nil
+}
+
+func (r recorder) expect(t *testing.T, kind string, id int) {
+	t.Helper()
+	select {
+	case rem := <-r:
+		if rem.Kind != kind || rem.ItemID != id {
+			t.Errorf("Expected %s reminder for item %d, got %s for item %d", kind, id, rem.Kind, rem.ItemID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for %s reminder for item %d", kind, id)
+	}
+}
+
+func (r recorder) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case rem := <-r:
+		t.Errorf("Unexpected reminder: %+v", rem)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func send(t *testing.T, cmd todo.Command) {
+	t.Helper()
+	cmd.Ctx = context.Background()
+	cmd.Result = make(chan any)
+	cmd.ErrChan = make(chan error)
+	todo.Store <- cmd
+	select {
+	case <-cmd.Result:
+	case err := <-cmd.ErrChan:
+		t.Fatalf("Command failed: %v", err)
+	}
+}
+
+// startScheduler starts a fresh actor and a scheduler using a fake clock set to start.
+func startScheduler(t *testing.T, start time.Time, offsets ...time.Duration) (*FakeClock, recorder) {
+	t.Helper()
+	todo.Store = make(chan todo.Command)
+	todo.StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(todo.Store) })
+
+	clock := NewFakeClock(start)
+	rec := make(recorder, 10)
+	s := NewScheduler(Config{Offsets: offsets, Clock: clock, Location: time.UTC}, rec)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return clock, rec
+}
+
+func TestScheduler_FiresEachOffsetInTurn(t *testing.T) {
+	clock, rec := startScheduler(t, time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC))
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Pay rent", Due: "10-01-2026"}})
+	rec.expectNone(t)
+
+	clock.Set(time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)) // one day before
+	rec.expect(t, KindUpcoming, 1)
+
+	clock.Set(time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)) // due
+	rec.expect(t, KindDue, 1)
+
+	clock.Set(time.Date(2026, 1, 10, 23, 0, 0, 0, time.UTC))
+	rec.expectNone(t)
+
+	clock.Set(time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)) // a day overdue
+	rec.expect(t, KindOverdue, 1)
+
+	clock.Advance(30 * 24 * time.Hour) // each reminder is only sent once
+	rec.expectNone(t)
+}
+
+func TestScheduler_SkipsCompletedAndDeletedItems(t *testing.T) {
+	clock, rec := startScheduler(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Done", Due: "05-01-2026"}})
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Gone", Due: "05-01-2026"}})
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Open", Due: "05-01-2026"}})
+
+	completed := true
+	complete := todo.Command{Action: todo.OpUpdate, ID: 1}
+	complete.UpdatePayload.Completed = &completed
+	send(t, complete)
+	send(t, todo.Command{Action: todo.OpDelete, ID: 2})
+
+	clock.Set(time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC))
+	rec.expect(t, KindDue, 3)
+	rec.expectNone(t)
+}
+
+func TestScheduler_OnlyLatestStaleReminderFires(t *testing.T) {
+	// The item is already a day overdue when the scheduler first sees it.
+	clock, rec := startScheduler(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Late", Due: "01-02-2026"}})
+	rec.expect(t, KindOverdue, 1)
+	clock.Advance(time.Hour)
+	rec.expectNone(t)
+}
+
+func TestScheduler_RearmsWhenDueDateMoves(t *testing.T) {
+	clock, rec := startScheduler(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Moving", Due: "02-01-2026"}})
+
+	clock.Set(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	rec.expect(t, KindDue, 1)
+
+	newDue := "05-01-2026"
+	move := todo.Command{Action: todo.OpUpdate, ID: 1}
+	move.UpdatePayload.Due = &newDue
+	send(t, move)
+	rec.expectNone(t)
+
+	clock.Set(time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC))
+	rec.expect(t, KindDue, 1)
+}
+
+func TestScheduler_ReloadsAfterRestore(t *testing.T) {
+	clock, rec := startScheduler(t, time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC), -24*time.Hour)
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Pay rent", Due: "10-01-2026"}})
+	send(t, todo.Command{Action: todo.OpRestore, Items: []todo.Item{{ID: 5, Name: "Restored", Due: "12-01-2026"}}})
+
+	clock.Set(time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC))
+	rec.expectNone(t) // item 1 was replaced by the restore
+
+	clock.Set(time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC))
+	rec.expect(t, KindUpcoming, 5)
+}
+
+func TestScheduler_Tenants(t *testing.T) {
+	todo.Store = make(chan todo.Command)
+	todo.StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(todo.Store) })
+	acme, err := todo.NewStore(todo.StoreConfig{Filename: filepath.Join(t.TempDir(), "todos.json"), Tenant: "acme"}, context.Background())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	t.Cleanup(func() { close(acme) })
+	sendTo := func(store chan todo.Command, cmd todo.Command) {
+		t.Helper()
+		cmd.Ctx = context.Background()
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		store <- cmd
+		select {
+		case <-cmd.Result:
+		case err := <-cmd.ErrChan:
+			t.Fatalf("Command failed: %v", err)
+		}
+	}
+
+	// Both stores have an item 1, due on different days; acme's is there before the scheduler starts.
+	sendTo(acme, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Acme's rent", Due: "12-01-2026"}})
+	clock := NewFakeClock(time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC))
+	rec := make(recorder, 10)
+	acquire := func(tenant string, ctx context.Context) (todo.Instance, func(), error) {
+		return todo.Instance{Tenant: tenant, Store: acme}, func() {}, nil
+	}
+	s := NewScheduler(Config{Offsets: []time.Duration{0}, Clock: clock, Location: time.UTC, Tenants: []string{"acme"}, Acquire: acquire}, rec)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	send(t, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Pay rent", Due: "10-01-2026"}})
+
+	next := func() Reminder {
+		t.Helper()
+		select {
+		case rem := <-rec:
+			return rem
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for a reminder")
+			return Reminder{}
+		}
+	}
+
+	clock.Set(time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC))
+	if rem := next(); rem.Tenant != "" || rem.Item.Name != "Pay rent" {
+		t.Errorf("Expected the default store's reminder, got %+v", rem)
+	}
+	// A restore in acme reloads only acme's items.
+	sendTo(acme, todo.Command{Action: todo.OpRestore, Items: []todo.Item{{ID: 1, Name: "Acme's restored rent", Due: "13-01-2026"}}})
+	clock.Set(time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC))
+	rec.expectNone(t)
+	clock.Set(time.Date(2026, 1, 13, 0, 0, 0, 0, time.UTC))
+	if rem := next(); rem.Tenant != "acme" || rem.ItemID != 1 || rem.Item.Name != "Acme's restored rent" {
+		t.Errorf("Expected acme's restored item's reminder, got %+v", rem)
+	}
+}
+
+func TestFakeClock_After(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	ch := clock.After(time.Hour)
+
+	clock.Advance(59 * time.Minute)
+	select {
+	case <-ch:
+		t.Fatal("Fired too early")
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("Expected waiter to fire after an hour")
+	}
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("Expected non-positive duration to fire immediately")
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	offsets, err := ParseOffsets("-24h, 0s,90m")
+	if err != nil {
+		t.Fatalf("ParseOffsets failed: %v", err)
+	}
+	want := []time.Duration{-24 * time.Hour, 0, 90 * time.Minute}
+	if len(offsets) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, offsets)
+	}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, offsets)
+		}
+	}
+	if _, err := ParseOffsets("tomorrow"); err == nil {
+		t.Error("Expected an error for an invalid offset")
+	}
+}
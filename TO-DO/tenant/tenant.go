@@ -0,0 +1,389 @@
+// Package tenant gives every tenant its own to-do store.
+//
+// Tenants, and the users who are members of each, are listed in Config.Members; no other tenant exists.
+// A Registry resolves the tenant of a request from the Header or the subdomain, and starts an actor for it
+// (see todo.NewStore) the first time a member needs it, on its own data file and attachments under Config.Dir.
+// Stores that go unused for Config.IdleTimeout are saved and stopped, and started again on the next request.
+// Requests without a tenant use the default store, todo.Store.
+package tenant
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header names the tenant of a request. It takes precedence over the subdomain.
+const Header = "X-Tenant"
+
+// Where each tenant's data is kept, inside its directory under Config.Dir.
+const (
+	DataFile       = "todos.json"
+	AttachmentsDir = "attachments"
+)
+
+// ErrInvalidTenant is returned for tenant names that aren't a single DNS label.
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// ErrUnknownTenant is returned for tenants that aren't in Config.Members.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// ErrNotMember is returned when a user asks for a tenant they aren't a member of.
+var ErrNotMember = errors.New("not a member of the tenant")
+
+var tenantName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Config controls where tenants' data is kept and how long their stores stay running.
+// Zero values are replaced by the defaults below.
+type Config struct {
+	Dir         string         // tenants' data is kept in Dir/<tenant>
+	BaseDomain  string         // requests to <tenant>.<BaseDomain> are for that tenant; empty means only the Header is used
+	IdleTimeout time.Duration  // how long a store may go unused before it is stopped
+	MaxItems    int            // the most items a tenant may have; 0 means no limit
+	Quotas      map[string]int // MaxItems for particular tenants, overriding the one above
+	// Members lists the tenants, with the usernames of the users who may use each. Other tenants don't exist.
+	Members map[string][]string
+}
+
+func (c Config) withDefaults() Config {
+	if c.Dir == "" {
+		c.Dir = "tenants"
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 15 * time.Minute
+	}
+	return c
+}
+
+// Validate checks that name can be used as a tenant: lower-case letters, digits and '-', like a DNS label.
+func Validate(name string) error {
+	if !tenantName.MatchString(name) {
+		return fmt.Errorf("%w: %q must be up to 63 lower-case letters, digits or '-'", ErrInvalidTenant, name)
+	}
+	return nil
+}
+
+// ParseQuotas parses per-tenant item quotas of the form "acme=5000,globex=100".
+func ParseQuotas(s string) (map[string]int, error) {
+	quotas := map[string]int{}
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("quota %q is not of the form tenant=items", part)
+		}
+		if err := Validate(name); err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("quota %q is not a number of items", part)
+		}
+		quotas[name] = n
+	}
+	return quotas, nil
+}
+
+// ParseMembers parses tenants and their members of the form "acme=ana|bob,globex=carol".
+func ParseMembers(s string) (map[string][]string, error) {
+	members := map[string][]string{}
+	for _, part := range strings.Split(s, ",") {
+		name, users, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("members %q are not of the form tenant=user|user", part)
+		}
+		if err := Validate(name); err != nil {
+			return nil, err
+		}
+		for _, u := range strings.Split(users, "|") {
+			if u = auth.NormalizeUsername(u); u != "" {
+				members[name] = append(members[name], u)
+			}
+		}
+		if len(members[name]) == 0 {
+			return nil, fmt.Errorf("tenant %s has no members", name)
+		}
+	}
+	return members, nil
+}
+
+// Registry owns the running tenant stores.
+type Registry struct {
+	cfg Config
+	now func() time.Time
+
+	// mu guards running, but is never held while a store loads or saves its file, so that one slow tenant
+	// doesn't hold up the others. A store stays in running until it has stopped, so a tenant never has two
+	// stores on the same file at once.
+	mu      sync.Mutex
+	running map[string]*store
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// store is a running tenant store.
+type store struct {
+	inst     todo.Instance
+	err      error         // why the store failed to start
+	started  chan struct{} // closed once inst or err is set
+	stopping chan struct{} // set once the store is being stopped, and closed when it has
+	active   int           // requests using the store right now
+	lastUsed time.Time
+}
+
+// NewRegistry creates a registry. No store is started until a request for its tenant comes in.
+func NewRegistry(cfg Config) *Registry {
+	return &Registry{cfg: cfg.withDefaults(), now: time.Now, running: map[string]*store{}}
+}
+
+// Resolve returns the tenant a request is for: the Header if it is set, otherwise the subdomain of
+// Config.BaseDomain the request was sent to. "" means the request is for the default store.
+func (reg *Registry) Resolve(r *http.Request) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(r.Header.Get(Header)))
+	if name == "" && reg.cfg.BaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(reg.cfg.BaseDomain)); ok {
+			name = sub
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Authorize checks that the tenant exists and that the user is one of its members.
+func (reg *Registry) Authorize(name string, u auth.User) error {
+	members, ok := reg.cfg.Members[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTenant, name)
+	}
+	if !slices.Contains(members, u.Username) {
+		return fmt.Errorf("%w: %s is not a member of %s", ErrNotMember, u.Username, name)
+	}
+	return nil
+}
+
+// Tenants returns the names of the tenants in Config.Members, sorted.
+func (reg *Registry) Tenants() []string {
+	return slices.Sorted(maps.Keys(reg.cfg.Members))
+}
+
+// MaxItems returns the item quota of the tenant; 0 means no limit.
+func (reg *Registry) MaxItems(name string) int {
+	if n, ok := reg.cfg.Quotas[name]; ok {
+		return n
+	}
+	return reg.cfg.MaxItems
+}
+
+// Acquire returns the tenant's store, starting it if it isn't running. The store won't be stopped for being idle
+// until release is called; call it once the request is done with the store. Only tenants in Config.Members have
+// a store; check the user with Authorize first.
+func (reg *Registry) Acquire(name string, ctx context.Context) (inst todo.Instance, release func(), err error) {
+	if err := Validate(name); err != nil {
+		return todo.Instance{}, nil, err
+	}
+	if _, ok := reg.cfg.Members[name]; !ok {
+		return todo.Instance{}, nil, fmt.Errorf("%w: %s", ErrUnknownTenant, name)
+	}
+	for {
+		reg.mu.Lock()
+		s, ok := reg.running[name]
+		if ok && s.stopping != nil {
+			// Wait for the store to save its file before starting a new one on it.
+			stopping := s.stopping
+			reg.mu.Unlock()
+			<-stopping
+			continue
+		}
+		if !ok {
+			s = &store{started: make(chan struct{})}
+			reg.running[name] = s
+		}
+		s.active++
+		s.lastUsed = reg.now()
+		reg.mu.Unlock()
+
+		var once sync.Once
+		release = func() {
+			once.Do(func() {
+				reg.mu.Lock()
+				defer reg.mu.Unlock()
+				s.active--
+				s.lastUsed = reg.now()
+			})
+		}
+		if !ok {
+			s.inst, s.err = reg.start(name, ctx)
+			if s.err != nil {
+				reg.mu.Lock()
+				delete(reg.running, name)
+				reg.mu.Unlock()
+			}
+			close(s.started)
+		}
+		<-s.started
+		if s.err != nil {
+			release()
+			return todo.Instance{}, nil, s.err
+		}
+		return s.inst, release, nil
+	}
+}
+
+// start starts the tenant's store on its own directory.
+func (reg *Registry) start(name string, ctx context.Context) (todo.Instance, error) {
+	dir := filepath.Join(reg.cfg.Dir, name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return todo.Instance{}, fmt.Errorf("could not create directory for tenant %s: %w", name, err)
+	}
+	cfg := todo.StoreConfig{
+		Filename: filepath.Join(dir, DataFile),
+		Blobs:    &todo.BlobStore{Dir: filepath.Join(dir, AttachmentsDir)},
+		MaxItems: reg.MaxItems(name),
+		Tenant:   name,
+	}
+	ch, err := todo.NewStore(cfg, ctx)
+	if err != nil {
+		return todo.Instance{}, fmt.Errorf("could not start store for tenant %s: %w", name, err)
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "Tenant store started.", "tenant", name, "file", cfg.Filename)
+	return todo.Instance{Tenant: name, Store: ch, Blobs: cfg.Blobs}, nil
+}
+
+// Running returns the names of the tenants whose stores are running, sorted.
+func (reg *Registry) Running() []string {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return slices.Sorted(maps.Keys(reg.running))
+}
+
+// EvictIdle saves and stops the stores that no request has used for Config.IdleTimeout, and returns how many it stopped.
+func (reg *Registry) EvictIdle(ctx context.Context) int {
+	reg.mu.Lock()
+	idle := map[string]*store{}
+	for name, s := range reg.running {
+		// A store being started is in use by the request starting it.
+		if s.stopping == nil && s.active == 0 && reg.now().Sub(s.lastUsed) >= reg.cfg.IdleTimeout {
+			s.stopping = make(chan struct{})
+			idle[name] = s
+		}
+	}
+	reg.mu.Unlock()
+
+	for name, err := range reg.stop(idle, ctx) {
+		// A store that fails to save is stopped all the same; its actor has exited.
+		if err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to save idle tenant store.", "tenant", name, "error", err)
+		} else {
+			slog.Default().Log(ctx, slog.LevelInfo, "Idle tenant store stopped.", "tenant", name)
+		}
+	}
+	return len(idle)
+}
+
+// stop saves and stops stores already marked as stopping, and returns the error of each by tenant.
+func (reg *Registry) stop(stores map[string]*store, ctx context.Context) map[string]error {
+	errs := make(map[string]error, len(stores))
+	for name, s := range stores {
+		errs[name] = shutdown(s.inst, ctx)
+		reg.mu.Lock()
+		delete(reg.running, name)
+		reg.mu.Unlock()
+		close(s.stopping)
+	}
+	return errs
+}
+
+// Start stops idle stores in the background until Stop is called.
+func (reg *Registry) Start(ctx context.Context) {
+	ctx, reg.cancel = context.WithCancel(ctx)
+	interval := min(reg.cfg.IdleTimeout, time.Minute)
+	reg.wg.Add(1)
+	go func() {
+		defer reg.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reg.EvictIdle(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background eviction and saves and stops every running store, whether or not it is in use.
+// Call it once the server has stopped taking requests.
+func (reg *Registry) Stop(ctx context.Context) error {
+	if reg.cancel != nil {
+		reg.cancel()
+	}
+	reg.wg.Wait()
+
+	reg.mu.Lock()
+	all := map[string]*store{}
+	var evicting []chan struct{}
+	for name, s := range reg.running {
+		if s.stopping == nil {
+			s.stopping = make(chan struct{})
+			all[name] = s
+		} else {
+			evicting = append(evicting, s.stopping)
+		}
+	}
+	reg.mu.Unlock()
+	for _, stopping := range evicting {
+		<-stopping
+	}
+
+	var errs []error
+	for name, s := range all {
+		<-s.started
+		if s.err != nil {
+			delete(all, name) // it never started, and Acquire removes it
+			close(s.stopping)
+		}
+	}
+	for name, err := range reg.stop(all, ctx) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdown sends OpShutdown to a store and waits for it to save its data.
+func shutdown(inst todo.Instance, ctx context.Context) error {
+	cmd := todo.Command{Action: todo.OpShutdown, Ctx: ctx, Result: make(chan any), ErrChan: make(chan error)}
+	inst.Store <- cmd
+	select {
+	case <-cmd.Result:
+		return nil
+	case err := <-cmd.ErrChan:
+		return err
+	}
+}
@@ -0,0 +1,217 @@
+package tenant
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Resolve(t *testing.T) {
+	reg := NewRegistry(Config{Dir: t.TempDir(), BaseDomain: "todo.example.com"})
+	for _, tc := range []struct {
+		host, header, want string
+		ok                 bool
+	}{
+		{"acme.todo.example.com", "", "acme", true},
+		{"ACME.todo.example.com:8080", "", "acme", true},
+		{"acme.todo.example.com", "globex", "globex", true}, // the header wins
+		{"todo.example.com", "", "", true},
+		{"localhost:8080", "", "", true},
+		{"a.b.todo.example.com", "", "", false},
+		{"localhost", "../etc", "", false},
+	} {
+		r := httptest.NewRequest("GET", "/get", nil)
+		r.Host = tc.host
+		if tc.header != "" {
+			r.Header.Set(Header, tc.header)
+		}
+		got, err := reg.Resolve(r)
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("Resolve(%s, %q): expected %q (ok %v), got %q, %v", tc.host, tc.header, tc.want, tc.ok, got, err)
+		}
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := ParseQuotas("acme=5000, globex=0")
+	if err != nil || quotas["acme"] != 5000 || quotas["globex"] != 0 {
+		t.Errorf("Unexpected quotas %v, %v", quotas, err)
+	}
+	for _, s := range []string{"acme", "acme=lots", "acme=-1", "Bad Name=1"} {
+		if _, err := ParseQuotas(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestParseMembers(t *testing.T) {
+	members, err := ParseMembers("acme=Ana|bob, globex=carol")
+	if err != nil || !slices.Equal(members["acme"], []string{"ana", "bob"}) || !slices.Equal(members["globex"], []string{"carol"}) {
+		t.Errorf("Unexpected members %v, %v", members, err)
+	}
+	for _, s := range []string{"acme", "acme=", "Bad Name=ana"} {
+		if _, err := ParseMembers(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestRegistry_Authorize(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(Config{Dir: dir, Members: map[string][]string{"acme": {"ana"}}})
+	if err := reg.Authorize("acme", auth.User{ID: "1", Username: "ana"}); err != nil {
+		t.Errorf("Expected ana to be a member of acme, got %v", err)
+	}
+	if err := reg.Authorize("acme", auth.User{ID: "2", Username: "bob"}); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected bob not to be a member, got %v", err)
+	}
+	if err := reg.Authorize("globex", auth.User{ID: "1", Username: "ana"}); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("Expected globex to be unknown, got %v", err)
+	}
+	// Unknown tenants never get a store, or a directory.
+	if _, _, err := reg.Acquire("globex", context.Background()); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("Expected Acquire to refuse an unknown tenant, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "globex")); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory for an unknown tenant, got %v", err)
+	}
+}
+
+func TestRegistry_Stores(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(Config{Dir: dir, IdleTimeout: time.Minute, MaxItems: 2, Quotas: map[string]int{"globex": 1},
+		Members: map[string][]string{"acme": {"ana"}, "globex": {"ana"}}})
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	reg.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	send := func(inst todo.Instance, cmd todo.Command) (any, error) {
+		cmd.Ctx = ctx
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		inst.Store <- cmd
+		select {
+		case result := <-cmd.Result:
+			return result, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+	add := func(inst todo.Instance, name string) error {
+		_, err := send(inst, todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: name, Due: "01-05-2026"}})
+		return err
+	}
+
+	acme, releaseAcme, err := reg.Acquire("acme", ctx)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	globex, releaseGlobex, _ := reg.Acquire("globex", ctx)
+	if acme.Store == globex.Store || acme.Store == todo.Store {
+		t.Fatalf("Expected every tenant to get its own store")
+	}
+
+	// Each tenant has its own items, numbered from 1, and its own quota.
+	if err := add(acme, "Acme's task"); err != nil {
+		t.Fatalf("Adding failed: %v", err)
+	}
+	add(acme, "Another one")
+	if err := add(acme, "One too many"); !errors.Is(err, todo.ErrItemQuotaExceeded) {
+		t.Errorf("Expected acme's quota of 2 to be enforced, got %v", err)
+	}
+	add(globex, "Globex's task")
+	if err := add(globex, "One too many"); !errors.Is(err, todo.ErrItemQuotaExceeded) {
+		t.Errorf("Expected globex's quota of 1 to be enforced, got %v", err)
+	}
+	result, _ := send(globex, todo.Command{Action: todo.OpGet})
+	if items := result.([]todo.Item); len(items) != 1 || items[0].ID != 1 || items[0].Name != "Globex's task" {
+		t.Errorf("Expected globex to see only its own item, got %+v", items)
+	}
+
+	// Stores in use, or used recently, are left running.
+	releaseGlobex()
+	now = now.Add(2 * time.Minute)
+	if n := reg.EvictIdle(ctx); n != 1 || !slices.Equal(reg.Running(), []string{"acme"}) {
+		t.Errorf("Expected only globex to be evicted, got %d, running %v", n, reg.Running())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "globex", DataFile)); err != nil {
+		t.Errorf("Expected globex's data to be saved when it was evicted: %v", err)
+	}
+	releaseAcme()
+	releaseAcme() // releasing twice is harmless
+
+	// An evicted store starts again, with its data, on the next request.
+	globex, releaseGlobex, _ = reg.Acquire("globex", ctx)
+	defer releaseGlobex()
+	result, _ = send(globex, todo.Command{Action: todo.OpGet})
+	if items := result.([]todo.Item); len(items) != 1 || items[0].Name != "Globex's task" {
+		t.Errorf("Expected globex's item to be reloaded, got %+v", items)
+	}
+
+	if err := reg.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if len(reg.Running()) != 0 {
+		t.Errorf("Expected Stop to stop every store, got %v", reg.Running())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "acme", DataFile)); err != nil {
+		t.Errorf("Expected acme's data to be saved on Stop: %v", err)
+	}
+}
+
+func TestRegistry_EvictWhileAcquiring(t *testing.T) {
+	reg := NewRegistry(Config{Dir: t.TempDir(), IdleTimeout: time.Nanosecond, Members: map[string][]string{"acme": {"ana"}}})
+	ctx := context.Background()
+	const adds = 20
+
+	// Stores are saved and stopped while other requests start them again; no item may be lost on the way.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < adds; i++ {
+			inst, release, err := reg.Acquire("acme", ctx)
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			cmd := todo.Command{Action: todo.OpAdd, Item: todo.Item{Name: "Task", Due: "01-05-2026"}, Ctx: ctx,
+				Result: make(chan any), ErrChan: make(chan error)}
+			inst.Store <- cmd
+			select {
+			case <-cmd.Result:
+			case err := <-cmd.ErrChan:
+				t.Errorf("Adding failed: %v", err)
+			}
+			release()
+		}
+	}()
+	for evicting := true; evicting; {
+		select {
+		case <-done:
+			evicting = false
+		default:
+			reg.EvictIdle(ctx)
+		}
+	}
+
+	inst, release, err := reg.Acquire("acme", ctx)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+	cmd := todo.Command{Action: todo.OpGet, Ctx: ctx, Result: make(chan any), ErrChan: make(chan error)}
+	inst.Store <- cmd
+	if items := (<-cmd.Result).([]todo.Item); len(items) != adds {
+		t.Errorf("Expected %d items, got %d", adds, len(items))
+	}
+	if err := reg.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
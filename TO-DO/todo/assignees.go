@@ -0,0 +1,59 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxAssignees is the most users an item can be assigned to.
+const MaxAssignees = 20
+
+// ErrInvalidAssignee is returned when assigning an item to a user who can't see it, or to too many users.
+var ErrInvalidAssignee = errors.New("invalid assignee")
+
+// AssignToDo replaces the assignees of the item with the given ID, and returns the usernames that were added
+// and removed. Assignees are kept sorted and without duplicates.
+func AssignToDo(toDos []Item, id int, usernames []string) (updated []Item, added, removed []string, err error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, nil, nil, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	assignees := slices.Compact(slices.Sorted(slices.Values(usernames)))
+	if len(assignees) > MaxAssignees {
+		return toDos, nil, nil, fmt.Errorf("%w: an item can have at most %d assignees", ErrInvalidAssignee, MaxAssignees)
+	}
+	if slices.Contains(assignees, "") {
+		return toDos, nil, nil, fmt.Errorf("%w: empty username", ErrInvalidAssignee)
+	}
+	for _, name := range assignees {
+		if !slices.Contains(toDos[i].Assignees, name) {
+			added = append(added, name)
+		}
+	}
+	for _, name := range toDos[i].Assignees {
+		if !slices.Contains(assignees, name) {
+			removed = append(removed, name)
+		}
+	}
+	if len(assignees) == 0 {
+		assignees = nil
+	}
+	toDos[i].Assignees = assignees
+	toDos[i].UpdatedAt = time.Now().UTC()
+	return toDos, added, removed, nil
+}
+
+// IsAssigned reports whether the item is assigned to the user with the given username.
+func IsAssigned(item Item, username string) bool {
+	return slices.Contains(item.Assignees, username)
+}
+
+// validateAssignees checks the assignees of an item from a backup.
+func validateAssignees(assignees []string) error {
+	if len(assignees) > MaxAssignees || slices.Contains(assignees, "") {
+		return fmt.Errorf("%w: at most %d non-empty usernames", ErrInvalidAssignee, MaxAssignees)
+	}
+	return nil
+}
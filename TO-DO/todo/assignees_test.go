@@ -0,0 +1,98 @@
+package todo
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAssignToDo(t *testing.T) {
+	toDos := []Item{{ID: 1, Name: "Paint the fence", Assignees: []string{"ana", "bob"}}}
+	toDos, added, removed, err := AssignToDo(toDos, 1, []string{"eve", "bob", "eve"})
+	if err != nil {
+		t.Fatalf("AssignToDo failed: %v", err)
+	}
+	if !slices.Equal(toDos[0].Assignees, []string{"bob", "eve"}) || !slices.Equal(added, []string{"eve"}) || !slices.Equal(removed, []string{"ana"}) {
+		t.Errorf("Unexpected assignees %v, added %v, removed %v", toDos[0].Assignees, added, removed)
+	}
+	if toDos, _, removed, _ = AssignToDo(toDos, 1, nil); toDos[0].Assignees != nil || len(removed) != 2 {
+		t.Errorf("Expected everyone to be unassigned, got %v (removed %v)", toDos[0].Assignees, removed)
+	}
+
+	if _, _, _, err := AssignToDo(toDos, 1, []string{""}); !errors.Is(err, ErrInvalidAssignee) {
+		t.Errorf("Expected an empty username to be rejected, got %v", err)
+	}
+	many := make([]string, MaxAssignees+1)
+	for i := range many {
+		many[i] = strings.Repeat("a", i+1)
+	}
+	if _, _, _, err := AssignToDo(toDos, 1, many); !errors.Is(err, ErrInvalidAssignee) {
+		t.Errorf("Expected too many assignees to be rejected, got %v", err)
+	}
+	if _, _, _, err := AssignToDo(toDos, 2, []string{"ana"}); err == nil {
+		t.Errorf("Expected a missing item to be an error")
+	}
+}
+
+func TestStartStore_Assign(t *testing.T) {
+	events, unsubscribe := Events.Subscribe(20)
+	t.Cleanup(unsubscribe)
+
+	Store = make(chan Command)
+	StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(Store) })
+
+	ana := auth.User{ID: "1", Username: "ana"}
+	bob := auth.User{ID: "2", Username: "bob"}
+	ctx := auth.WithUser(context.Background(), ana)
+	send := func(cmd Command) (any, error) {
+		cmd.Ctx = ctx
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		Store <- cmd
+		select {
+		case result := <-cmd.Result:
+			return result, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+
+	send(Command{Action: OpAdd, Item: Item{Name: "Paint the fence", Due: "01-05-2026"}})
+
+	// bob can't be given an item he can't see.
+	if _, err := send(Command{Action: OpAssign, ID: 1, Assignees: []auth.User{bob}}); !errors.Is(err, ErrInvalidAssignee) {
+		t.Errorf("Expected assigning an invisible item to be rejected, got %v", err)
+	}
+	if _, err := send(Command{Action: OpShare, ID: 1, Share: Share{UserID: bob.ID, Role: RoleViewer}}); err != nil {
+		t.Fatalf("Sharing failed: %v", err)
+	}
+	result, err := send(Command{Action: OpAssign, ID: 1, Assignees: []auth.User{ana, bob}})
+	if err != nil {
+		t.Fatalf("Assigning failed: %v", err)
+	}
+	if item := result.(Item); !slices.Equal(item.Assignees, []string{"ana", "bob"}) {
+		t.Errorf("Unexpected assignees %v", item.Assignees)
+	}
+	if _, err := send(Command{Action: OpAssign, ID: 1, Assignees: []auth.User{bob}}); err != nil {
+		t.Fatalf("Unassigning failed: %v", err)
+	}
+
+	var got []string
+	for e := range events {
+		switch e.Type {
+		case EventAssigned, EventUnassigned:
+			got = append(got, string(e.Type)+" "+e.Assignee)
+		}
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []string{"assigned ana", "assigned bob", "unassigned ana"}; !slices.Equal(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,294 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Attachment describes a file attached to an item. The file itself is kept in Blobs under its SHA-256,
+// so identical files attached to several items are only stored once.
+type Attachment struct {
+	ID         string    `json:"id"`
+	Filename   string    `json:"filename"`
+	Size       int64     `json:"size"`
+	MIMEType   string    `json:"mime_type"`
+	SHA256     string    `json:"sha256"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// AttachmentQuota limits how much can be attached. A zero limit means no limit.
+type AttachmentQuota struct {
+	MaxFileSize  int64 // bytes per file
+	MaxItemSize  int64 // bytes attached to one item
+	MaxItemFiles int   // files attached to one item
+	MaxTotalSize int64 // bytes stored for all items together (each distinct file counted once)
+}
+
+// Quota is the AttachmentQuota enforced by the actor and the upload endpoint.
+var Quota = AttachmentQuota{
+	MaxFileSize:  10 << 20, // 10 MB
+	MaxItemSize:  50 << 20, // 50 MB
+	MaxItemFiles: 20,
+	MaxTotalSize: 1 << 30, // 1 GB
+}
+
+// ErrQuotaExceeded is returned (wrapped) when an upload or attach would go over Quota.
+var ErrQuotaExceeded = errors.New("attachment quota exceeded")
+
+// Blobs is where attachment contents are stored.
+var Blobs = &BlobStore{Dir: "attachments"}
+
+// orphanGracePeriod is how old an unreferenced blob must be before a sweep deletes it,
+// so that a file that has just been uploaded but not yet attached is left alone.
+const orphanGracePeriod = time.Hour
+
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// BlobStore is a content-addressed directory: each file is stored as Dir/<first 2 hex digits>/<sha256>.
+type BlobStore struct {
+	Dir string
+}
+
+func (b *BlobStore) path(sum string) (string, error) {
+	// The sum ends up in a file path, so anything but a SHA-256 (e.g. from a tampered backup) is refused.
+	if !sha256Hex.MatchString(sum) {
+		return "", fmt.Errorf("invalid sha256 %q", sum)
+	}
+	return filepath.Join(b.Dir, sum[:2], sum), nil
+}
+
+// Put stores the contents of r and returns its SHA-256, size and sniffed MIME type.
+// If r holds more than limit bytes nothing is stored and an error wrapping ErrQuotaExceeded is returned.
+func (b *BlobStore) Put(r io.Reader, limit int64) (sum string, size int64, mimeType string, err error) {
+	if err := os.MkdirAll(b.Dir, 0750); err != nil {
+		return "", 0, "", err
+	}
+	tmp, err := os.CreateTemp(b.Dir, ".upload-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename has succeeded
+	defer tmp.Close()
+
+	hash := sha256.New()
+	head := &headBuffer{max: 512} // http.DetectContentType looks at the first 512 bytes at most
+	src := r
+	if limit > 0 {
+		src = io.LimitReader(r, limit+1)
+	}
+	size, err = io.Copy(io.MultiWriter(tmp, hash, head), src)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if limit > 0 && size > limit {
+		return "", 0, "", fmt.Errorf("%w: files may be at most %d bytes", ErrQuotaExceeded, limit)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, "", err
+	}
+
+	sum = hex.EncodeToString(hash.Sum(nil))
+	dst, _ := b.path(sum)
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return "", 0, "", err
+	}
+	// If the blob already exists it has the same contents, so replacing it is harmless.
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, "", err
+	}
+	return sum, size, http.DetectContentType(head.Bytes()), nil
+}
+
+// Open opens the blob with the given SHA-256 for reading.
+func (b *BlobStore) Open(sum string) (*os.File, error) {
+	p, err := b.path(sum)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Exists reports whether the blob with the given SHA-256 is stored.
+func (b *BlobStore) Exists(sum string) bool {
+	p, err := b.path(sum)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+// Delete removes a blob. Deleting a blob that isn't stored is not an error.
+func (b *BlobStore) Delete(sum string) error {
+	p, err := b.path(sum)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	os.Remove(filepath.Dir(p)) // only succeeds once the directory is empty
+	return nil
+}
+
+// Sweep deletes blobs that no item refers to and that were last written more than olderThan ago.
+// It returns the number of blobs deleted.
+func (b *BlobStore) Sweep(items []Item, olderThan time.Duration) (int, error) {
+	referenced := referencedBlobs(items)
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	err := filepath.WalkDir(b.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == b.Dir {
+				return fs.SkipAll // nothing has been uploaded yet
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+		// Interrupted uploads leave .upload-* temp files behind; those are cleaned up too.
+		if !referenced[d.Name()] {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// referencedBlobs returns the set of SHA-256s attached to any of the items.
+func referencedBlobs(items []Item) map[string]bool {
+	refs := make(map[string]bool)
+	for _, item := range items {
+		for _, a := range item.Attachments {
+			refs[a.SHA256] = true
+		}
+	}
+	return refs
+}
+
+// deleteOrphans deletes the blobs of the given attachments that none of the items refer to any more,
+// from the Blobs of ctx's Instance.
+func deleteOrphans(items []Item, attachments []Attachment, ctx context.Context) {
+	refs := referencedBlobs(items)
+	for _, a := range attachments {
+		if refs[a.SHA256] {
+			continue
+		}
+		if err := InstanceFrom(ctx).Blobs.Delete(a.SHA256); err != nil {
+			slog.Default().Log(ctx, slog.LevelError, "Failed to delete orphaned attachment blob", "sha256", a.SHA256, "error", err)
+			continue
+		}
+		slog.Default().Log(ctx, slog.LevelInfo, "Deleted orphaned attachment blob", "sha256", a.SHA256)
+	}
+}
+
+// checkAttachQuota reports whether attaching a to the item with the given ID stays within Quota.
+func checkAttachQuota(items []Item, id int, a Attachment) error {
+	var itemSize, totalSize int64
+	itemFiles := 0
+	seen := map[string]bool{a.SHA256: true}
+	totalSize = a.Size
+	for _, item := range items {
+		for _, existing := range item.Attachments {
+			if item.ID == id {
+				itemSize += existing.Size
+				itemFiles++
+			}
+			if !seen[existing.SHA256] {
+				seen[existing.SHA256] = true
+				totalSize += existing.Size
+			}
+		}
+	}
+	switch {
+	case Quota.MaxFileSize > 0 && a.Size > Quota.MaxFileSize:
+		return fmt.Errorf("%w: files may be at most %d bytes", ErrQuotaExceeded, Quota.MaxFileSize)
+	case Quota.MaxItemFiles > 0 && itemFiles+1 > Quota.MaxItemFiles:
+		return fmt.Errorf("%w: an item may have at most %d attachments", ErrQuotaExceeded, Quota.MaxItemFiles)
+	case Quota.MaxItemSize > 0 && itemSize+a.Size > Quota.MaxItemSize:
+		return fmt.Errorf("%w: an item may have at most %d bytes of attachments", ErrQuotaExceeded, Quota.MaxItemSize)
+	case Quota.MaxTotalSize > 0 && totalSize > Quota.MaxTotalSize:
+		return fmt.Errorf("%w: the attachment store is full (%d bytes)", ErrQuotaExceeded, Quota.MaxTotalSize)
+	}
+	return nil
+}
+
+// AttachToDo adds an attachment, whose blob must already be in the Blobs of ctx's Instance, to the item with the given ID.
+func AttachToDo(toDos []Item, id int, a Attachment, ctx context.Context) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	if err := checkAttachQuota(toDos, id, a); err != nil {
+		return toDos, err
+	}
+	// A concurrent delete may have removed the blob since it was uploaded.
+	if !InstanceFrom(ctx).Blobs.Exists(a.SHA256) {
+		return toDos, fmt.Errorf("attachment contents %s are missing; upload the file again", a.SHA256)
+	}
+	toDos[i].Attachments = append(toDos[i].Attachments, a)
+	toDos[i].UpdatedAt = time.Now().UTC()
+	slog.Default().Log(ctx, slog.LevelInfo, "Attachment added", "id", id, "attachment_id", a.ID, "filename", a.Filename, "size", a.Size)
+	return toDos, nil
+}
+
+// DetachToDo removes an attachment from the item with the given ID, deleting its blob if nothing else refers to it.
+func DetachToDo(toDos []Item, id int, attachmentID string, ctx context.Context) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	for j, a := range toDos[i].Attachments {
+		if a.ID == attachmentID {
+			// Build a new slice rather than shifting in place, since snapshots handed out by OpGet share the old one.
+			toDos[i].Attachments = append(toDos[i].Attachments[:j:j], toDos[i].Attachments[j+1:]...)
+			toDos[i].UpdatedAt = time.Now().UTC()
+			deleteOrphans(toDos, []Attachment{a}, ctx)
+			slog.Default().Log(ctx, slog.LevelInfo, "Attachment removed", "id", id, "attachment_id", attachmentID)
+			return toDos, nil
+		}
+	}
+	return toDos, fmt.Errorf("attachment %s %w on item %d", attachmentID, ErrNotFound, id)
+}
+
+func indexOf(toDos []Item, id int) int {
+	for i, item := range toDos {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// headBuffer keeps the first max bytes written to it.
+type headBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if room := h.max - h.Len(); room > 0 {
+		h.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,135 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempBlobs points Blobs at a fresh directory for the duration of the test.
+func useTempBlobs(t *testing.T) {
+	t.Helper()
+	old := Blobs
+	Blobs = &BlobStore{Dir: t.TempDir()}
+	t.Cleanup(func() { Blobs = old })
+}
+
+func TestBlobStore_Put(t *testing.T) {
+	useTempBlobs(t)
+
+	sum, size, mimeType, err := Blobs.Put(strings.NewReader("hello"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" || size != 5 || !strings.HasPrefix(mimeType, "text/plain") {
+		t.Errorf("Unexpected blob %s, %d bytes, %s", sum, size, mimeType)
+	}
+	if _, err := os.Stat(filepath.Join(Blobs.Dir, "2c", sum)); err != nil {
+		t.Errorf("Expected the blob under its hash: %v", err)
+	}
+
+	// The same contents are stored once.
+	again, _, _, err := Blobs.Put(strings.NewReader("hello"), 10)
+	if err != nil || again != sum {
+		t.Errorf("Expected the same blob, got %s, %v", again, err)
+	}
+
+	if _, _, _, err := Blobs.Put(strings.NewReader("far too long"), 10); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	entries, _ := os.ReadDir(Blobs.Dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected a rejected upload to leave nothing behind, got %d entries", len(entries))
+	}
+
+	if _, err := Blobs.Open("../../etc/passwd"); err == nil {
+		t.Error("Expected an invalid sha256 to be refused")
+	}
+}
+
+func TestRemoveToDo_DeletesOrphanedBlobs(t *testing.T) {
+	useTempBlobs(t)
+	ctx := context.Background()
+
+	shared, _, _, _ := Blobs.Put(strings.NewReader("shared"), 0)
+	own, _, _, _ := Blobs.Put(strings.NewReader("own"), 0)
+	toDos := []Item{{ID: 1, Name: "One", Due: "01-01-2026"}, {ID: 2, Name: "Two", Due: "01-01-2026"}}
+	var err error
+	for _, step := range []struct {
+		id  int
+		sum string
+	}{{1, shared}, {1, own}, {2, shared}} {
+		toDos, err = AttachToDo(toDos, step.id, Attachment{ID: step.sum[:8] + "-" + string(rune('0'+step.id)), SHA256: step.sum, Size: 3}, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	toDos, err = RemoveToDo(toDos, 1, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Blobs.Exists(own) {
+		t.Error("Expected the blob only item 1 used to be deleted")
+	}
+	if !Blobs.Exists(shared) {
+		t.Error("Expected the blob item 2 still uses to be kept")
+	}
+
+	toDos, err = DetachToDo(toDos, 2, toDos[0].Attachments[0].ID, ctx)
+	if err != nil || len(toDos[0].Attachments) != 0 || Blobs.Exists(shared) {
+		t.Errorf("Expected detaching the last reference to delete the blob: %v", err)
+	}
+}
+
+func TestAttachToDo_Quota(t *testing.T) {
+	useTempBlobs(t)
+	old := Quota
+	Quota = AttachmentQuota{MaxItemFiles: 1, MaxTotalSize: 10}
+	t.Cleanup(func() { Quota = old })
+	ctx := context.Background()
+
+	a, _, _, _ := Blobs.Put(strings.NewReader("a"), 0)
+	b, _, _, _ := Blobs.Put(strings.NewReader("b"), 0)
+	toDos := []Item{{ID: 1, Name: "One", Due: "01-01-2026"}, {ID: 2, Name: "Two", Due: "01-01-2026"}}
+	toDos, err := AttachToDo(toDos, 1, Attachment{ID: "x", SHA256: a, Size: 6}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AttachToDo(toDos, 1, Attachment{ID: "y", SHA256: b, Size: 1}, ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the per-item file limit to apply, got %v", err)
+	}
+	if _, err := AttachToDo(toDos, 2, Attachment{ID: "z", SHA256: b, Size: 6}, ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the total size limit to apply, got %v", err)
+	}
+	// The same file again takes no more space.
+	if _, err := AttachToDo(toDos, 2, Attachment{ID: "z", SHA256: a, Size: 6}, ctx); err != nil {
+		t.Errorf("Expected a file already stored not to count twice, got %v", err)
+	}
+}
+
+func TestBlobStore_Sweep(t *testing.T) {
+	useTempBlobs(t)
+
+	kept, _, _, _ := Blobs.Put(strings.NewReader("kept"), 0)
+	orphan, _, _, _ := Blobs.Put(strings.NewReader("orphan"), 0)
+	fresh, _, _, _ := Blobs.Put(strings.NewReader("fresh"), 0)
+	old := time.Now().Add(-2 * orphanGracePeriod)
+	for _, sum := range []string{kept, orphan} {
+		p, _ := Blobs.path(sum)
+		os.Chtimes(p, old, old)
+	}
+
+	items := []Item{{ID: 1, Attachments: []Attachment{{ID: "a", SHA256: kept}}}}
+	removed, err := Blobs.Sweep(items, orphanGracePeriod)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected one blob to be swept, got %d, %v", removed, err)
+	}
+	if !Blobs.Exists(kept) || Blobs.Exists(orphan) || !Blobs.Exists(fresh) {
+		t.Error("Expected only the old unreferenced blob to be deleted")
+	}
+}
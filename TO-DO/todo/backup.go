@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WriteBackup writes a snapshot of the list, the workflows and the custom fields, as returned by OpSnapshot.
+func WriteBackup(w io.Writer, snap Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// ReadBackup reads a backup written by this or any earlier version, migrating it to SchemaVersion.
+// It returns the snapshot and the version the backup was written in. Version 0 is a plain copy of an old todos.json.
+// Invalid items are reported in the returned []RowError, numbered from 1 in the order they appear.
+func ReadBackup(r io.Reader) (Snapshot, int, []RowError, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Snapshot{}, 0, nil, fmt.Errorf("could not read backup: %w", err)
+	}
+	snap, from, err := decodeSnapshot(data)
+	if err != nil {
+		return Snapshot{}, from, nil, fmt.Errorf("invalid backup: %w", err)
+	}
+	for list, wf := range snap.Workflows {
+		if err := wf.Validate(); err != nil {
+			return Snapshot{}, from, nil, fmt.Errorf("invalid backup: workflow for list %q: %w", list, err)
+		}
+	}
+	for list, defs := range snap.Fields {
+		if err := ValidateFieldDefs(defs); err != nil {
+			return Snapshot{}, from, nil, fmt.Errorf("invalid backup: custom fields for list %q: %w", list, err)
+		}
+	}
+	for list, access := range snap.Lists {
+		err := validateShares(access.Shares)
+		if access.Owner == "" {
+			err = errors.New("no owner")
+		}
+		if err != nil {
+			return Snapshot{}, from, nil, fmt.Errorf("invalid backup: access to list %q: %w", list, err)
+		}
+	}
+	return snap, from, validateSnapshotItems(snap.Items, snap.Workflows, snap.Fields), nil
+}
+
+// validateSnapshotItems checks that stored items are valid, that their IDs are positive and unique,
+// that each is in a status of its workflow and that their custom field values suit their list's fields.
+func validateSnapshotItems(items []Item, workflows map[string]Workflow, fields map[string][]FieldDef) []RowError {
+	var rowErrs []RowError
+	seen := make(map[int]bool, len(items))
+	for i, item := range items {
+		err := ValidateItem(item)
+		switch {
+		case err != nil:
+		case item.ID <= 0:
+			err = fmt.Errorf("invalid id %d", item.ID)
+		case seen[item.ID]:
+			err = fmt.Errorf("duplicate id %d", item.ID)
+		case !WorkflowFor(workflows, item).Has(item.Status):
+			err = fmt.Errorf("%w %q for list %q", ErrUnknownStatus, item.Status, ListOf(item))
+		default:
+			_, fieldErr := normalizeFields(FieldsFor(fields, item), item.Fields)
+			err = errors.Join(fieldErr, validateAttachments(item.Attachments), validateComments(item.Comments), validateTimeEntries(item.TimeEntries), validateShares(item.Shares), validateAssignees(item.Assignees))
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Row: i + 1, Error: err.Error()})
+		}
+		seen[item.ID] = true
+	}
+	return rowErrs
+}
+
+// validateAttachments checks attachment metadata from a backup. Only the metadata is backed up, so the
+// blobs themselves are not required to exist, but the SHA-256s must be usable as blob names.
+func validateAttachments(attachments []Attachment) error {
+	for _, a := range attachments {
+		switch {
+		case a.ID == "":
+			return fmt.Errorf("attachment without an id")
+		case !sha256Hex.MatchString(a.SHA256):
+			return fmt.Errorf("attachment %s: invalid sha256 %q", a.ID, a.SHA256)
+		case a.Size < 0:
+			return fmt.Errorf("attachment %s: invalid size %d", a.ID, a.Size)
+		}
+	}
+	return nil
+}
+
+// validateComments checks the comments of an item from a backup.
+func validateComments(comments []Comment) error {
+	for _, c := range comments {
+		if c.ID == "" {
+			return fmt.Errorf("comment without an id")
+		}
+		if err := ValidateComment(c); err != nil {
+			return fmt.Errorf("comment %s: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// validateTimeEntries checks the time entries of an item from a backup.
+func validateTimeEntries(entries []TimeEntry) error {
+	for _, e := range entries {
+		if e.ID == "" {
+			return fmt.Errorf("time entry without an id")
+		}
+		if err := ValidateTimeEntry(e); err != nil {
+			return fmt.Errorf("time entry %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
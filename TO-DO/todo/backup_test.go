@@ -0,0 +1,175 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackup_RoundTrip(t *testing.T) {
+	items := []Item{
+		{ID: 3, Name: "Pay rent", Due: "05-01-2026", Completed: true, Status: "done", Priority: 1},
+		{ID: 7, Name: "Book flights", Due: "01-01-2026", Projects: []string{"Holiday"}, Status: "booking"},
+	}
+	holiday := Workflow{States: []WorkflowState{{Name: "idea"}, {Name: "booking"}, {Name: "booked", Done: true}}}
+	in := NewSnapshot(items)
+	in.Workflows = map[string]Workflow{"Holiday": holiday}
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf, in); err != nil {
+		t.Fatalf("WriteBackup failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"schema_version": 2`) {
+		t.Errorf("Expected the backup to record its schema version, got %s", buf.String())
+	}
+
+	snap, from, rowErrs, err := ReadBackup(&buf)
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ReadBackup failed: %v %+v", err, rowErrs)
+	}
+	if from != SchemaVersion || snap.SchemaVersion != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d (read from %d)", SchemaVersion, snap.SchemaVersion, from)
+	}
+	if len(snap.Items) != 2 || snap.Items[0].ID != 3 || snap.Items[1].Projects[0] != "Holiday" || snap.Items[0].Priority != 1 {
+		t.Errorf("Round trip changed the items: %+v", snap.Items)
+	}
+	if len(snap.Workflows["Holiday"].States) != 3 || snap.Items[1].Status != "booking" {
+		t.Errorf("Round trip lost the workflows: %+v", snap.Workflows)
+	}
+}
+
+func TestReadBackup_MigratesBareArray(t *testing.T) {
+	// An old todos.json copied by hand is a version 0 backup.
+	old := `[{"ID":1,"Name":"Old task","Completed":false,"Due":"01-01-2025"}]`
+	snap, from, rowErrs, err := ReadBackup(strings.NewReader(old))
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ReadBackup failed: %v %+v", err, rowErrs)
+	}
+	if from != 0 || snap.SchemaVersion != SchemaVersion || snap.ItemCount != 1 {
+		t.Errorf("Expected a migrated version 0 backup, got from=%d %+v", from, snap)
+	}
+	if len(snap.Items) != 1 || snap.Items[0].Name != "Old task" {
+		t.Errorf("Unexpected items: %+v", snap.Items)
+	}
+}
+
+func TestReadBackup_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"newer version":  `{"schema_version": 999, "items": []}`,
+		"no version":     `{"items": []}`,
+		"incomplete":     `{"schema_version": 1, "item_count": 2, "items": [{"ID":1,"Name":"a","Due":"01-01-2025"}]}`,
+		"not json":       `not a backup`,
+		"version is a 0": `{"schema_version": 0, "items": []}`,
+	}
+	for name, doc := range tests {
+		if _, _, _, err := ReadBackup(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadBackup_InvalidItems(t *testing.T) {
+	doc := `{"schema_version": 1, "item_count": 5, "items": [
+		{"ID":1,"Name":"ok","Due":"01-01-2025"},
+		{"ID":1,"Name":"duplicate","Due":"01-01-2025"},
+		{"ID":0,"Name":"no id","Due":"01-01-2025"},
+		{"ID":4,"Name":"","Due":"01-01-2025"},
+		{"ID":5,"Name":"bad blob","Due":"01-01-2025","Attachments":[{"id":"a","sha256":"../../etc/passwd"}]}]}`
+	_, _, rowErrs, err := ReadBackup(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+	if len(rowErrs) != 4 || rowErrs[0].Row != 2 || rowErrs[1].Row != 3 || rowErrs[2].Row != 4 || rowErrs[3].Row != 5 {
+		t.Errorf("Expected errors for items 2-5, got %+v", rowErrs)
+	}
+}
+
+func TestReadBackup_Workflows(t *testing.T) {
+	doc := `{"schema_version": 2, "item_count": 2, "workflows": {"Work": {"states": [{"name": "open"}, {"name": "closed", "done": true}]}},
+		"items": [{"ID":1,"Name":"ok","Due":"01-01-2025","Projects":["Work"],"Status":"open"},
+		{"ID":2,"Name":"stray","Due":"01-01-2025","Projects":["Work"],"Status":"in_review"}]}`
+	_, _, rowErrs, err := ReadBackup(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 2 {
+		t.Errorf("Expected an error for the item in an unknown status, got %+v", rowErrs)
+	}
+
+	bad := `{"schema_version": 2, "item_count": 0, "workflows": {"Work": {"states": [{"name": "open"}]}}, "items": []}`
+	if _, _, _, err := ReadBackup(strings.NewReader(bad)); err == nil {
+		t.Errorf("Expected an invalid workflow to be rejected")
+	}
+}
+
+func TestReadBackup_Fields(t *testing.T) {
+	doc := `{"schema_version": 2, "item_count": 2, "fields": {"Work": [{"name": "seats", "type": "number"}]},
+		"items": [{"ID":1,"Name":"ok","Due":"01-01-2025","Projects":["Work"],"Status":"todo","Fields":{"seats":"3"}},
+		{"ID":2,"Name":"bad","Due":"01-01-2025","Projects":["Work"],"Status":"todo","Fields":{"seats":"three"}}]}`
+	_, _, rowErrs, err := ReadBackup(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 2 {
+		t.Errorf("Expected an error for the item with an invalid value, got %+v", rowErrs)
+	}
+
+	bad := `{"schema_version": 2, "item_count": 0, "fields": {"Work": [{"name": "seats", "type": "colour"}]}, "items": []}`
+	if _, _, _, err := ReadBackup(strings.NewReader(bad)); err == nil {
+		t.Errorf("Expected invalid field definitions to be rejected")
+	}
+}
+
+func TestStartStore_Restore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	Store = make(chan Command)
+	StartStore(filename)
+	t.Cleanup(func() { close(Store) })
+
+	events, unsubscribe := Events.Subscribe(10)
+	defer unsubscribe()
+
+	send := func(cmd Command) any {
+		cmd.Result, cmd.ErrChan = make(chan any), make(chan error)
+		Store <- cmd
+		select {
+		case r := <-cmd.Result:
+			return r
+		case err := <-cmd.ErrChan:
+			t.Fatalf("Command failed: %v", err)
+			return nil
+		}
+	}
+	for range 5 {
+		send(Command{Action: OpAdd, Item: Item{Name: "before", Due: "01-01-2025"}})
+	}
+	restored := []Item{{ID: 2, Name: "restored", Due: "02-02-2025"}}
+	if n := send(Command{Action: OpRestore, Items: restored}); n != 1 {
+		t.Errorf("Expected 1 restored item, got %v", n)
+	}
+
+	items := send(Command{Action: OpGet}).([]Item)
+	if len(items) != 1 || items[0].Name != "restored" {
+		t.Errorf("Expected the list to be replaced, got %+v", items)
+	}
+	// The restore is written straight to disk.
+	data, _ := os.ReadFile(filename)
+	var onDisk Snapshot
+	if err := json.Unmarshal(data, &onDisk); err != nil || len(onDisk.Items) != 1 {
+		t.Errorf("Expected the restored list on disk, got %s", data)
+	}
+	// IDs used before the restore are not handed out again.
+	added := send(Command{Action: OpAdd, Item: Item{Name: "after", Due: "01-01-2025"}}).(Item)
+	if added.ID != 6 {
+		t.Errorf("Expected new item to get ID 6, got %d", added.ID)
+	}
+
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventRestored {
+			return
+		}
+	}
+	t.Error("Expected a restored event")
+}
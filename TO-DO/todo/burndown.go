@@ -0,0 +1,92 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxBurndownDays is the longest range BuildBurndown accepts.
+const MaxBurndownDays = 366
+
+// BurndownPoint is the effort of a set of items at the end of one day.
+type BurndownPoint struct {
+	Date      string  `json:"date"`      // YYYY-MM-DD
+	Scope     float64 `json:"scope"`     // estimate of every item that existed by the end of the day
+	Completed float64 `json:"completed"` // estimate of those items that were completed by the end of the day
+	Remaining float64 `json:"remaining"` // Scope - Completed
+	Ideal     float64 `json:"ideal"`     // a straight line from the first day's Remaining down to 0 on the last day of the range
+}
+
+// Burndown holds burndown (Remaining against Ideal) and burnup (Completed against Scope) series for a range of days.
+type Burndown struct {
+	From    string `json:"from"` // YYYY-MM-DD
+	To      string `json:"to"`   // YYYY-MM-DD, included
+	Project string `json:"project,omitempty"`
+	Context string `json:"context,omitempty"`
+	// Unestimated counts the items in scope without an Estimate, which the series can't account for.
+	Unestimated int             `json:"unestimated"`
+	Points      []BurndownPoint `json:"points"`
+}
+
+// BuildBurndown computes the daily effort of the items in a project and/or context (empty means any) from the first
+// day to the last, both included, using the items' CreatedAt and CompletedAt timestamps. Days are UTC. Days after now
+// have no point yet, though the ideal line still runs to the end of the range.
+//
+// Items are counted from the day they were created, and items without a CreatedAt (saved before it existed) from the
+// start. Completed items without a CompletedAt count as completed from the start. Deleted items are gone, so they drop
+// out of the past as well.
+func BuildBurndown(items []Item, from, to time.Time, project, context string, now time.Time) (Burndown, error) {
+	from = from.UTC().Truncate(24 * time.Hour)
+	to = to.UTC().Truncate(24 * time.Hour)
+	if to.Before(from) {
+		return Burndown{}, errors.New("the end of the range must not be before its start")
+	}
+	days := int(to.Sub(from)/(24*time.Hour)) + 1
+	if days > MaxBurndownDays {
+		return Burndown{}, fmt.Errorf("the range can be at most %d days, got %d", MaxBurndownDays, days)
+	}
+
+	b := Burndown{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Project: project, Context: context, Points: []BurndownPoint{}}
+	var selected []Item
+	for _, item := range items {
+		if (project == "" || slices.Contains(item.Projects, project)) && (context == "" || slices.Contains(item.Contexts, context)) {
+			selected = append(selected, item)
+			if item.Estimate == 0 {
+				b.Unestimated++
+			}
+		}
+	}
+
+	for i := 0; i < days; i++ {
+		day := from.AddDate(0, 0, i)
+		if day.After(now) {
+			break
+		}
+		end := day.AddDate(0, 0, 1)
+		p := BurndownPoint{Date: day.Format(time.DateOnly)}
+		for _, item := range selected {
+			if item.CreatedAt.IsZero() || item.CreatedAt.Before(end) {
+				p.Scope += item.Estimate
+				if item.Completed && (item.CompletedAt.IsZero() || item.CompletedAt.Before(end)) {
+					p.Completed += item.Estimate
+				}
+			}
+		}
+		p.Remaining = p.Scope - p.Completed
+		b.Points = append(b.Points, p)
+	}
+
+	if len(b.Points) > 0 {
+		start := b.Points[0].Remaining
+		for i := range b.Points {
+			if days > 1 {
+				b.Points[i].Ideal = start * float64(days-1-i) / float64(days-1)
+			} else {
+				b.Points[i].Ideal = start
+			}
+		}
+	}
+	return b, nil
+}
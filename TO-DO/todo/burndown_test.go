@@ -0,0 +1,44 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildBurndown(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	items := []Item{
+		{ID: 1, Name: "Design", Estimate: 5, Projects: []string{"web"}, CreatedAt: day(1), Completed: true, CompletedAt: day(2)},
+		{ID: 2, Name: "Build", Estimate: 8, Projects: []string{"web"}, CreatedAt: day(1)},
+		// Added to the scope on day 3.
+		{ID: 3, Name: "Fix", Estimate: 3, Projects: []string{"web"}, CreatedAt: day(3), Completed: true, CompletedAt: day(4)},
+		{ID: 4, Name: "Not estimated", Projects: []string{"web"}, CreatedAt: day(1)},
+		{ID: 5, Name: "Other project", Estimate: 100, Projects: []string{"ops"}, CreatedAt: day(1)},
+	}
+	// The range runs to day 5, but "now" is day 4, so there are four points.
+	b, err := BuildBurndown(items, day(1), day(5), "web", "", day(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.From != "2026-03-01" || b.To != "2026-03-05" || b.Unestimated != 1 || len(b.Points) != 4 {
+		t.Fatalf("Unexpected burndown %+v", b)
+	}
+	want := []BurndownPoint{
+		{Date: "2026-03-01", Scope: 13, Completed: 0, Remaining: 13, Ideal: 13},
+		{Date: "2026-03-02", Scope: 13, Completed: 5, Remaining: 8, Ideal: 9.75},
+		{Date: "2026-03-03", Scope: 16, Completed: 5, Remaining: 11, Ideal: 6.5},
+		{Date: "2026-03-04", Scope: 16, Completed: 8, Remaining: 8, Ideal: 3.25},
+	}
+	for i, p := range b.Points {
+		if p != want[i] {
+			t.Errorf("Day %d: expected %+v, got %+v", i+1, want[i], p)
+		}
+	}
+
+	if _, err := BuildBurndown(items, day(5), day(1), "", "", day(4)); err == nil {
+		t.Error("Expected a reversed range to be rejected")
+	}
+	if _, err := BuildBurndown(items, day(1), day(1).AddDate(2, 0, 0), "", "", day(4)); err == nil {
+		t.Error("Expected a range over MaxBurndownDays to be rejected")
+	}
+}
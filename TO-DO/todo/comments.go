@@ -0,0 +1,119 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// MaxCommentSize is the largest Comment.Body accepted, in bytes.
+const MaxCommentSize = 8 << 10 // 8 KB
+
+// MaxAuthorLength is the longest Comment.Author accepted, in bytes.
+const MaxAuthorLength = 100
+
+// Comment is one message in the discussion thread of an item. The body is Markdown, like Item.Notes.
+type Comment struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	AuthorID  string    `json:"author_id,omitempty"` // the ID of the user who posted it; empty for comments from before there were accounts
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"` // zero until the comment is edited
+}
+
+// ValidateComment checks the fields a client must supply when posting or editing a comment.
+func ValidateComment(c Comment) error {
+	switch {
+	case strings.TrimSpace(c.Author) == "":
+		return errors.New("author cannot be empty")
+	case len(c.Author) > MaxAuthorLength:
+		return fmt.Errorf("author must be at most %d bytes, got %d", MaxAuthorLength, len(c.Author))
+	case strings.TrimSpace(c.Body) == "":
+		return errors.New("comment body cannot be empty")
+	case len(c.Body) > MaxCommentSize:
+		return fmt.Errorf("comments must be at most %d bytes, got %d", MaxCommentSize, len(c.Body))
+	}
+	return nil
+}
+
+// AddComment appends c to the thread of the item with the given ID.
+// Comments don't change the item itself, so its UpdatedAt is left alone.
+func AddComment(toDos []Item, id int, c Comment, ctx context.Context) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	if err := ValidateComment(c); err != nil {
+		return toDos, err
+	}
+	// Build a new slice rather than appending in place, since snapshots handed out by OpGet may share the old one.
+	toDos[i].Comments = append(toDos[i].Comments[:len(toDos[i].Comments):len(toDos[i].Comments)], c)
+	slog.Default().Log(ctx, slog.LevelInfo, "Comment added", "id", id, "comment_id", c.ID, "author", c.Author)
+	return toDos, nil
+}
+
+// EditComment replaces the body of a comment and returns the edited comment.
+func EditComment(toDos []Item, id int, commentID string, body string, ctx context.Context) ([]Item, Comment, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, Comment{}, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	for j, c := range toDos[i].Comments {
+		if c.ID != commentID {
+			continue
+		}
+		c.Body = body
+		if err := ValidateComment(c); err != nil {
+			return toDos, Comment{}, err
+		}
+		c.UpdatedAt = time.Now().UTC()
+		comments := append([]Comment(nil), toDos[i].Comments...)
+		comments[j] = c
+		toDos[i].Comments = comments
+		slog.Default().Log(ctx, slog.LevelInfo, "Comment edited", "id", id, "comment_id", commentID)
+		return toDos, c, nil
+	}
+	return toDos, Comment{}, fmt.Errorf("comment %s %w on item %d", commentID, ErrNotFound, id)
+}
+
+// authorizeComment checks that the caller may edit or delete a comment: only its author and the item's owners may.
+// Comments on unowned items from before there were accounts stay open to everyone, like the items themselves.
+func authorizeComment(toDos []Item, lists map[string]ListAccess, ctx context.Context, id int, commentID string) error {
+	userID, ok := caller(ctx)
+	i := indexOf(toDos, id)
+	if !ok || i < 0 {
+		return nil // the command itself reports a missing item
+	}
+	item := toDos[i]
+	for _, c := range item.Comments {
+		if c.ID != commentID {
+			continue
+		}
+		switch {
+		case c.AuthorID == userID, item.Owner != "" && RoleFor(item, lists, userID) == RoleOwner, c.AuthorID == "" && item.Owner == "":
+			return nil
+		}
+		return fmt.Errorf("%w: only the comment's author or the item's owner may change comment %s", ErrForbidden, commentID)
+	}
+	return nil
+}
+
+// DeleteComment removes a comment from the thread of the item with the given ID and returns it.
+func DeleteComment(toDos []Item, id int, commentID string, ctx context.Context) ([]Item, Comment, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, Comment{}, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	for j, c := range toDos[i].Comments {
+		if c.ID == commentID {
+			toDos[i].Comments = append(toDos[i].Comments[:j:j], toDos[i].Comments[j+1:]...)
+			slog.Default().Log(ctx, slog.LevelInfo, "Comment deleted", "id", id, "comment_id", commentID)
+			return toDos, c, nil
+		}
+	}
+	return toDos, Comment{}, fmt.Errorf("comment %s %w on item %d", commentID, ErrNotFound, id)
+}
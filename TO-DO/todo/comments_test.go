@@ -0,0 +1,95 @@
+package todo
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateComment(t *testing.T) {
+	for _, c := range []Comment{
+		{Author: "", Body: "hi"},
+		{Author: "  ", Body: "hi"},
+		{Author: "Ana", Body: " \n"},
+		{Author: strings.Repeat("a", MaxAuthorLength+1), Body: "hi"},
+		{Author: "Ana", Body: strings.Repeat("a", MaxCommentSize+1)},
+	} {
+		if err := ValidateComment(c); err == nil {
+			t.Errorf("Expected %q by %q to be rejected", c.Body, c.Author)
+		}
+	}
+	if err := ValidateComment(Comment{Author: "Ana", Body: "Looks good"}); err != nil {
+		t.Errorf("Expected a valid comment, got %v", err)
+	}
+}
+
+func TestComments_KeepSnapshotsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	toDos := []Item{{ID: 1, Name: "Task", Due: "01-01-2025"}}
+	toDos, _ = AddComment(toDos, 1, Comment{ID: "a", Author: "Ana", Body: "first"}, ctx)
+	snapshot := append([]Item(nil), toDos...)
+
+	toDos, edited, err := EditComment(toDos, 1, "a", "changed", ctx)
+	if err != nil || edited.Body != "changed" || edited.UpdatedAt.IsZero() {
+		t.Fatalf("Unexpected edit %+v, %v", edited, err)
+	}
+	toDos, _ = AddComment(toDos, 1, Comment{ID: "b", Author: "Ben", Body: "second"}, ctx)
+	toDos, _, err = DeleteComment(toDos, 1, "a", ctx)
+	if err != nil || len(toDos[0].Comments) != 1 || toDos[0].Comments[0].ID != "b" {
+		t.Fatalf("Unexpected thread after delete %+v, %v", toDos[0].Comments, err)
+	}
+	if c := snapshot[0].Comments; len(c) != 1 || c[0].Body != "first" {
+		t.Errorf("Expected an earlier snapshot to be unaffected, got %+v", c)
+	}
+
+	if _, _, err := DeleteComment(toDos, 1, "missing", ctx); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestStartStore_Comments(t *testing.T) {
+	events, unsubscribe := Events.Subscribe(10)
+	t.Cleanup(unsubscribe)
+
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	Store = make(chan Command)
+	StartStore(filename)
+	t.Cleanup(func() { close(Store) })
+
+	send := func(cmd Command) any {
+		t.Helper()
+		cmd.Ctx = context.Background()
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		Store <- cmd
+		select {
+		case result := <-cmd.Result:
+			return result
+		case err := <-cmd.ErrChan:
+			t.Fatalf("Command %v failed: %v", cmd.Action, err)
+			return nil
+		}
+	}
+
+	send(Command{Action: OpAdd, Item: Item{Name: "Task", Due: "01-01-2025"}})
+	send(Command{Action: OpComment, ID: 1, Comment: Comment{ID: "c1", Author: "Ana", Body: "On it"}})
+	edited := send(Command{Action: OpEditComment, ID: 1, Comment: Comment{ID: "c1", Body: "Done soon"}}).(Comment)
+	if edited.Author != "Ana" || edited.Body != "Done soon" {
+		t.Errorf("Expected the edit to keep the author, got %+v", edited)
+	}
+	send(Command{Action: OpDeleteComment, ID: 1, Comment: Comment{ID: "c1"}})
+
+	<-events // created
+	for _, want := range []EventType{EventCommented, EventCommentEdited, EventCommentDeleted} {
+		e := <-events
+		if e.Type != want || e.ItemID != 1 || e.Comment == nil || e.Comment.ID != "c1" {
+			t.Errorf("Expected %s event for comment c1, got %+v", want, e)
+		}
+	}
+
+	items := send(Command{Action: OpGet}).([]Item)
+	if len(items[0].Comments) != 0 {
+		t.Errorf("Expected no comments left, got %+v", items[0].Comments)
+	}
+}
@@ -0,0 +1,161 @@
+package todo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVHeader is the header row written by WriteCSV.
+var CSVHeader = []string{"ID", "Name", "Due", "Completed"}
+
+// csvAliases maps lower-cased column headers a spreadsheet is likely to use onto item fields.
+var csvAliases = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"title":     "name",
+	"task":      "name",
+	"due":       "due",
+	"due date":  "due",
+	"deadline":  "due",
+	"completed": "completed",
+	"done":      "completed",
+	"status":    "completed",
+}
+
+// RowError describes why one row of an import was rejected. Row numbers start at 1 for the first data row.
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// WriteCSV writes the items, preceded by CSVHeader, to w.
+func WriteCSV(w io.Writer, items []Item) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record := []string{strconv.Itoa(item.ID), escapeFormula(item.Name), item.Due, strconv.FormatBool(item.Completed)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV parses items from CSV with a header row.
+// Columns are matched to fields by header name, case-insensitively, using common aliases ("Task", "Deadline", "Done"...).
+// mapping adds or overrides entries: its keys are header names and its values one of "name", "due", "completed" or "ignore".
+// An ID column is accepted but ignored; imported items are given new IDs by the actor.
+//
+// Rows that fail validation are reported in the returned []RowError rather than as an error;
+// the error is only set if the CSV itself can't be read or the header is unusable.
+func ReadCSV(r io.Reader, mapping map[string]string) ([]Item, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // Ragged rows are reported per row rather than failing the whole file.
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("csv is empty")
+		}
+		return nil, nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+
+	columns := make(map[string]int) // field -> column index
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(h))
+		field, ok := csvAliases[key]
+		for k, v := range mapping {
+			if strings.EqualFold(strings.TrimSpace(k), key) {
+				field, ok = strings.ToLower(v), true
+			}
+		}
+		if !ok || field == "ignore" || field == "id" {
+			continue
+		}
+		if field != "name" && field != "due" && field != "completed" {
+			return nil, nil, fmt.Errorf("column %q is mapped to unknown field %q", h, field)
+		}
+		if _, dup := columns[field]; dup {
+			return nil, nil, fmt.Errorf("more than one column is mapped to %q", field)
+		}
+		columns[field] = i
+	}
+	for _, required := range []string{"name", "due"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("no column found for %q", required)
+		}
+	}
+
+	var items []Item
+	var rowErrs []RowError
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrs = append(rowErrs, RowError{Row: row, Error: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return unescapeFormula(strings.TrimSpace(record[i]))
+		}
+
+		item := Item{Name: field("name"), Due: field("due")}
+		if err := ValidateItem(item); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: row, Error: err.Error()})
+			continue
+		}
+		if item.Completed, err = parseCompleted(field("completed")); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: row, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, rowErrs, nil
+}
+
+// parseCompleted accepts the usual spreadsheet spellings of a boolean. An empty cell means not completed.
+func parseCompleted(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "false", "no", "n", "0", "open", "todo":
+		return false, nil
+	case "true", "yes", "y", "1", "x", "done", "completed":
+		return true, nil
+	}
+	return false, fmt.Errorf("completed value %q is not a boolean", s)
+}
+
+// escapeFormula stops spreadsheets from evaluating a cell as a formula ("CSV injection")
+// by prefixing values that start with a formula character with a single quote.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula reverses escapeFormula so that exported files can be imported again unchanged.
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package todo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV_RoundTrip(t *testing.T) {
+	items := []Item{
+		{ID: 1, Name: "Book taxi, early", Due: "27-12-2025"},
+		{ID: 2, Name: "=HYPERLINK(\"evil\")", Due: "28-12-2025", Completed: true},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, items); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	// Formula-like values are neutralised for spreadsheets...
+	if !strings.Contains(buf.String(), `"'=HYPERLINK(""evil"")"`) {
+		t.Errorf("Expected formula to be escaped, got:\n%s", buf.String())
+	}
+
+	// ...but come back unchanged when the file is imported again.
+	got, rowErrs, err := ReadCSV(&buf, nil)
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("ReadCSV failed: %v %v", err, rowErrs)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(got))
+	}
+	for i := range items {
+		if got[i].Name != items[i].Name || got[i].Due != items[i].Due || got[i].Completed != items[i].Completed {
+			t.Errorf("Row %d: expected %+v, got %+v", i+1, items[i], got[i])
+		}
+		if got[i].ID != 0 {
+			t.Errorf("Row %d: expected ID to be ignored, got %d", i+1, got[i].ID)
+		}
+	}
+}
+
+func TestReadCSV_AliasesAndMapping(t *testing.T) {
+	input := "Task,Deadline,Owner,Done\nWrite report,01-02-2026,Sam,x\n"
+	items, rowErrs, err := ReadCSV(strings.NewReader(input), nil)
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("ReadCSV failed: %v %v", err, rowErrs)
+	}
+	if len(items) != 1 || items[0].Name != "Write report" || items[0].Due != "01-02-2026" || !items[0].Completed {
+		t.Errorf("Unexpected items: %+v", items)
+	}
+
+	// An explicit mapping takes precedence over the aliases.
+	input = "Summary,When,Title\nReal name,01-02-2026,Not the name\n"
+	items, _, err = ReadCSV(strings.NewReader(input), map[string]string{"summary": "name", "when": "due", "title": "ignore"})
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Name != "Real name" {
+		t.Errorf("Unexpected items: %+v", items)
+	}
+}
+
+func TestReadCSV_RowErrors(t *testing.T) {
+	input := "Name,Due,Completed\n" +
+		"Good,01-01-2026,false\n" +
+		",01-01-2026,false\n" +
+		"Bad date,2026-01-01,false\n" +
+		"Bad flag,01-01-2026,maybe\n"
+	items, rowErrs, err := ReadCSV(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 valid item, got %d", len(items))
+	}
+	if len(rowErrs) != 3 || rowErrs[0].Row != 2 || rowErrs[1].Row != 3 || rowErrs[2].Row != 4 {
+		t.Errorf("Expected errors on rows 2-4, got %+v", rowErrs)
+	}
+}
+
+func TestReadCSV_BadHeader(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"missing due":    "Name\nTask\n",
+		"duplicate name": "Name,Task,Due\na,b,01-01-2026\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ReadCSV(strings.NewReader(input), nil); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+	if _, _, err := ReadCSV(strings.NewReader("Name,Due\n"), map[string]string{"Due": "deadline"}); err == nil {
+		t.Error("Expected an error for a mapping to an unknown field")
+	}
+}
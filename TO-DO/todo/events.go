@@ -0,0 +1,99 @@
+package todo
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to an item.
+type EventType string
+
+const (
+	EventCreated   EventType = "created"
+	EventUpdated   EventType = "updated"
+	EventCompleted EventType = "completed"
+	EventDeleted   EventType = "deleted"
+	// EventRestored means the whole list was replaced from a backup. It has no item;
+	// subscribers that keep their own copy of the list should fetch it again.
+	EventRestored EventType = "restored"
+	// Comment events carry the comment in Event.Comment as well as the item with its updated thread.
+	EventCommented      EventType = "commented"
+	EventCommentEdited  EventType = "comment_edited"
+	EventCommentDeleted EventType = "comment_deleted"
+	// Assignment events carry the user who was assigned or unassigned in Event.Assignee, one event per user.
+	EventAssigned   EventType = "assigned"
+	EventUnassigned EventType = "unassigned"
+)
+
+// Event is a change notification published by the actor after a command has been applied.
+// Item holds the state of the item after the change (or, for deletes, the state it had before it was removed).
+// Comment is set for comment events only, and Assignee (a username) for assignment events only.
+type Event struct {
+	Type     EventType `json:"type"`
+	ItemID   int       `json:"item_id"`
+	Item     Item      `json:"item"`
+	Comment  *Comment  `json:"comment,omitempty"`
+	Assignee string    `json:"assignee,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"` // the tenant whose store published the event; "" for the default one
+	Time     time.Time `json:"time"`
+	// Readers are the IDs of the users allowed to see the item, set by the actor; nil means everyone.
+	Readers []string `json:"-"`
+}
+
+// VisibleTo reports whether the user with the given ID may see the event.
+func (e Event) VisibleTo(userID string) bool {
+	return e.Readers == nil || slices.Contains(e.Readers, userID)
+}
+
+// Broker fans events out to any number of subscribers.
+// The actor publishes to it; WebSocket connections, webhooks etc. subscribe to it.
+// Publishing never blocks the actor: a subscriber that can't keep up has events dropped.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[chan Event]struct{})}
+}
+
+// Events is the broker the actor publishes change notifications to.
+var Events = NewBroker()
+
+// Subscribe registers a new subscriber with a channel buffer of the given size.
+// The returned function must be called to unsubscribe; it closes the channel.
+func (b *Broker) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers e to every subscriber without blocking.
+func (b *Broker) Publish(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			slog.Default().Log(ctx, slog.LevelWarn, "Event subscriber is full, dropping event.", "type", e.Type, "id", e.ItemID)
+		}
+	}
+}
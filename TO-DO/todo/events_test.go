@@ -0,0 +1,79 @@
+package todo
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe(1)
+
+	b.Publish(context.Background(), Event{Type: EventCreated, ItemID: 1})
+	e := <-events
+	if e.Type != EventCreated || e.ItemID != 1 || e.Time.IsZero() {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	// A full subscriber must not block the publisher; the extra event is dropped.
+	b.Publish(context.Background(), Event{Type: EventUpdated, ItemID: 1})
+	b.Publish(context.Background(), Event{Type: EventDeleted, ItemID: 1})
+	if e := <-events; e.Type != EventUpdated {
+		t.Errorf("Expected the first queued event, got %+v", e)
+	}
+
+	// Unsubscribing closes the channel, and calling it twice is safe.
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestStartStore_PublishesEvents(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "todo_store_events_*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+	os.Remove(tmpFile.Name())
+
+	events, unsubscribe := Events.Subscribe(10)
+	t.Cleanup(unsubscribe)
+
+	Store = make(chan Command)
+	StartStore(tmpFile.Name())
+	t.Cleanup(func() { close(Store) })
+
+	send := func(cmd Command) {
+		cmd.Ctx = context.Background()
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		Store <- cmd
+		select {
+		case <-cmd.Result:
+		case err := <-cmd.ErrChan:
+			t.Fatalf("Command %v failed: %v", cmd.Action, err)
+		}
+	}
+
+	completed := true
+	name := "Renamed"
+	send(Command{Action: OpAdd, Item: Item{Name: "Task", Due: "01-01-2025"}})
+	update := Command{Action: OpUpdate, ID: 1}
+	update.UpdatePayload.Name = &name
+	send(update)
+	complete := Command{Action: OpUpdate, ID: 1}
+	complete.UpdatePayload.Completed = &completed
+	send(complete)
+	send(Command{Action: OpDelete, ID: 1})
+
+	want := []EventType{EventCreated, EventUpdated, EventCompleted, EventDeleted}
+	for _, w := range want {
+		e := <-events
+		if e.Type != w || e.ItemID != 1 {
+			t.Errorf("Expected %s event for item 1, got %+v", w, e)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldType is the kind of value a custom field holds.
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"
+	FieldNumber FieldType = "number"
+	FieldDate   FieldType = "date" // stored as YYYY-MM-DD
+	FieldEnum   FieldType = "enum" // one of the field's Options
+	FieldURL    FieldType = "url"  // an absolute http or https URL
+)
+
+// Limits on custom fields, so that a list's definitions and an item's values stay small.
+const (
+	MaxFields       = 32
+	MaxFieldOptions = 50
+	MaxFieldValue   = 1024 // bytes, for text and URL values
+	MaxOptionLength = 100
+)
+
+// FieldDef defines a custom field of a list. Items of the list store its value under Name in Item.Fields.
+type FieldDef struct {
+	Name    string    `json:"name"`
+	Label   string    `json:"label,omitempty"` // shown instead of Name in the web UI
+	Type    FieldType `json:"type"`
+	Options []string  `json:"options,omitempty"` // the allowed values of an enum field, in display order
+}
+
+var (
+	// ErrInvalidField is returned (wrapped) for a value that doesn't suit its field, or for a field the list doesn't have.
+	ErrInvalidField = errors.New("invalid custom field")
+	// ErrFieldsInUse is returned (wrapped) when changing a list's fields would leave items with values they don't allow.
+	ErrFieldsInUse = errors.New("custom fields are in use")
+)
+
+var fieldName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// DisplayName is the field's Label, or its Name if it has none.
+func (d FieldDef) DisplayName() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return d.Name
+}
+
+// Validate checks the field's name and type, and that an enum field has unique, non-empty options.
+func (d FieldDef) Validate() error {
+	if !fieldName.MatchString(d.Name) {
+		return fmt.Errorf("invalid field name %q (use up to 32 lower-case letters, digits, _ or -)", d.Name)
+	}
+	if utf8.RuneCountInString(d.Label) > MaxOptionLength {
+		return fmt.Errorf("field %q: the label can be at most %d characters", d.Name, MaxOptionLength)
+	}
+	switch d.Type {
+	case FieldText, FieldNumber, FieldDate, FieldURL:
+		if len(d.Options) > 0 {
+			return fmt.Errorf("field %q: only enum fields have options", d.Name)
+		}
+	case FieldEnum:
+		if len(d.Options) == 0 || len(d.Options) > MaxFieldOptions {
+			return fmt.Errorf("field %q: an enum needs between 1 and %d options", d.Name, MaxFieldOptions)
+		}
+		for i, o := range d.Options {
+			if strings.TrimSpace(o) == "" || utf8.RuneCountInString(o) > MaxOptionLength {
+				return fmt.Errorf("field %q: options must be between 1 and %d characters", d.Name, MaxOptionLength)
+			}
+			if slices.Contains(d.Options[:i], o) {
+				return fmt.Errorf("field %q: duplicate option %q", d.Name, o)
+			}
+		}
+	default:
+		return fmt.Errorf("field %q: unknown type %q (use text, number, date, enum or url)", d.Name, d.Type)
+	}
+	return nil
+}
+
+// Normalize checks a value against the field's type and returns it in the form it is stored in: numbers without
+// redundant digits, dates as YYYY-MM-DD (DD-MM-YYYY is accepted too), and other values trimmed.
+func (d FieldDef) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch d.Type {
+	case FieldNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("%w: %s must be a number", ErrInvalidField, d.Name)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case FieldDate:
+		for _, layout := range []string{time.DateOnly, DueLayout} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format(time.DateOnly), nil
+			}
+		}
+		return "", fmt.Errorf("%w: %s must be a date (YYYY-MM-DD or DD-MM-YYYY)", ErrInvalidField, d.Name)
+	case FieldEnum:
+		if !slices.Contains(d.Options, value) {
+			return "", fmt.Errorf("%w: %s must be one of %s", ErrInvalidField, d.Name, strings.Join(d.Options, ", "))
+		}
+		return value, nil
+	case FieldURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%w: %s must be an http or https URL", ErrInvalidField, d.Name)
+		}
+	}
+	if len(value) > MaxFieldValue {
+		return "", fmt.Errorf("%w: %s can be at most %d bytes", ErrInvalidField, d.Name, MaxFieldValue)
+	}
+	return value, nil
+}
+
+// ValidateFieldDefs checks the definitions of one list's fields, which must have unique names.
+func ValidateFieldDefs(defs []FieldDef) error {
+	if len(defs) > MaxFields {
+		return fmt.Errorf("a list can have at most %d custom fields", MaxFields)
+	}
+	for i, d := range defs {
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if slices.ContainsFunc(defs[:i], func(other FieldDef) bool { return other.Name == d.Name }) {
+			return fmt.Errorf("duplicate field %q", d.Name)
+		}
+	}
+	return nil
+}
+
+// FieldsFor returns the custom fields of the item's list.
+func FieldsFor(fields map[string][]FieldDef, item Item) []FieldDef {
+	return fields[ListOf(item)]
+}
+
+// normalizeFields checks values against a list's fields and returns them normalized. Empty values are dropped, so
+// that an empty form field simply leaves the custom field unset.
+func normalizeFields(defs []FieldDef, values map[string]string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(values))
+	for name, value := range values {
+		i := slices.IndexFunc(defs, func(d FieldDef) bool { return d.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: the list has no field %q", ErrInvalidField, name)
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		v, err := defs[i].Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		normalized[name] = v
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// normalizeFieldUpdate checks the values of a partial update against the item's fields. Unlike normalizeFields it
+// keeps empty values, which mean that the field is to be cleared.
+func normalizeFieldUpdate(defs []FieldDef, values map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(values))
+	for name, value := range values {
+		if strings.TrimSpace(value) == "" {
+			if !slices.ContainsFunc(defs, func(d FieldDef) bool { return d.Name == name }) {
+				return nil, fmt.Errorf("%w: the list has no field %q", ErrInvalidField, name)
+			}
+			normalized[name] = ""
+			continue
+		}
+		v, err := normalizeFields(defs, map[string]string{name: value})
+		if err != nil {
+			return nil, err
+		}
+		normalized[name] = v[name]
+	}
+	return normalized, nil
+}
+
+// mergeFields applies a normalized update to an item's values, in a new map so that earlier snapshots of the item
+// keep theirs. Empty values remove the field.
+func mergeFields(current, update map[string]string) map[string]string {
+	merged := make(map[string]string, len(current)+len(update))
+	for name, value := range current {
+		merged[name] = value
+	}
+	for name, value := range update {
+		if value == "" {
+			delete(merged, name)
+		} else {
+			merged[name] = value
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// checkFields reports the items whose values don't suit the fields of their list under the given definitions.
+func checkFields(items []Item, fields map[string][]FieldDef) error {
+	var stranded []string
+	for _, item := range items {
+		if _, err := normalizeFields(FieldsFor(fields, item), item.Fields); err != nil {
+			stranded = append(stranded, fmt.Sprintf("#%d (%v)", item.ID, err))
+		}
+	}
+	if len(stranded) > 0 {
+		sort.Strings(stranded)
+		return fmt.Errorf("%w: items %s would be left with values the fields don't allow; change them first", ErrFieldsInUse, strings.Join(stranded, ", "))
+	}
+	return nil
+}
+
+// SetFields sets (or, with no definitions, removes) the custom fields of a list and returns the new set of
+// definitions. It fails if any item of the list has a value the new fields don't allow.
+func SetFields(items []Item, fields map[string][]FieldDef, list string, defs []FieldDef) (map[string][]FieldDef, error) {
+	if err := ValidateFieldDefs(defs); err != nil {
+		return fields, err
+	}
+	updated := make(map[string][]FieldDef, len(fields)+1)
+	for name, existing := range fields {
+		updated[name] = existing
+	}
+	if len(defs) == 0 {
+		delete(updated, list)
+	} else {
+		updated[list] = slices.Clone(defs)
+	}
+	if err := checkFields(items, updated); err != nil {
+		return fields, err
+	}
+	return updated, nil
+}
+
+// MatchFields reports whether the item has every one of the given values, compared case-insensitively.
+func MatchFields(item Item, filters map[string]string) bool {
+	for name, want := range filters {
+		if !strings.EqualFold(item.Fields[name], strings.TrimSpace(want)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package todo
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var supportFields = []FieldDef{
+	{Name: "customer", Type: FieldText},
+	{Name: "seats", Type: FieldNumber},
+	{Name: "renewal", Type: FieldDate},
+	{Name: "env", Label: "Environment", Type: FieldEnum, Options: []string{"staging", "production"}},
+	{Name: "ticket", Type: FieldURL},
+}
+
+func TestFieldDef_Normalize(t *testing.T) {
+	byName := func(name string) FieldDef {
+		for _, d := range supportFields {
+			if d.Name == name {
+				return d
+			}
+		}
+		t.Fatalf("no field %q", name)
+		return FieldDef{}
+	}
+	valid := []struct{ field, in, want string }{
+		{"customer", "  Acme Ltd ", "Acme Ltd"},
+		{"seats", "12.50", "12.5"},
+		{"seats", "-3", "-3"},
+		{"renewal", "2026-03-01", "2026-03-01"},
+		{"renewal", "01-03-2026", "2026-03-01"},
+		{"env", "production", "production"},
+		{"ticket", "https://tracker.example.com/T-1", "https://tracker.example.com/T-1"},
+	}
+	for _, tc := range valid {
+		if got, err := byName(tc.field).Normalize(tc.in); err != nil || got != tc.want {
+			t.Errorf("%s %q: expected %q, got %q (%v)", tc.field, tc.in, tc.want, got, err)
+		}
+	}
+	invalid := []struct{ field, in string }{
+		{"seats", "a dozen"},
+		{"seats", "Inf"},
+		{"renewal", "March 1st"},
+		{"env", "Production"},
+		{"ticket", "javascript:alert(1)"},
+		{"ticket", "tracker.example.com/T-1"},
+		{"customer", strings.Repeat("x", MaxFieldValue+1)},
+	}
+	for _, tc := range invalid {
+		if _, err := byName(tc.field).Normalize(tc.in); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("%s %q: expected ErrInvalidField, got %v", tc.field, tc.in, err)
+		}
+	}
+}
+
+func TestValidateFieldDefs(t *testing.T) {
+	if err := ValidateFieldDefs(supportFields); err != nil {
+		t.Fatalf("Expected valid fields, got %v", err)
+	}
+	tests := map[string][]FieldDef{
+		"bad name":          {{Name: "Customer Name", Type: FieldText}},
+		"unknown type":      {{Name: "x", Type: "colour"}},
+		"enum without opts": {{Name: "x", Type: FieldEnum}},
+		"duplicate option":  {{Name: "x", Type: FieldEnum, Options: []string{"a", "a"}}},
+		"options on text":   {{Name: "x", Type: FieldText, Options: []string{"a"}}},
+		"duplicate field":   {{Name: "x", Type: FieldText}, {Name: "x", Type: FieldNumber}},
+	}
+	for name, defs := range tests {
+		if err := ValidateFieldDefs(defs); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSetFields(t *testing.T) {
+	items := []Item{{ID: 1, Projects: []string{"Support"}, Fields: map[string]string{"env": "staging"}}}
+	fields, err := SetFields(items, nil, "Support", supportFields)
+	if err != nil || len(fields["Support"]) != len(supportFields) {
+		t.Fatalf("SetFields failed: %v", err)
+	}
+	// Dropping the staging option would leave item 1 with a value it doesn't allow.
+	narrower := []FieldDef{{Name: "env", Type: FieldEnum, Options: []string{"production"}}}
+	if _, err := SetFields(items, fields, "Support", narrower); !errors.Is(err, ErrFieldsInUse) {
+		t.Errorf("Expected ErrFieldsInUse, got %v", err)
+	}
+	if _, err := SetFields(items, fields, "Support", nil); !errors.Is(err, ErrFieldsInUse) {
+		t.Errorf("Expected ErrFieldsInUse when removing fields in use, got %v", err)
+	}
+	if fields, err = SetFields(nil, fields, "Support", nil); err != nil || len(fields) != 0 {
+		t.Errorf("Expected the fields to be removed, got %+v (%v)", fields, err)
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	item := Item{Fields: map[string]string{"customer": "Acme", "env": "production"}}
+	if !MatchFields(item, map[string]string{"customer": "acme", "env": "production"}) || !MatchFields(item, nil) {
+		t.Errorf("Expected the item to match")
+	}
+	if MatchFields(item, map[string]string{"customer": "Acme", "env": "staging"}) || MatchFields(item, map[string]string{"seats": "1"}) {
+		t.Errorf("Expected the item not to match")
+	}
+}
+
+func TestStartStore_Fields(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	Store = make(chan Command)
+	StartStore(filename)
+	t.Cleanup(func() { close(Store) })
+
+	send := func(cmd Command) (any, error) {
+		cmd.Result, cmd.ErrChan = make(chan any), make(chan error)
+		Store <- cmd
+		select {
+		case r := <-cmd.Result:
+			return r, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+	if _, err := send(Command{Action: OpSetFields, List: "Support", FieldDefs: supportFields}); err != nil {
+		t.Fatalf("OpSetFields failed: %v", err)
+	}
+	item := Item{Name: "Renew licence", Due: "01-01-2026", Projects: []string{"Support"}, Fields: map[string]string{"seats": "10.0", "customer": ""}}
+	added, err := send(Command{Action: OpAdd, Item: item})
+	if err != nil || added.(Item).Fields["seats"] != "10" || len(added.(Item).Fields) != 1 {
+		t.Fatalf("Expected a normalized value and no empty ones, got %+v (%v)", added, err)
+	}
+	// The fields belong to the Support list only.
+	if _, err := send(Command{Action: OpAdd, Item: Item{Name: "Other", Due: "01-01-2026", Fields: map[string]string{"seats": "1"}}}); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField for a list without the field, got %v", err)
+	}
+
+	updated, err := send(Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Fields: map[string]string{"env": "staging", "seats": ""}}})
+	if err != nil || updated.(Item).Fields["env"] != "staging" || updated.(Item).Fields["seats"] != "" {
+		t.Errorf("Expected env set and seats cleared, got %+v (%v)", updated, err)
+	}
+	if _, err := send(Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Fields: map[string]string{"env": "dev"}}}); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField, got %v", err)
+	}
+	// Imports are checked the same way, and a bad value refuses the whole batch.
+	imported, err := send(Command{Action: OpImport, Items: []Item{{Name: "Imported", Due: "01-01-2026", Projects: []string{"Support"}, Fields: map[string]string{"seats": "2.0"}}}})
+	if err != nil || imported.([]Item)[0].Fields["seats"] != "2" {
+		t.Errorf("Expected an imported value to be normalized, got %+v (%v)", imported, err)
+	}
+	bad := []Item{
+		{Name: "Fine", Due: "01-01-2026", Projects: []string{"Support"}},
+		{Name: "Bad", Due: "01-01-2026", Projects: []string{"Support"}, Fields: map[string]string{"seats": "many"}},
+	}
+	if _, err := send(Command{Action: OpImport, Items: bad}); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField for an import, got %v", err)
+	}
+	if items, _ := send(Command{Action: OpGet}); len(items.([]Item)) != 2 {
+		t.Errorf("Expected the bad import to add nothing, got %+v", items)
+	}
+	// The earlier snapshot still has the values it was taken with.
+	if added.(Item).Fields["seats"] != "10" {
+		t.Errorf("Update changed an earlier snapshot: %+v", added)
+	}
+
+	if _, err := send(Command{Action: OpShutdown}); err != nil {
+		t.Fatalf("OpShutdown failed: %v", err)
+	}
+	snap, err := LoadSnapshot(filename, t.Context())
+	if err != nil || len(snap.Fields["Support"]) != len(supportFields) || snap.Items[0].Fields["env"] != "staging" {
+		t.Errorf("Expected the fields and values on disk, got %+v (%v)", snap, err)
+	}
+}
@@ -0,0 +1,301 @@
+package todo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iCalendar (RFC 5545) support: each Item is written as a VTODO component.
+
+const (
+	icalProdID     = "-//GoAcademy//TO-DO//EN"
+	icalDateLayout = "20060102"
+	icalUTCLayout  = "20060102T150405Z"
+	icalLineLimit  = 75 // octets per line, excluding the CRLF
+)
+
+// ICalUID returns the UID written for an item. It depends only on the ID, so it is stable across exports.
+func ICalUID(item Item) string {
+	return fmt.Sprintf("todo-%d@goacademy-todo", item.ID)
+}
+
+// WriteICS writes the items as an iCalendar stream containing one VTODO per item.
+func WriteICS(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	stamp := time.Now().UTC().Format(icalUTCLayout)
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", icalProdID)
+	for _, item := range items {
+		line("BEGIN", "VTODO")
+		line("UID", ICalUID(item))
+		line("DTSTAMP", stamp)
+		line("SUMMARY", escapeICalText(item.Name))
+		if item.Notes != "" {
+			line("DESCRIPTION", escapeICalText(item.Notes))
+		}
+		if due, err := time.Parse(DueLayout, item.Due); err == nil {
+			line("DUE;VALUE=DATE", due.Format(icalDateLayout))
+		}
+		if item.Completed {
+			line("STATUS", "COMPLETED")
+			if !item.CompletedAt.IsZero() {
+				line("COMPLETED", item.CompletedAt.UTC().Format(icalUTCLayout))
+			}
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		if item.Priority > 0 {
+			line("PRIORITY", strconv.Itoa(item.Priority))
+		}
+		if !item.CreatedAt.IsZero() {
+			line("CREATED", item.CreatedAt.UTC().Format(icalUTCLayout))
+		}
+		if !item.UpdatedAt.IsZero() {
+			line("LAST-MODIFIED", item.UpdatedAt.UTC().Format(icalUTCLayout))
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeFolded writes a content line, folding it so that no line exceeds 75 octets.
+// Continuation lines start with a single space, and folds never split a multi-byte UTF-8 character.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := icalLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = icalLineLimit - 1 // the leading space counts towards the limit
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// escapeICalText escapes a TEXT value: backslash, semicolon, comma and newlines.
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// unescapeICalText reverses escapeICalText.
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default: // \\ \; \, (and anything else is taken literally)
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// icalProperty is one unfolded content line: NAME;PARAM=VALUE:VALUE
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// parseICalLine splits a content line into name, parameters and value.
+// Parameter values may be quoted, and quoted values may contain ':' and ';'.
+func parseICalLine(line string) (icalProperty, error) {
+	inQuote := false
+	colon := -1
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			inQuote = !inQuote
+		case ':':
+			if !inQuote {
+				colon = i
+			}
+		}
+		if colon >= 0 {
+			break
+		}
+	}
+	if colon < 0 {
+		return icalProperty{}, fmt.Errorf("malformed content line %q", line)
+	}
+
+	prop := icalProperty{Params: make(map[string]string), Value: line[colon+1:]}
+	parts := splitUnquoted(line[:colon], ';')
+	prop.Name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		prop.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return prop, nil
+}
+
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unfoldICal reads content lines, joining folded continuation lines (those starting with a space or tab).
+func unfoldICal(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		lines = append(lines, text)
+	}
+	return lines, scanner.Err()
+}
+
+// ReadICS parses the VTODO components of an iCalendar stream into items.
+// Other components (VEVENT, VTIMEZONE...) are skipped. VTODOs that can't be turned into a valid item
+// are reported in the returned []RowError, numbered by their position among the VTODOs starting at 1.
+// VTODOs without a DUE are due the day they are imported.
+func ReadICS(r io.Reader) ([]Item, []RowError, error) {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read calendar: %w", err)
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, nil, errors.New("not an iCalendar stream: missing BEGIN:VCALENDAR")
+	}
+
+	var items []Item
+	var rowErrs []RowError
+	var current *Item
+	var currentErr error
+	var depth int // nesting inside the current VTODO (e.g. VALARM)
+	index := 0
+
+	for _, line := range lines {
+		prop, err := parseICalLine(line)
+		if err != nil {
+			if current != nil && currentErr == nil {
+				currentErr = err
+			}
+			continue
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VTODO") && current == nil:
+			index++
+			current, currentErr, depth = &Item{}, nil, 0
+			continue
+		case current == nil:
+			continue
+		case prop.Name == "BEGIN":
+			depth++
+			continue
+		case prop.Name == "END" && depth > 0:
+			depth--
+			continue
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VTODO"):
+			if currentErr == nil {
+				*current = withDefaultDue(*current)
+				currentErr = ValidateItem(*current)
+			}
+			if currentErr != nil {
+				rowErrs = append(rowErrs, RowError{Row: index, Error: currentErr.Error()})
+			} else {
+				items = append(items, *current)
+			}
+			current = nil
+			continue
+		case depth > 0:
+			continue // properties of a nested component such as VALARM
+		}
+
+		if err := applyICalProperty(current, prop); err != nil && currentErr == nil {
+			currentErr = err
+		}
+	}
+	if current != nil {
+		rowErrs = append(rowErrs, RowError{Row: index, Error: "VTODO is missing END:VTODO"})
+	}
+	return items, rowErrs, nil
+}
+
+// applyICalProperty sets the item field corresponding to a VTODO property. Unknown properties are ignored.
+func applyICalProperty(item *Item, prop icalProperty) error {
+	switch prop.Name {
+	case "SUMMARY":
+		item.Name = unescapeICalText(prop.Value)
+	case "DESCRIPTION":
+		item.Notes = unescapeICalText(prop.Value)
+	case "DUE":
+		due, err := parseICalTime(prop)
+		if err != nil {
+			return fmt.Errorf("invalid DUE %q", prop.Value)
+		}
+		item.Due = due.Format(DueLayout)
+	case "STATUS":
+		item.Completed = strings.EqualFold(prop.Value, "COMPLETED")
+	case "COMPLETED":
+		completed, err := parseICalTime(prop)
+		if err != nil {
+			return fmt.Errorf("invalid COMPLETED %q", prop.Value)
+		}
+		item.CompletedAt = completed.UTC()
+		item.Completed = true
+	case "PRIORITY":
+		p, err := strconv.Atoi(prop.Value)
+		if err != nil || p < 0 || p > 9 {
+			return fmt.Errorf("invalid PRIORITY %q", prop.Value)
+		}
+		item.Priority = p
+	}
+	return nil
+}
+
+// parseICalTime accepts DATE values and DATE-TIME values in UTC or floating/TZID form.
+// For a DATE-TIME with a TZID the wall-clock date is used, which is all an Item's due date records.
+func parseICalTime(prop icalProperty) (time.Time, error) {
+	v := prop.Value
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(v) == len(icalDateLayout) {
+		return time.Parse(icalDateLayout, v)
+	}
+	if strings.HasSuffix(v, "Z") {
+		return time.Parse(icalUTCLayout, v)
+	}
+	return time.Parse("20060102T150405", v)
+}
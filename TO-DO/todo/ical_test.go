@@ -0,0 +1,138 @@
+package todo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteICS(t *testing.T) {
+	updated := time.Date(2025, 12, 1, 9, 30, 0, 0, time.UTC)
+	items := []Item{
+		{ID: 1, Name: "Book taxi; airport, early", Due: "27-12-2025", Priority: 1, UpdatedAt: updated},
+		{ID: 2, Name: "Done", Due: "28-12-2025", Completed: true, CompletedAt: updated},
+	}
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, items); err != nil {
+		t.Fatalf("WriteICS failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:todo-1@goacademy-todo\r\n",
+		`SUMMARY:Book taxi\; airport\, early` + "\r\n",
+		"DUE;VALUE=DATE:20251227\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"PRIORITY:1\r\n",
+		"LAST-MODIFIED:20251201T093000Z\r\n",
+		"STATUS:COMPLETED\r\n",
+		"COMPLETED:20251201T093000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q\n%s", want, out)
+		}
+	}
+
+	// The UID must not change between exports.
+	var again bytes.Buffer
+	WriteICS(&again, items)
+	if !strings.Contains(again.String(), "UID:todo-1@goacademy-todo\r\n") {
+		t.Error("UID changed between exports")
+	}
+}
+
+func TestWriteICS_FoldsLongLines(t *testing.T) {
+	name := strings.Repeat("ü", 60) // 120 octets: a naive fold would split a character
+	var buf bytes.Buffer
+	WriteICS(&buf, []Item{{ID: 1, Name: name, Due: "01-01-2026"}})
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line exceeds 75 octets (%d): %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Fold split a UTF-8 character: %q", line)
+		}
+	}
+
+	items, rowErrs, err := ReadICS(&buf)
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("ReadICS failed: %v %v", err, rowErrs)
+	}
+	if len(items) != 1 || items[0].Name != name {
+		t.Errorf("Folded name did not round trip: %+v", items)
+	}
+}
+
+func TestReadICS(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Not a todo\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:abc\r\n" +
+		"SUMMARY:Line one\\nline two\\, with \\;escapes\\\\\r\n" +
+		"DUE;TZID=\"Europe/London:odd\":20260115T170000\r\n" +
+		"PRIORITY:5\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"SUMMARY:Alarm summary must not overwrite the todo\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:Folded\r\n" +
+		"  summary\r\n" +
+		"DESCRIPTION:Bring the *receipt*\\nand the card\r\n" +
+		"DUE;VALUE=DATE:20260201\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"COMPLETED:20260202T080000Z\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:No due date\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:Bad priority\r\n" +
+		"DUE:20260201\r\n" +
+		"PRIORITY:high\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	items, rowErrs, err := ReadICS(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadICS failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items, got %d: %+v", len(items), items)
+	}
+	if items[0].Name != "Line one\nline two, with ;escapes\\" || items[0].Due != "15-01-2026" || items[0].Priority != 5 {
+		t.Errorf("Unexpected first item: %+v", items[0])
+	}
+	if items[1].Name != "Folded summary" || items[1].Notes != "Bring the *receipt*\nand the card" || items[1].Due != "01-02-2026" || !items[1].Completed ||
+		!items[1].CompletedAt.Equal(time.Date(2026, 2, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected second item: %+v", items[1])
+	}
+	if items[2].Name != "No due date" || items[2].Due != time.Now().Format(DueLayout) {
+		t.Errorf("Expected a VTODO without DUE to be due today, got %+v", items[2])
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 4 {
+		t.Errorf("Expected an error for VTODO 4, got %+v", rowErrs)
+	}
+}
+
+func TestReadICS_RejectsNonCalendar(t *testing.T) {
+	if _, _, err := ReadICS(strings.NewReader("Name,Due\n")); err == nil {
+		t.Error("Expected an error for input that isn't iCalendar")
+	}
+}
+
+func TestICalText_EscapeRoundTrip(t *testing.T) {
+	for _, s := range []string{`plain`, `a;b,c\d`, "multi\nline", `trailing\`} {
+		if got := unescapeICalText(escapeICalText(s)); got != s {
+			t.Errorf("Round trip of %q gave %q", s, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrItemQuotaExceeded is returned when a command would leave a store with more items than its StoreConfig allows.
+var ErrItemQuotaExceeded = errors.New("item quota exceeded")
+
+// StoreConfig describes one actor: where it keeps its data and how many items it may hold.
+type StoreConfig struct {
+	Filename string     // the data file, as for StartStore
+	Blobs    *BlobStore // where attachment contents are kept; nil means Blobs
+	MaxItems int        // the most items the store may hold; 0 means no limit
+	Tenant   string     // the tenant the store belongs to, if any; set on every Event it publishes
+}
+
+// NewStore loads cfg.Filename and starts another actor on it, like StartStore does for Store, returning the
+// channel to send it commands on. Send it OpShutdown to save the data and stop it.
+// Unlike StartStore, a file that can't be loaded is an error rather than a panic.
+func NewStore(cfg StoreConfig, ctx context.Context) (chan Command, error) {
+	snap, err := LoadSnapshot(cfg.Filename, ctx)
+	if err != nil {
+		return nil, err
+	}
+	store := make(chan Command)
+	go runStore(store, cfg, snap)
+	return store, nil
+}
+
+// Instance is the actor a request's commands are sent to, together with where its attachments are kept.
+type Instance struct {
+	Tenant string
+	Store  chan Command
+	Blobs  *BlobStore
+}
+
+type instanceKey struct{}
+
+// WithInstance returns a copy of ctx whose commands go to inst rather than to Store.
+func WithInstance(ctx context.Context, inst Instance) context.Context {
+	return context.WithValue(ctx, instanceKey{}, inst)
+}
+
+// InstanceFrom returns the Instance put in ctx by WithInstance, or Store and Blobs if there is none.
+func InstanceFrom(ctx context.Context) Instance {
+	var inst Instance
+	if ctx != nil {
+		inst, _ = ctx.Value(instanceKey{}).(Instance)
+	}
+	if inst.Store == nil {
+		inst.Store = Store
+	}
+	inst.Blobs = inst.blobStore()
+	return inst
+}
+
+// blobStore returns where the instance's attachments are kept.
+func (inst Instance) blobStore() *BlobStore {
+	if inst.Blobs == nil {
+		return Blobs
+	}
+	return inst.Blobs
+}
+
+// checkItemQuota reports whether a store may hold n items.
+func checkItemQuota(maxItems, n int) error {
+	if maxItems > 0 && n > maxItems {
+		return fmt.Errorf("%w: at most %d items are allowed", ErrItemQuotaExceeded, maxItems)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package todo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHub-style task list support:
+//
+//	- [ ] Book taxi (due 27-12-2025)
+//	- [x] Feed the cat (due 15-10-2026)
+
+// Ways WriteMarkdown can group the list under headings.
+const (
+	MarkdownGroupNone       = ""
+	MarkdownGroupCompletion = "completion" // "Open" then "Done"
+	MarkdownGroupDue        = "due"        // one heading per due date, earliest first
+)
+
+var (
+	// markdownTask matches a task list item, allowing for indentation and any list marker.
+	markdownTask = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+	// markdownDue matches the "(due ...)" suffix; both DD-MM-YYYY and YYYY-MM-DD are accepted on import.
+	markdownDue = regexp.MustCompile(`\s*\(due:?\s+([0-9-]+)\)\s*$`)
+)
+
+// FormatMarkdownTask returns the task list line for an item.
+func FormatMarkdownTask(item Item) string {
+	box := "[ ]"
+	if item.Completed {
+		box = "[x]"
+	}
+	// A newline would end the list item, so the name is flattened onto one line.
+	name := strings.Join(strings.Fields(item.Name), " ")
+	return fmt.Sprintf("- %s %s (due %s)", box, name, item.Due)
+}
+
+// WriteMarkdown writes the items as a task list, optionally grouped under "##" headings (see the MarkdownGroup constants).
+func WriteMarkdown(w io.Writer, items []Item, groupBy string) error {
+	bw := bufio.NewWriter(w)
+	writeList := func(items []Item) {
+		for _, item := range items {
+			bw.WriteString(FormatMarkdownTask(item))
+			bw.WriteString("\n")
+		}
+	}
+	writeSection := func(heading string, items []Item) {
+		fmt.Fprintf(bw, "## %s\n\n", heading)
+		writeList(items)
+		bw.WriteString("\n")
+	}
+
+	switch groupBy {
+	case MarkdownGroupNone:
+		writeList(items)
+	case MarkdownGroupCompletion:
+		var open, done []Item
+		for _, item := range items {
+			if item.Completed {
+				done = append(done, item)
+			} else {
+				open = append(open, item)
+			}
+		}
+		writeSection("Open", open)
+		writeSection("Done", done)
+	case MarkdownGroupDue:
+		byDue := make(map[string][]Item)
+		var dues []string
+		for _, item := range items {
+			if _, seen := byDue[item.Due]; !seen {
+				dues = append(dues, item.Due)
+			}
+			byDue[item.Due] = append(byDue[item.Due], item)
+		}
+		sort.Slice(dues, func(i, j int) bool {
+			a, errA := time.Parse(DueLayout, dues[i])
+			b, errB := time.Parse(DueLayout, dues[j])
+			if errA != nil || errB != nil {
+				return errB != nil && errA == nil // unparseable dates go last
+			}
+			return a.Before(b)
+		})
+		for _, due := range dues {
+			writeSection("Due "+due, byDue[due])
+		}
+	default:
+		return fmt.Errorf("unknown grouping %q", groupBy)
+	}
+	return bw.Flush()
+}
+
+// ParseMarkdownTask parses one task list line. ok is false if the line isn't a task list item at all.
+func ParseMarkdownTask(line string) (item Item, ok bool, err error) {
+	m := markdownTask.FindStringSubmatch(line)
+	if m == nil {
+		return Item{}, false, nil
+	}
+	item.Completed = m[1] != " "
+	text := m[2]
+
+	if d := markdownDue.FindStringSubmatchIndex(text); d != nil {
+		due := text[d[2]:d[3]]
+		if t, err := time.Parse(DueLayout, due); err == nil {
+			item.Due = t.Format(DueLayout)
+		} else if t, err := time.Parse("2006-01-02", due); err == nil {
+			item.Due = t.Format(DueLayout)
+		} else {
+			return item, true, fmt.Errorf("invalid due date %q", due)
+		}
+		text = text[:d[0]]
+	}
+	item.Name = strings.TrimSpace(text)
+	return item, true, nil
+}
+
+// ReadMarkdown extracts the task list items from a Markdown document, preserving their checkbox state.
+// Headings, prose and ordinary list items are ignored. Invalid tasks are reported by line number.
+func ReadMarkdown(r io.Reader) ([]Item, []RowError, error) {
+	scanner := bufio.NewScanner(r)
+	var items []Item
+	var rowErrs []RowError
+	for line := 1; scanner.Scan(); line++ {
+		item, ok, err := ParseMarkdownTask(scanner.Text())
+		if !ok {
+			continue
+		}
+		if err == nil {
+			err = ValidateItem(item)
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Row: line, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read markdown: %w", err)
+	}
+	return items, rowErrs, nil
+}
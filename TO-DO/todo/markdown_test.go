@@ -0,0 +1,93 @@
+package todo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	items := []Item{
+		{ID: 1, Name: "Pay rent", Due: "05-01-2026", Completed: true},
+		{ID: 2, Name: "Book\nflights", Due: "01-01-2026"},
+		{ID: 3, Name: "Call the garage", Due: "05-01-2026"},
+	}
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{MarkdownGroupNone, "- [x] Pay rent (due 05-01-2026)\n- [ ] Book flights (due 01-01-2026)\n- [ ] Call the garage (due 05-01-2026)\n"},
+		{MarkdownGroupCompletion, "## Open\n\n- [ ] Book flights (due 01-01-2026)\n- [ ] Call the garage (due 05-01-2026)\n\n" +
+			"## Done\n\n- [x] Pay rent (due 05-01-2026)\n\n"},
+		{MarkdownGroupDue, "## Due 01-01-2026\n\n- [ ] Book flights (due 01-01-2026)\n\n" +
+			"## Due 05-01-2026\n\n- [x] Pay rent (due 05-01-2026)\n- [ ] Call the garage (due 05-01-2026)\n\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteMarkdown(&buf, items, tt.groupBy); err != nil {
+			t.Fatalf("WriteMarkdown(%q) failed: %v", tt.groupBy, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("WriteMarkdown(%q)\n got %q\nwant %q", tt.groupBy, buf.String(), tt.want)
+		}
+	}
+
+	if err := WriteMarkdown(&bytes.Buffer{}, items, "priority"); err == nil {
+		t.Error("Expected an error for an unknown grouping")
+	}
+}
+
+func TestReadMarkdown(t *testing.T) {
+	doc := `# Sprint notes
+
+Some prose that isn't a task.
+
+## Open
+
+- [ ] Book flights (due 01-01-2026)
+  * [X] Pack bags (due 2026-01-02)
+1. [ ] Numbered task (due 03-01-2026)
+- an ordinary list item
+- [ ] No due date
+- [x] Bad date (due 31-02-2026)
+`
+	items, rowErrs, err := ReadMarkdown(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ReadMarkdown failed: %v", err)
+	}
+	want := []Item{
+		{Name: "Book flights", Due: "01-01-2026"},
+		{Name: "Pack bags", Due: "02-01-2026", Completed: true},
+		{Name: "Numbered task", Due: "03-01-2026"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(want), len(items), items)
+	}
+	for i := range want {
+		if items[i].Name != want[i].Name || items[i].Due != want[i].Due || items[i].Completed != want[i].Completed {
+			t.Errorf("Item %d: expected %+v, got %+v", i, want[i], items[i])
+		}
+	}
+	if len(rowErrs) != 2 || rowErrs[0].Row != 11 || rowErrs[1].Row != 12 {
+		t.Errorf("Expected errors on lines 11 and 12, got %+v", rowErrs)
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	items := []Item{
+		{Name: "Pay rent", Due: "05-01-2026", Completed: true},
+		{Name: "Book flights (cheap ones)", Due: "01-01-2026"},
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, items, MarkdownGroupCompletion); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	got, rowErrs, err := ReadMarkdown(&buf)
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ReadMarkdown failed: %v %+v", err, rowErrs)
+	}
+	// Grouping puts the open item first.
+	if len(got) != 2 || got[0].Name != items[1].Name || got[1].Name != items[0].Name || !got[1].Completed || got[0].Due != items[1].Due {
+		t.Errorf("Round trip changed the items: %+v", got)
+	}
+}
@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations_CoverEveryVersion(t *testing.T) {
+	if len(migrations) != SchemaVersion {
+		t.Fatalf("Expected %d migrations to reach schema version %d, got %d", SchemaVersion, SchemaVersion, len(migrations))
+	}
+	for i, m := range migrations {
+		if m.From != i {
+			t.Errorf("migrations[%d] upgrades from version %d; they must be in order", i, m.From)
+		}
+	}
+}
+
+// TestMigrate_V0ToV1 covers the original format: a bare array of items.
+func TestMigrate_V0ToV1(t *testing.T) {
+	v0 := `[{"ID":1,"Name":"Old task","Completed":false,"Due":"01-01-2025","Unknown":"kept"},{"ID":2,"Name":"Another","Completed":true,"Due":"02-01-2025"}]`
+	doc, from, err := Migrate([]byte(v0))
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if from != 0 {
+		t.Errorf("Expected a bare array to be version 0, got %d", from)
+	}
+
+	var got struct {
+		SchemaVersion int               `json:"schema_version"`
+		ItemCount     int               `json:"item_count"`
+		Items         []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(doc, &got); err != nil {
+		t.Fatalf("Migrated document is not an envelope: %v\n%s", err, doc)
+	}
+	if got.SchemaVersion != SchemaVersion || got.ItemCount != 2 || len(got.Items) != 2 {
+		t.Errorf("Unexpected envelope: %s", doc)
+	}
+	// Items are moved over untouched apart from the status added by v1 -> v2, including fields this version doesn't
+	// know about.
+	var first map[string]any
+	json.Unmarshal(got.Items[0], &first)
+	if first["Name"] != "Old task" || first["Unknown"] != "kept" || first["Status"] != "todo" {
+		t.Errorf("Item changed by the migration: %s", got.Items[0])
+	}
+
+	doc, _, err = Migrate([]byte(" []\n"))
+	if err != nil {
+		t.Fatalf("Migrate failed for an empty array: %v", err)
+	}
+	if snap, _, err := decodeSnapshot(doc); err != nil || len(snap.Items) != 0 {
+		t.Errorf("Expected an empty list, got %+v (%v)", snap, err)
+	}
+}
+
+func TestMigrate_CurrentVersionIsUnchanged(t *testing.T) {
+	current := `{"schema_version":2,"item_count":0,"items":[]}`
+	doc, from, err := Migrate([]byte(current))
+	if err != nil || from != SchemaVersion || string(doc) != current {
+		t.Errorf("Expected the document back unchanged, got %s from %d (%v)", doc, from, err)
+	}
+}
+
+func TestMigrate_V1ToV2(t *testing.T) {
+	v1 := `{"schema_version":1,"item_count":2,"items":[{"ID":1,"Name":"Open","Completed":false,"Due":"01-01-2025"},{"ID":2,"Name":"Closed","Completed":true,"Due":"01-01-2025"}]}`
+	doc, from, err := Migrate([]byte(v1))
+	if err != nil || from != 1 {
+		t.Fatalf("Migrate failed: %v (from %d)", err, from)
+	}
+	snap, _, err := decodeSnapshot(doc)
+	if err != nil {
+		t.Fatalf("Migrated document doesn't decode: %v\n%s", err, doc)
+	}
+	if snap.SchemaVersion != 2 || len(snap.Items) != 2 || snap.Items[0].Status != "todo" || snap.Items[1].Status != "done" {
+		t.Errorf("Expected statuses derived from Completed, got %+v", snap)
+	}
+}
+
+func TestMigrate_RejectsUnknownVersions(t *testing.T) {
+	for _, doc := range []string{`{"schema_version":99,"items":[]}`, `{"items":[]}`, `{"schema_version":-1}`, `"just a string"`} {
+		if _, _, err := Migrate([]byte(doc)); err == nil {
+			t.Errorf("Expected an error for %s", doc)
+		}
+	}
+}
+
+func TestLoadToDos_MigratesOldFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	v0 := `[{"ID":7,"Name":"From an old build","Completed":false,"Due":"01-01-2025"}]`
+	os.WriteFile(filename, []byte(v0), 0644)
+
+	ctx := context.Background()
+	todos, err := LoadToDos(filename, ctx)
+	if err != nil {
+		t.Fatalf("LoadToDos failed: %v", err)
+	}
+	if len(todos) != 1 || todos[0].ID != 7 || todos[0].Name != "From an old build" {
+		t.Errorf("Unexpected items: %+v", todos)
+	}
+	// The original file is kept before it can be overwritten in the new format.
+	if backup, err := os.ReadFile(filename + ".v0.bak"); err != nil || string(backup) != v0 {
+		t.Errorf("Expected the original file to be backed up, got %q (%v)", backup, err)
+	}
+
+	// Once saved, the file is in the current format and loads without migrating.
+	if err := SaveToDos(filename, todos, ctx); err != nil {
+		t.Fatalf("SaveToDos failed: %v", err)
+	}
+	data, _ := os.ReadFile(filename)
+	if _, from, err := decodeSnapshot(data); err != nil || from != SchemaVersion {
+		t.Errorf("Expected the saved file to be version %d, got %d (%v)", SchemaVersion, from, err)
+	}
+}
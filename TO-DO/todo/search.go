@@ -0,0 +1,143 @@
+package todo
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is one item matching a search, with its relevance score (higher is better).
+type SearchResult struct {
+	Item  Item    `json:"item"`
+	Score float64 `json:"score"`
+}
+
+// prefixWeight is how much a prefix match ("rep" for "report") counts compared with an exact one.
+const prefixWeight = 0.5
+
+// Index is an in-memory inverted index over the searchable text (name and notes) of items.
+// It is not safe for concurrent use; the actor owns it and keeps it up to date as items change.
+type Index struct {
+	postings map[string]map[int]int // token -> item ID -> number of occurrences
+	terms    []string               // every indexed token, sorted, for prefix lookups
+	docs     map[int]indexedItem    // item ID -> the item as indexed
+}
+
+type indexedItem struct {
+	item   Item
+	tokens []string // kept so the item can be removed from the postings again
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int]int), docs: make(map[int]indexedItem)}
+}
+
+// Tokenize splits text into lower-case words made of letters and digits.
+// "Call the garage +Car @phone" becomes [call the garage car phone].
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchableText is the text of an item that is indexed: its name and notes.
+func searchableText(item Item) string {
+	return item.Name + "\n" + item.Notes
+}
+
+// Add indexes item, replacing anything previously indexed under its ID.
+func (ix *Index) Add(item Item) {
+	ix.Remove(item.ID)
+	tokens := Tokenize(searchableText(item))
+	ix.docs[item.ID] = indexedItem{item: item, tokens: tokens}
+	for _, tok := range tokens {
+		ids, ok := ix.postings[tok]
+		if !ok {
+			ids = make(map[int]int)
+			ix.postings[tok] = ids
+			i, _ := slices.BinarySearch(ix.terms, tok)
+			ix.terms = slices.Insert(ix.terms, i, tok)
+		}
+		ids[item.ID]++
+	}
+}
+
+// Remove drops an item from the index. Removing an ID that isn't indexed does nothing.
+func (ix *Index) Remove(id int) {
+	for _, tok := range ix.docs[id].tokens {
+		ids := ix.postings[tok]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(ix.postings, tok)
+			if i, found := slices.BinarySearch(ix.terms, tok); found {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// Reset replaces the whole index with the given items.
+func (ix *Index) Reset(items []Item) {
+	*ix = *NewIndex()
+	for _, item := range items {
+		ix.Add(item)
+	}
+}
+
+// Search returns the items matching every word of query, best match first.
+// Each query word matches indexed words it equals or is a prefix of, ignoring case. Matches are scored by
+// TF-IDF: rare words count for more than common ones, exact matches count for more than prefix matches,
+// and a match in a short text counts for more than one in a long text. Ties are broken by ID.
+func (ix *Index) Search(query string) []SearchResult {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	n := float64(len(ix.docs))
+	var scores map[int]float64
+	for _, word := range words {
+		wordScores := make(map[int]float64)
+		// The sorted term list puts every token starting with word right after the position word would take.
+		for i := sort.SearchStrings(ix.terms, word); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], word); i++ {
+			term := ix.terms[i]
+			ids := ix.postings[term]
+			weight := math.Log(1 + n/float64(len(ids)))
+			if term != word {
+				weight *= prefixWeight
+			}
+			for id, count := range ids {
+				wordScores[id] += float64(count) * weight
+			}
+		}
+		// Every word must match, so only items found for all words so far are kept.
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := wordScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		doc := ix.docs[id]
+		results = append(results, SearchResult{Item: doc.item, Score: score / math.Sqrt(float64(len(doc.tokens)))})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Item.ID < results[j].Item.ID
+	})
+	return results
+}
@@ -0,0 +1,139 @@
+package todo
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func resultIDs(results []SearchResult) []int {
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.Item.ID)
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Call the GARAGE +Car @phone, re-check 2x Ünïcode")
+	want := []string{"call", "the", "garage", "car", "phone", "re", "check", "2x", "ünïcode"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokenize\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := NewIndex()
+	ix.Reset([]Item{
+		{ID: 1, Name: "Repair the garage door"},
+		{ID: 2, Name: "Write quarterly report"},
+		{ID: 3, Name: "Report the broken garage light to the landlord"},
+		{ID: 4, Name: "Replace bike tyre"},
+	})
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"garage", []int{1, 3}},       // the shorter name ranks first
+		{"GARAGE", []int{1, 3}},       // case-folded
+		{"rep", []int{4, 1, 2, 3}},    // prefix of replace, repair and report; "report" is in two items so counts for less
+		{"rep gar", []int{1, 3}},      // every word must match
+		{"report", []int{2, 3}},       // an exact match beats "repair"
+		{"landlord garage", []int{3}}, // word order doesn't matter
+		{"garden", nil},
+		{"  ...  ", nil},
+	}
+	for _, tt := range tests {
+		if got := resultIDs(ix.Search(tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIndex_Search_RanksRareWordsHigher(t *testing.T) {
+	ix := NewIndex()
+	ix.Reset([]Item{
+		{ID: 1, Name: "buy milk"},
+		{ID: 2, Name: "buy bread"},
+		{ID: 3, Name: "buy stamps"},
+	})
+	// Both words appear in one item each, but "buy" appears in all of them, so it adds nothing.
+	results := ix.Search("buy milk")
+	if len(results) != 1 || results[0].Item.ID != 1 || results[0].Score <= 0 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestIndex_SearchesNotes(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(Item{ID: 1, Name: "Renew passport", Notes: "Take the **old passport** and two photos to the post office."})
+	ix.Add(Item{ID: 2, Name: "Post office"})
+	if got := resultIDs(ix.Search("photos")); !slices.Equal(got, []int{1}) {
+		t.Errorf("Expected a match in the notes, got %v", got)
+	}
+	if got := resultIDs(ix.Search("post office")); !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("Expected both items, shorter text first, got %v", got)
+	}
+}
+
+func TestIndex_AddAndRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(Item{ID: 1, Name: "Pay rent"})
+	ix.Add(Item{ID: 1, Name: "Pay council tax"}) // re-adding replaces the old text
+	if got := ix.Search("rent"); len(got) != 0 {
+		t.Errorf("Expected the old name to be forgotten, got %+v", got)
+	}
+	if got := ix.Search("council"); len(got) != 1 || got[0].Item.Name != "Pay council tax" {
+		t.Errorf("Expected the new name to be found, got %+v", got)
+	}
+
+	ix.Remove(1)
+	ix.Remove(42) // not indexed
+	if len(ix.terms) != 0 || len(ix.postings) != 0 || len(ix.docs) != 0 {
+		t.Errorf("Expected an empty index, got terms=%v", ix.terms)
+	}
+}
+
+func TestStartStore_Search(t *testing.T) {
+	Store = make(chan Command)
+	StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(Store) })
+
+	send := func(cmd Command) any {
+		cmd.Result, cmd.ErrChan = make(chan any), make(chan error)
+		Store <- cmd
+		select {
+		case r := <-cmd.Result:
+			return r
+		case err := <-cmd.ErrChan:
+			t.Fatalf("Command failed: %v", err)
+			return nil
+		}
+	}
+	search := func(q string) []int {
+		return resultIDs(send(Command{Action: OpSearch, Query: q}).([]SearchResult))
+	}
+
+	send(Command{Action: OpAdd, Item: Item{Name: "Book flights", Due: "01-01-2026"}})
+	send(Command{Action: OpAdd, Item: Item{Name: "Book hotel", Due: "01-01-2026"}})
+	if got := search("book"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("Expected both items, got %v", got)
+	}
+
+	name := "Cancel hotel"
+	send(Command{Action: OpUpdate, ID: 2, UpdatePayload: ItemUpdate{Name: &name}})
+	if got := search("book"); !slices.Equal(got, []int{1}) {
+		t.Errorf("Expected the renamed item to be reindexed, got %v", got)
+	}
+
+	send(Command{Action: OpDelete, ID: 1})
+	if got := search("flights"); len(got) != 0 {
+		t.Errorf("Expected the deleted item to be gone, got %v", got)
+	}
+
+	send(Command{Action: OpRestore, Items: []Item{{ID: 7, Name: "Restored flights", Due: "01-01-2026"}}})
+	if got := search("flights"); !slices.Equal(got, []int{7}) {
+		t.Errorf("Expected the restored list to be indexed, got %v", got)
+	}
+}
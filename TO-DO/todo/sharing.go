@@ -0,0 +1,248 @@
+package todo
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// Role is what a user may do with an item or a list. Each role includes the ones before it:
+// viewers can see, editors can also change, and owners can also delete and share.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ErrForbidden is returned when the caller can see an item or list but their role doesn't allow the change.
+// Items the caller can't see at all are reported as not found instead, so that their existence doesn't leak.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrNotFound is wrapped by the errors for items, lists, attachments, comments and time entries that don't
+// exist, or that the caller may not see.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidRole is returned for a role other than viewer, editor or owner.
+var ErrInvalidRole = errors.New("role must be viewer, editor or owner")
+
+// Includes reports whether r allows everything other allows.
+func (r Role) Includes(other Role) bool {
+	return roleRank[r] >= roleRank[other] && roleRank[r] > 0
+}
+
+// ParseRole checks a role from a client.
+func ParseRole(s string) (Role, error) {
+	if r := Role(s); roleRank[r] > 0 {
+		return r, nil
+	}
+	return "", fmt.Errorf("%w, got %q", ErrInvalidRole, s)
+}
+
+// ListAccess records who owns a list and who it is shared with. A list is claimed by the first user to put an
+// item in it (or to share it); its roles then apply to every item in it, on top of the items' own.
+type ListAccess struct {
+	Owner  string          `json:"owner"`
+	Shares map[string]Role `json:"shares,omitempty"` // by user ID
+}
+
+// Share gives a user a role on an item or list, for OpShare. An empty Role removes the user's share.
+type Share struct {
+	UserID string `json:"user_id"`
+	Role   Role   `json:"role,omitempty"`
+}
+
+// caller returns the ID of the user a command was sent for. Commands without a user, such as those of the
+// reminder scheduler, come from the server itself and may do anything.
+func caller(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	u, ok := auth.UserFrom(ctx)
+	return u.ID, ok
+}
+
+// RoleFor returns the user's role on an item: the highest of the item's own and that of its list.
+// Items from before there were accounts have no owner, and stay open to everyone as they were.
+func RoleFor(item Item, lists map[string]ListAccess, userID string) Role {
+	if item.Owner == "" || item.Owner == userID {
+		return RoleOwner
+	}
+	role := item.Shares[userID]
+	if access, ok := lists[ListOf(item)]; ok && ListOf(item) != "" {
+		listRole := access.Shares[userID]
+		if access.Owner == userID {
+			listRole = RoleOwner
+		}
+		if roleRank[listRole] > roleRank[role] {
+			role = listRole
+		}
+	}
+	return role
+}
+
+// ListRole returns the user's role on a list. Unclaimed lists, including "" (no list), belong to everyone.
+func ListRole(lists map[string]ListAccess, list, userID string) Role {
+	access, ok := lists[list]
+	if !ok || list == "" || access.Owner == userID {
+		return RoleOwner
+	}
+	return access.Shares[userID]
+}
+
+// Visible returns the items the caller may see. The result shares no memory with items.
+func Visible(items []Item, lists map[string]ListAccess, ctx context.Context) []Item {
+	userID, ok := caller(ctx)
+	visible := make([]Item, 0, len(items))
+	for _, item := range items {
+		if !ok || RoleFor(item, lists, userID).Includes(RoleViewer) {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
+
+// authorize checks that the caller has at least the needed role on the item with the given ID. An item the
+// caller can't see is reported exactly like one that doesn't exist.
+func authorize(items []Item, lists map[string]ListAccess, ctx context.Context, id int, needed Role) error {
+	userID, ok := caller(ctx)
+	i := indexOf(items, id)
+	if !ok || i < 0 {
+		return nil // the command itself reports a missing item
+	}
+	role := RoleFor(items[i], lists, userID)
+	switch {
+	case !role.Includes(RoleViewer):
+		return fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	case !role.Includes(needed):
+		return fmt.Errorf("%w: item %d needs the %s role", ErrForbidden, id, needed)
+	}
+	return nil
+}
+
+// authorizeList checks that the caller has at least the needed role on a list. A list the caller has no role
+// on is reported as not found.
+func authorizeList(lists map[string]ListAccess, ctx context.Context, list string, needed Role) error {
+	userID, ok := caller(ctx)
+	if !ok {
+		return nil
+	}
+	role := ListRole(lists, list, userID)
+	switch {
+	case !role.Includes(RoleViewer):
+		return fmt.Errorf("list %q %w", list, ErrNotFound)
+	case !role.Includes(needed):
+		return fmt.Errorf("%w: list %q needs the %s role", ErrForbidden, list, needed)
+	}
+	return nil
+}
+
+// claimList makes the caller the owner of a list nobody has claimed yet, and returns the updated lists.
+// The map is copied rather than changed in place, since snapshots may share it.
+func claimList(lists map[string]ListAccess, ctx context.Context, list string) map[string]ListAccess {
+	userID, ok := caller(ctx)
+	if _, claimed := lists[list]; !ok || claimed || list == "" {
+		return lists
+	}
+	updated := maps.Clone(lists)
+	if updated == nil {
+		updated = make(map[string]ListAccess)
+	}
+	updated[list] = ListAccess{Owner: userID}
+	return updated
+}
+
+// ShareToDo gives share.UserID share.Role on the item with the given ID, or takes their share away if the
+// role is empty. The owner's own role can't be changed.
+func ShareToDo(toDos []Item, id int, share Share) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	if share.Role != "" && roleRank[share.Role] == 0 {
+		return toDos, ErrInvalidRole
+	}
+	if toDos[i].Owner == "" || share.UserID == toDos[i].Owner {
+		return toDos, fmt.Errorf("%w: the item's owner can't be given a share, and items from before accounts can't be shared", ErrForbidden)
+	}
+	toDos[i].Shares = withShare(toDos[i].Shares, share)
+	return toDos, nil
+}
+
+// ShareList gives share.UserID share.Role on a list, claiming it for owner if nobody has yet, or takes their
+// share away if the role is empty.
+func ShareList(lists map[string]ListAccess, list, owner string, share Share) (map[string]ListAccess, error) {
+	if list == "" {
+		return lists, errors.New("list name cannot be empty")
+	}
+	if share.Role != "" && roleRank[share.Role] == 0 {
+		return lists, ErrInvalidRole
+	}
+	access, ok := lists[list]
+	if !ok {
+		access = ListAccess{Owner: owner}
+	}
+	if access.Owner == "" || share.UserID == access.Owner {
+		return lists, fmt.Errorf("%w: the list's owner can't be given a share", ErrForbidden)
+	}
+	access.Shares = withShare(access.Shares, share)
+	updated := maps.Clone(lists)
+	if updated == nil {
+		updated = make(map[string]ListAccess)
+	}
+	updated[list] = access
+	return updated, nil
+}
+
+// withShare returns a copy of shares with share applied.
+func withShare(shares map[string]Role, share Share) map[string]Role {
+	updated := maps.Clone(shares)
+	if share.Role == "" {
+		delete(updated, share.UserID)
+	} else {
+		if updated == nil {
+			updated = make(map[string]Role)
+		}
+		updated[share.UserID] = share.Role
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return updated
+}
+
+// readers returns the IDs of the users who may see an item, or nil if everyone may.
+func readers(item Item, lists map[string]ListAccess) []string {
+	if item.Owner == "" {
+		return nil
+	}
+	ids := []string{item.Owner}
+	add := func(shares map[string]Role) {
+		for id, role := range shares {
+			if role.Includes(RoleViewer) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	add(item.Shares)
+	if access, ok := lists[ListOf(item)]; ok && ListOf(item) != "" {
+		ids = append(ids, access.Owner)
+		add(access.Shares)
+	}
+	return ids
+}
+
+// validateShares checks the roles of item or list shares from a backup.
+func validateShares(shares map[string]Role) error {
+	for userID, role := range shares {
+		if userID == "" || roleRank[role] == 0 {
+			return fmt.Errorf("invalid share %q: %q", userID, role)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package todo
+
+import (
+	"GoAcademy/TO-DO/auth"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoleFor(t *testing.T) {
+	lists := map[string]ListAccess{"Home": {Owner: "ana", Shares: map[string]Role{"bob": RoleViewer}}}
+	for _, tc := range []struct {
+		item Item
+		user string
+		want Role
+	}{
+		{Item{}, "eve", RoleOwner}, // from before there were accounts
+		{Item{Owner: "ana"}, "ana", RoleOwner},
+		{Item{Owner: "ana"}, "bob", ""},
+		{Item{Owner: "ana", Shares: map[string]Role{"bob": RoleEditor}}, "bob", RoleEditor},
+		{Item{Owner: "eve", Projects: []string{"Home"}}, "ana", RoleOwner},
+		{Item{Owner: "ana", Projects: []string{"Home"}}, "bob", RoleViewer},
+		{Item{Owner: "ana", Projects: []string{"Home"}, Shares: map[string]Role{"bob": RoleEditor}}, "bob", RoleEditor},
+		{Item{Owner: "ana", Projects: []string{"Work"}}, "bob", ""}, // unclaimed lists grant nothing
+	} {
+		if got := RoleFor(tc.item, lists, tc.user); got != tc.want {
+			t.Errorf("RoleFor(%+v, %s): expected %q, got %q", tc.item, tc.user, tc.want, got)
+		}
+	}
+}
+
+func TestStartStore_Sharing(t *testing.T) {
+	events, unsubscribe := Events.Subscribe(20)
+	t.Cleanup(unsubscribe)
+
+	Store = make(chan Command)
+	StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(Store) })
+
+	ana := auth.WithUser(context.Background(), auth.User{ID: "ana"})
+	bob := auth.WithUser(context.Background(), auth.User{ID: "bob"})
+	send := func(ctx context.Context, cmd Command) (any, error) {
+		cmd.Ctx = ctx
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		Store <- cmd
+		select {
+		case result := <-cmd.Result:
+			return result, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+	get := func(ctx context.Context) []Item {
+		result, _ := send(ctx, Command{Action: OpGet})
+		return result.([]Item)
+	}
+
+	send(ana, Command{Action: OpAdd, Item: Item{Name: "Paint the fence", Due: "01-05-2026", Projects: []string{"Home"}}})
+	send(bob, Command{Action: OpAdd, Item: Item{Name: "Bob's task", Due: "01-05-2026"}})
+	if items := get(ana); len(items) != 1 || items[0].Owner != "ana" {
+		t.Fatalf("Expected ana to see only her item, got %+v", items)
+	}
+	if items := get(context.Background()); len(items) != 2 {
+		t.Errorf("Expected the server itself to see every item, got %d", len(items))
+	}
+
+	// Items bob can't see are not found, exactly like missing ones.
+	name := "Hijacked"
+	_, err := send(bob, Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Name: &name}})
+	if err == nil || err.Error() != "item with id 1 not found" {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	if _, err := send(bob, Command{Action: OpAdd, Item: Item{Name: "Sneak in", Due: "01-05-2026", Projects: []string{"Home"}}}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected bob not to find ana's list, got %v", err)
+	}
+	if result, _ := send(bob, Command{Action: OpSearch, Query: "fence"}); len(result.([]SearchResult)) != 0 {
+		t.Errorf("Expected search to hide ana's item, got %+v", result)
+	}
+
+	// Sharing the list as a viewer lets bob see, but not change, the items in it.
+	if _, err := send(bob, Command{Action: OpShare, List: "Home", Share: Share{UserID: "bob", Role: RoleOwner}}); err == nil {
+		t.Errorf("Expected bob not to share ana's list")
+	}
+	if _, err := send(ana, Command{Action: OpShare, List: "Home", Share: Share{UserID: "bob", Role: RoleViewer}}); err != nil {
+		t.Fatalf("Sharing the list failed: %v", err)
+	}
+	if items := get(bob); len(items) != 2 {
+		t.Errorf("Expected bob to see the shared list, got %+v", items)
+	}
+	if _, err := send(bob, Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Name: &name}}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected a viewer to be forbidden to edit, got %v", err)
+	}
+
+	// Editors can edit, but only owners can delete.
+	if _, err := send(ana, Command{Action: OpShare, ID: 1, Share: Share{UserID: "bob", Role: RoleEditor}}); err != nil {
+		t.Fatalf("Sharing the item failed: %v", err)
+	}
+	if _, err := send(bob, Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Name: &name}}); err != nil {
+		t.Errorf("Expected an editor to edit, got %v", err)
+	}
+	if _, err := send(bob, Command{Action: OpDelete, ID: 1}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected an editor to be forbidden to delete, got %v", err)
+	}
+	if _, err := send(bob, Command{Action: OpRestore}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected bob to be forbidden to replace ana's items, got %v", err)
+	}
+
+	// Events say who may see them.
+	for e := range events {
+		if e.Type == EventUpdated && e.ItemID == 1 && e.Item.Name == name {
+			if !e.VisibleTo("ana") || !e.VisibleTo("bob") || e.VisibleTo("eve") {
+				t.Errorf("Unexpected readers %v", e.Readers)
+			}
+			break
+		}
+	}
+	// A snapshot only has the workflows and custom fields of lists the caller has a role on.
+	eve := auth.WithUser(context.Background(), auth.User{ID: "eve"})
+	if _, err := send(ana, Command{Action: OpSetWorkflow, List: "Home", Workflow: &Workflow{States: []WorkflowState{{Name: "todo"}, {Name: "done", Done: true}}}}); err != nil {
+		t.Fatalf("Setting the workflow failed: %v", err)
+	}
+	if _, err := send(ana, Command{Action: OpSetFields, List: "Home", FieldDefs: []FieldDef{{Name: "room", Type: FieldText}}}); err != nil {
+		t.Fatalf("Setting the fields failed: %v", err)
+	}
+	for ctx, visible := range map[context.Context]bool{bob: true, eve: false} {
+		result, _ := send(ctx, Command{Action: OpSnapshot})
+		snap := result.(Snapshot)
+		if _, ok := snap.Workflows["Home"]; ok != visible {
+			t.Errorf("Expected the workflow to be visible %v, got %+v", visible, snap.Workflows)
+		}
+		if _, ok := snap.Fields["Home"]; ok != visible {
+			t.Errorf("Expected the fields to be visible %v, got %+v", visible, snap.Fields)
+		}
+	}
+
+	// Stopping a timer needs a role on the item it runs on, whoever's timer it is.
+	if _, err := send(ana, Command{Action: OpStartTimer, ID: 1, TimeEntry: TimeEntry{ID: "t1", User: "ana", Start: time.Now().UTC()}}); err != nil {
+		t.Fatalf("Starting the timer failed: %v", err)
+	}
+	if _, err := send(eve, Command{Action: OpStopTimer, TimeEntry: TimeEntry{User: "ana"}}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected eve not to stop a timer on ana's item, got %v", err)
+	}
+	if _, err := send(ana, Command{Action: OpStopTimer, TimeEntry: TimeEntry{User: "ana"}}); err != nil {
+		t.Errorf("Expected ana to stop her timer, got %v", err)
+	}
+}
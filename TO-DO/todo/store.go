@@ -1,7 +1,13 @@
 package todo
 
 import (
+	"GoAcademy/TO-DO/auth"
 	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
 )
 
 // Define the types of actions our actor can perform.
@@ -14,6 +20,27 @@ const (
 	OpUpdate
 	OpDelete
 	OpShutdown
+	OpImport  // adds every item in Command.Items in one step
+	OpRestore // replaces the whole list with Command.Items, keeping their IDs
+	OpSearch  // full-text search for Command.Query; the result is []SearchResult
+	OpAttach  // adds Command.Attachment to the item with Command.ID
+	OpDetach  // removes the attachment with Command.AttachmentID from the item with Command.ID
+
+	OpComment       // adds Command.Comment to the item with Command.ID
+	OpEditComment   // replaces the body of the comment with Command.Comment.ID with Command.Comment.Body
+	OpDeleteComment // removes the comment with Command.Comment.ID from the item with Command.ID
+
+	OpStartTimer      // starts a timer for Command.TimeEntry.User on the item with Command.ID
+	OpStopTimer       // stops the running timer of Command.TimeEntry.User, wherever it is
+	OpAddTimeEntry    // adds the finished Command.TimeEntry to the item with Command.ID
+	OpDeleteTimeEntry // removes the time entry with Command.TimeEntry.ID from the item with Command.ID
+
+	OpTransition  // moves the item with Command.ID to Command.Status, if its workflow allows it
+	OpSetWorkflow // sets the workflow of Command.List to Command.Workflow, or removes it if that is nil
+	OpSnapshot    // the result is a Snapshot of the list, the workflows and the custom fields, e.g. for a backup
+	OpSetFields   // sets the custom fields of Command.List to Command.FieldDefs, or removes them if that is empty
+	OpShare       // applies Command.Share to the item with Command.ID or, if that is 0, to Command.List
+	OpAssign      // assigns the item with Command.ID to Command.Assignees, replacing its assignees
 )
 
 // Command is the message we'll send to the actor.
@@ -21,17 +48,29 @@ const (
 type Command struct {
 	Action Op //holds the operation type, and will be one of the Op constants
 	Item   Item
-	// UpdatePayload holds pointers for partial updates.
-	// This allows us to distinguish between a zero value (e.g., "") and a field that wasn't provided.
-	UpdatePayload struct {
-		Name      *string
-		Due       *string
-		Completed *bool
-	}
-	ID      int
-	Ctx     context.Context // Context for managing request-scoped values
-	Result  chan any        // Channel to send result back to the caller; the channel is defined as bidirectional so that both sending and receiving are possible; any means any type
-	ErrChan chan error      // Channel to send error back to the caller
+	// UpdatePayload holds pointers for partial updates (see ItemUpdate).
+	UpdatePayload ItemUpdate
+	ID            int
+	Items         []Item                // Items to add for OpImport, or the new list for OpRestore
+	Workflows     map[string]Workflow   // The new workflows for OpRestore
+	Fields        map[string][]FieldDef // The new custom field definitions for OpRestore
+	Lists         map[string]ListAccess // The new list owners and shares for OpRestore
+	Query         string                // Search terms for OpSearch
+	Attachment    Attachment            // Attachment to add for OpAttach; its blob must already be in Blobs
+	AttachmentID  string                // Attachment to remove for OpDetach
+	Comment       Comment               // Comment to add, edit or delete for OpComment, OpEditComment and OpDeleteComment
+	TimeEntry     TimeEntry             // Time entry to start, stop, add or delete for the timer and time entry ops
+	Status        string                // Status to move to for OpTransition
+	List          string                // List whose workflow OpSetWorkflow sets
+	Workflow      *Workflow             // Workflow for OpSetWorkflow; nil removes the list's own workflow
+	FieldDefs     []FieldDef            // Custom fields for OpSetFields
+	Share         Share                 // Share to give or take away for OpShare
+	Assignees     []auth.User           // Users to assign for OpAssign
+	// Ctx carries request-scoped values, including the user the command is sent for (see auth.WithUser).
+	// Commands only see and change what that user may; commands without a user come from the server itself.
+	Ctx     context.Context
+	Result  chan any   // Channel to send result back to the caller; the channel is defined as bidirectional so that both sending and receiving are possible; any means any type
+	ErrChan chan error // Channel to send error back to the caller
 }
 
 // The Store is our actor. It holds the channels.
@@ -47,80 +86,515 @@ func StartStore(filename string) {
 
 		// Load initial data. We do this once inside the actor goroutine
 		// to ensure no other part of the app can access the list while it's loading.
-		var err error
-		ToDos, err = LoadToDos(filename, context.Background())
+		snap, err := LoadSnapshot(filename, context.Background())
 		if err != nil {
 			// If loading fails, we can't safely proceed.
 			panic(err)
 		}
-		// If loading succeeds, we can start processing commands.
-		// The initial list of ToDos is now available.
+		runStore(Store, StoreConfig{Filename: filename}, snap)
+	}()
+}
+
+// runStore is the actor: it owns the list loaded from cfg.Filename and runs the commands sent on store, one at a time,
+// until OpShutdown.
+func runStore(store chan Command, cfg StoreConfig, snap Snapshot) {
+	filename := cfg.Filename
+	self := Instance{Tenant: cfg.Tenant, Store: store, Blobs: cfg.Blobs}
+	toDos := snap.Items
+	// The workflows of the lists are owned by the actor too, since every status change is checked against them.
+	workflows := snap.Workflows
+	// So are the custom field definitions, which every value is checked against.
+	fields := snap.Fields
+	// And so are the owners and shares of the lists, which every command is checked against.
+	lists := snap.Lists
+	for i := range toDos {
+		syncStatus(&toDos[i], WorkflowFor(workflows, toDos[i]))
+	}
+	// If loading succeeds, we can start processing commands.
+	// The initial list of toDos is now available.
+
+	var maxID int
+	for _, item := range toDos {
+		if item.ID > maxID {
+			maxID = item.ID
+		}
+	}
+
+	// Clear out blobs left behind by uploads that never got attached, e.g. because the server stopped mid-upload.
+	if n, err := self.blobStore().Sweep(toDos, orphanGracePeriod); err != nil {
+		slog.Default().Log(context.Background(), slog.LevelWarn, "Failed to sweep orphaned attachment blobs", "error", err)
+	} else if n > 0 {
+		slog.Default().Log(context.Background(), slog.LevelInfo, "Swept orphaned attachment blobs", "count", n)
+	}
 
-		var maxID int
-		for _, item := range ToDos {
-			if item.ID > maxID {
-				maxID = item.ID
+	// The search index is owned by the actor like the list itself, and updated by every command that changes the list.
+	index := NewIndex()
+	index.Reset(toDos)
+
+	// publish tells subscribers about a change, along with who may see it.
+	publish := func(ctx context.Context, e Event) {
+		if e.ItemID != 0 {
+			e.Readers = readers(e.Item, lists)
+		}
+		e.Tenant = cfg.Tenant
+		Events.Publish(ctx, e)
+	}
+
+	// This is the actor's main loop. It waits for commands on the 'store' channel.
+	// Using for and range to continuously listen for incoming commands and also to make sure each
+	// command is processed one at a time in the order received.
+	for cmd := range store {
+		// Whatever the command does with attachments is done with this actor's blobs.
+		if cmd.Ctx == nil {
+			cmd.Ctx = context.Background()
+		}
+		cmd.Ctx = WithInstance(cmd.Ctx, self)
+
+		// Commands on one item need a role on it: editor to change it, owner to delete or share it.
+		// The command isn't run if the caller doesn't have it.
+		if needed, ok := requiredRole[cmd.Action]; ok {
+			if err := authorize(toDos, lists, cmd.Ctx, cmd.ID, needed); err != nil {
+				if cmd.Action == OpAttach {
+					deleteOrphans(toDos, []Attachment{cmd.Attachment}, cmd.Ctx)
+				}
+				cmd.ErrChan <- err
+				continue
 			}
 		}
 
-		// This is the actor's main loop. It waits for commands on the 'store' channel.
-		// Using for and range to continuously listen for incoming commands and also to make sure each
-		// command is processed one at a time in the order received.
-		for cmd := range Store {
-			switch cmd.Action {
-			case OpGet:
-				// Create a copy to send back, preventing race conditions.
-				// The caller gets a snapshot, not a direct reference.
-				listCopy := make([]Item, len(ToDos))
-				copy(listCopy, ToDos)
-				cmd.Result <- listCopy //Sending back on the Result channel that was defined in the Command struct as part of the command message.
-			case OpAdd:
-				maxID++
-				cmd.Item.ID = maxID
-				var err error
-				ToDos, err = AddToDo(ToDos, cmd.Item.ID, cmd.Item.Name, cmd.Item.Due, cmd.Ctx)
-				if err != nil {
-					cmd.ErrChan <- err
-				} else {
-					cmd.Result <- cmd.Item // Acknowledge completion by returning the added item.
+		switch cmd.Action {
+		case OpGet:
+			// Create a copy to send back, preventing race conditions.
+			// The caller gets a snapshot, not a direct reference, holding only the items they may see.
+			listCopy := Visible(toDos, lists, cmd.Ctx)
+			cmd.Result <- listCopy //Sending back on the Result channel that was defined in the Command struct as part of the command message.
+		case OpSnapshot:
+			snap := NewSnapshot(Visible(toDos, lists, cmd.Ctx))
+			snap.Workflows = maps.Clone(workflows)
+			snap.Fields = maps.Clone(fields)
+			snap.Lists = maps.Clone(lists)
+			if userID, ok := caller(cmd.Ctx); ok {
+				hidden := func(list string) bool { return !ListRole(lists, list, userID).Includes(RoleViewer) }
+				maps.DeleteFunc(snap.Lists, func(list string, _ ListAccess) bool { return hidden(list) })
+				maps.DeleteFunc(snap.Workflows, func(list string, _ Workflow) bool { return hidden(list) })
+				maps.DeleteFunc(snap.Fields, func(list string, _ []FieldDef) bool { return hidden(list) })
+			}
+			cmd.Result <- snap
+		case OpSearch:
+			results := index.Search(cmd.Query)
+			if userID, ok := caller(cmd.Ctx); ok {
+				results = slices.DeleteFunc(results, func(r SearchResult) bool { return !RoleFor(r.Item, lists, userID).Includes(RoleViewer) })
+			}
+			cmd.Result <- results
+		case OpAdd:
+			// Adding to a list someone else owns needs the editor role on it; a new list is claimed by the caller.
+			if err := authorizeList(lists, cmd.Ctx, ListOf(cmd.Item), RoleEditor); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			if err := checkItemQuota(cfg.MaxItems, len(toDos)+1); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			// The status and custom fields are checked first so that a rejected item doesn't use up an ID.
+			status, err := initialStatus(workflows, cmd.Item, cmd.Item.Status)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			cmd.Item.Fields, err = normalizeFields(FieldsFor(fields, cmd.Item), cmd.Item.Fields)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			maxID++
+			cmd.Item.ID = maxID
+			toDos, err = AddToDo(toDos, cmd.Item.ID, cmd.Item.Name, cmd.Item.Due, cmd.Ctx)
+			if err != nil {
+				cmd.ErrChan <- err
+			} else {
+				added := &toDos[len(toDos)-1]
+				copyOptionalFields(added, cmd.Item)
+				added.Owner, _ = caller(cmd.Ctx)
+				lists = claimList(lists, cmd.Ctx, ListOf(*added))
+				setStatus(added, WorkflowFor(workflows, *added), status, added.CreatedAt)
+				index.Add(*added)
+				// Events are published before replying so that subscribers have been told about a change by the time its caller carries on.
+				publish(cmd.Ctx, Event{Type: EventCreated, ItemID: added.ID, Item: *added})
+				cmd.Result <- *added // Acknowledge completion by returning the added item.
+			}
+		case OpUpdate:
+			var err error
+
+			//Need to pass the memory address (&) of the fields to update to prevent situations where a user may not want to
+			// update completed (for example) and leaves it blank, which would default to false if not using pointers and addresses.
+
+			// Remember whether the item was already completed so that a transition can be reported as its own event.
+			wasCompleted := false
+			for _, item := range toDos {
+				if item.ID == cmd.ID {
+					wasCompleted = item.Completed
+					// Custom field values are checked against the item's list, which only the actor knows about.
+					if cmd.UpdatePayload.Fields != nil {
+						cmd.UpdatePayload.Fields, err = normalizeFieldUpdate(FieldsFor(fields, item), cmd.UpdatePayload.Fields)
+					}
+					break
 				}
-			case OpUpdate:
-				var err error
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
 
-				//Need to pass the memory address (&) of the fields to update to prevent situations where a user may not want to
-				// update completed (for example) and leaves it blank, which would default to false if not using pointers and addresses.
+			toDos, err = UpdateToDo(toDos, cmd.ID, cmd.UpdatePayload, cmd.Ctx)
+			if err != nil {
+				cmd.ErrChan <- err
+			} else {
+				var updatedItem Item
+				for i := range toDos {
+					if toDos[i].ID == cmd.ID {
+						syncStatus(&toDos[i], WorkflowFor(workflows, toDos[i]))
+						updatedItem = toDos[i]
+						break
+					}
+				}
+				index.Add(updatedItem)
+				eventType := EventUpdated
+				if updatedItem.Completed && !wasCompleted {
+					eventType = EventCompleted
+				}
+				publish(cmd.Ctx, Event{Type: eventType, ItemID: updatedItem.ID, Item: updatedItem})
+				cmd.Result <- updatedItem
+			}
+		case OpDelete:
+			// Keep a copy of the item so the deleted event can describe what was removed.
+			var removed Item
+			for _, item := range toDos {
+				if item.ID == cmd.ID {
+					removed = item
+					break
+				}
+			}
 
-				ToDos, err = UpdateToDo(ToDos, cmd.ID, cmd.UpdatePayload.Name, cmd.UpdatePayload.Due, cmd.UpdatePayload.Completed, cmd.Ctx)
-				if err != nil {
-					cmd.ErrChan <- err
-				} else {
-					var updatedItem Item
-					for _, item := range ToDos {
-						if item.ID == cmd.ID {
-							updatedItem = item
-							break
-						}
+			var err error
+			toDos, err = RemoveToDo(toDos, cmd.ID, cmd.Ctx)
+			if err != nil {
+				cmd.ErrChan <- err
+			} else {
+				index.Remove(removed.ID)
+				publish(cmd.Ctx, Event{Type: EventDeleted, ItemID: removed.ID, Item: removed})
+				cmd.Result <- "success"
+			}
+		case OpImport:
+			// Items are numbered exactly as if each had been sent with OpAdd, but the whole batch is applied
+			// before any other command can run, so an import never interleaves with other changes.
+			// Like OpAdd, it needs the editor role on every list it adds to, and custom field values that suit their
+			// lists, and is refused as a whole otherwise.
+			var err error
+			for i, item := range cmd.Items {
+				if err = authorizeList(lists, cmd.Ctx, ListOf(item), RoleEditor); err != nil {
+					break
+				}
+				if cmd.Items[i].Fields, err = normalizeFields(FieldsFor(fields, item), item.Fields); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = checkItemQuota(cfg.MaxItems, len(toDos)+len(cmd.Items))
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			added := make([]Item, 0, len(cmd.Items))
+			for _, item := range cmd.Items {
+				maxID++
+				toDos, _ = AddToDo(toDos, maxID, item.Name, item.Due, cmd.Ctx)
+				stored := &toDos[len(toDos)-1]
+				copyOptionalFields(stored, item)
+				stored.Owner, _ = caller(cmd.Ctx)
+				lists = claimList(lists, cmd.Ctx, ListOf(*stored))
+				// Keep the creation date of items coming from another system.
+				if !item.CreatedAt.IsZero() {
+					stored.CreatedAt = item.CreatedAt
+				}
+				// Unlike OpAdd, an import may bring in items that are already done.
+				if item.Completed {
+					stored.Completed = true
+					stored.CompletedAt = item.CompletedAt
+					if stored.CompletedAt.IsZero() {
+						stored.CompletedAt = stored.CreatedAt
 					}
-					cmd.Result <- updatedItem
 				}
-			case OpDelete:
-				var err error
-				ToDos, err = RemoveToDo(ToDos, cmd.ID, cmd.Ctx)
+				syncStatus(stored, WorkflowFor(workflows, *stored))
+				index.Add(*stored)
+				added = append(added, *stored)
+			}
+			for _, item := range added {
+				publish(cmd.Ctx, Event{Type: EventCreated, ItemID: item.ID, Item: item})
+			}
+			cmd.Result <- added
+		case OpRestore:
+			// A restore replaces everything, so a user may only restore if they own every item there is now.
+			if userID, ok := caller(cmd.Ctx); ok && slices.ContainsFunc(toDos, func(item Item) bool { return !RoleFor(item, lists, userID).Includes(RoleOwner) }) {
+				cmd.ErrChan <- fmt.Errorf("%w: restoring would replace items you don't own", ErrForbidden)
+				continue
+			}
+			if err := checkItemQuota(cfg.MaxItems, len(cmd.Items)); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			// The restored list is written to disk before it replaces the one in memory,
+			// so a failed save leaves both the file and the running list untouched.
+			restored := NewSnapshot(slices.Clone(cmd.Items))
+			restored.Workflows = maps.Clone(cmd.Workflows)
+			restored.Fields = maps.Clone(cmd.Fields)
+			restored.Lists = maps.Clone(cmd.Lists)
+			for i := range restored.Items {
+				syncStatus(&restored.Items[i], WorkflowFor(restored.Workflows, restored.Items[i]))
+			}
+			if err := SaveSnapshot(filename, restored, cmd.Ctx); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			toDos, workflows, fields, lists = restored.Items, restored.Workflows, restored.Fields, restored.Lists
+			index.Reset(toDos)
+			// Blobs only the old list referred to are orphans now. The backup itself doesn't hold any file contents.
+			if _, err := self.blobStore().Sweep(toDos, orphanGracePeriod); err != nil {
+				slog.Default().Log(cmd.Ctx, slog.LevelWarn, "Failed to sweep orphaned attachment blobs", "error", err)
+			}
+			// IDs handed out before the restore are never reused, so clients can't mistake a new item for an old one.
+			for _, item := range toDos {
+				maxID = max(maxID, item.ID)
+			}
+			publish(cmd.Ctx, Event{Type: EventRestored})
+			cmd.Result <- len(toDos)
+		case OpAttach, OpDetach:
+			var err error
+			if cmd.Action == OpAttach {
+				toDos, err = AttachToDo(toDos, cmd.ID, cmd.Attachment, cmd.Ctx)
 				if err != nil {
-					cmd.ErrChan <- err
-				} else {
-					cmd.Result <- "success"
+					// The upload is useless if it can't be attached, unless another item already has the same file.
+					deleteOrphans(toDos, []Attachment{cmd.Attachment}, cmd.Ctx)
+				}
+			} else {
+				toDos, err = DetachToDo(toDos, cmd.ID, cmd.AttachmentID, cmd.Ctx)
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			updated := toDos[indexOf(toDos, cmd.ID)]
+			publish(cmd.Ctx, Event{Type: EventUpdated, ItemID: updated.ID, Item: updated})
+			if cmd.Action == OpAttach {
+				cmd.Result <- cmd.Attachment
+			} else {
+				cmd.Result <- "success"
+			}
+		case OpComment, OpEditComment, OpDeleteComment:
+			var (
+				c         Comment
+				eventType EventType
+				err       error
+			)
+			switch cmd.Action {
+			case OpComment:
+				c, eventType = cmd.Comment, EventCommented
+				toDos, err = AddComment(toDos, cmd.ID, c, cmd.Ctx)
+			case OpEditComment:
+				eventType = EventCommentEdited
+				if err = authorizeComment(toDos, lists, cmd.Ctx, cmd.ID, cmd.Comment.ID); err == nil {
+					toDos, c, err = EditComment(toDos, cmd.ID, cmd.Comment.ID, cmd.Comment.Body, cmd.Ctx)
+				}
+			default:
+				eventType = EventCommentDeleted
+				if err = authorizeComment(toDos, lists, cmd.Ctx, cmd.ID, cmd.Comment.ID); err == nil {
+					toDos, c, err = DeleteComment(toDos, cmd.ID, cmd.Comment.ID, cmd.Ctx)
+				}
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			item := toDos[indexOf(toDos, cmd.ID)]
+			publish(cmd.Ctx, Event{Type: eventType, ItemID: item.ID, Item: item, Comment: &c})
+			cmd.Result <- c
+		case OpStartTimer, OpStopTimer, OpAddTimeEntry, OpDeleteTimeEntry:
+			// Every time entry op replies with an ItemTimeEntry.
+			entry := ItemTimeEntry{ItemID: cmd.ID, TimeEntry: cmd.TimeEntry}
+			var err error
+			switch cmd.Action {
+			case OpStartTimer:
+				toDos, err = StartTimer(toDos, cmd.ID, cmd.TimeEntry, cmd.Ctx)
+			case OpStopTimer:
+				// The item is the one the user's timer runs on, so the role on it is checked here.
+				if running, ok := RunningTimer(toDos, cmd.TimeEntry.User); ok {
+					err = authorize(toDos, lists, cmd.Ctx, running.ItemID, RoleEditor)
+				}
+				if err == nil {
+					toDos, entry, err = StopTimer(toDos, cmd.TimeEntry.User, time.Now().UTC(), cmd.Ctx)
 				}
-			case OpShutdown:
-				// Save the data one last time.
-				err := SaveToDos(filename, ToDos, cmd.Ctx)
+			case OpAddTimeEntry:
+				toDos, err = AddTimeEntry(toDos, cmd.ID, cmd.TimeEntry, cmd.Ctx)
+			default:
+				toDos, entry, err = DeleteTimeEntry(toDos, cmd.ID, cmd.TimeEntry.ID, cmd.Ctx)
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			item := toDos[indexOf(toDos, entry.ItemID)]
+			publish(cmd.Ctx, Event{Type: EventUpdated, ItemID: item.ID, Item: item})
+			cmd.Result <- entry
+		case OpTransition:
+			var err error
+			i := indexOf(toDos, cmd.ID)
+			wasCompleted := i >= 0 && toDos[i].Completed
+			toDos, err = TransitionToDo(toDos, cmd.ID, cmd.Status, workflows)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			moved := toDos[i]
+			slog.Default().Log(cmd.Ctx, slog.LevelInfo, "To-do status changed", "id", moved.ID, "status", moved.Status)
+			eventType := EventUpdated
+			if moved.Completed && !wasCompleted {
+				eventType = EventCompleted
+			}
+			publish(cmd.Ctx, Event{Type: eventType, ItemID: moved.ID, Item: moved})
+			cmd.Result <- moved
+		case OpSetWorkflow:
+			// A list's workflow and custom fields apply to everyone's items in it, so only its owner sets them.
+			if err := authorizeList(lists, cmd.Ctx, cmd.List, RoleOwner); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			updated, err := SetWorkflow(toDos, workflows, cmd.List, cmd.Workflow)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			workflows = updated
+			slog.Default().Log(cmd.Ctx, slog.LevelInfo, "Workflow changed", "list", cmd.List, "removed", cmd.Workflow == nil)
+			cmd.Result <- WorkflowFor(workflows, Item{Projects: []string{cmd.List}})
+		case OpSetFields:
+			if err := authorizeList(lists, cmd.Ctx, cmd.List, RoleOwner); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			updated, err := SetFields(toDos, fields, cmd.List, cmd.FieldDefs)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			fields = updated
+			slog.Default().Log(cmd.Ctx, slog.LevelInfo, "Custom fields changed", "list", cmd.List, "count", len(cmd.FieldDefs))
+			defs := fields[cmd.List]
+			if defs == nil {
+				defs = []FieldDef{}
+			}
+			cmd.Result <- slices.Clone(defs)
+		case OpShare:
+			if cmd.ID != 0 {
+				// The owner role was checked with the other commands on one item.
+				var err error
+				toDos, err = ShareToDo(toDos, cmd.ID, cmd.Share)
 				if err != nil {
 					cmd.ErrChan <- err
-				} else {
-					cmd.Result <- "success"
+					continue
 				}
-				return // Return from the function to stop the actor goroutine.
+				shared := toDos[indexOf(toDos, cmd.ID)]
+				index.Add(shared)
+				slog.Default().Log(cmd.Ctx, slog.LevelInfo, "Item sharing changed", "id", shared.ID, "user_id", cmd.Share.UserID, "role", cmd.Share.Role)
+				publish(cmd.Ctx, Event{Type: EventUpdated, ItemID: shared.ID, Item: shared})
+				cmd.Result <- shared
+				continue
 			}
+			if err := authorizeList(lists, cmd.Ctx, cmd.List, RoleOwner); err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			userID, _ := caller(cmd.Ctx)
+			updated, err := ShareList(lists, cmd.List, userID, cmd.Share)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			lists = updated
+			slog.Default().Log(cmd.Ctx, slog.LevelInfo, "List sharing changed", "list", cmd.List, "user_id", cmd.Share.UserID, "role", cmd.Share.Role)
+			cmd.Result <- lists[cmd.List]
+		case OpAssign:
+			// Only users who can see the item may be assigned to it.
+			i := indexOf(toDos, cmd.ID)
+			usernames := make([]string, 0, len(cmd.Assignees))
+			var err error
+			for _, u := range cmd.Assignees {
+				if i >= 0 && !RoleFor(toDos[i], lists, u.ID).Includes(RoleViewer) {
+					err = fmt.Errorf("%w: %s can't see item %d; share it with them first", ErrInvalidAssignee, u.Username, cmd.ID)
+					break
+				}
+				usernames = append(usernames, u.Username)
+			}
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			var added, removed []string
+			toDos, added, removed, err = AssignToDo(toDos, cmd.ID, usernames)
+			if err != nil {
+				cmd.ErrChan <- err
+				continue
+			}
+			assigned := toDos[i]
+			slog.Default().Log(cmd.Ctx, slog.LevelInfo, "Assignees changed", "id", assigned.ID, "assignees", assigned.Assignees)
+			for _, name := range added {
+				publish(cmd.Ctx, Event{Type: EventAssigned, ItemID: assigned.ID, Item: assigned, Assignee: name})
+			}
+			for _, name := range removed {
+				publish(cmd.Ctx, Event{Type: EventUnassigned, ItemID: assigned.ID, Item: assigned, Assignee: name})
+			}
+			cmd.Result <- assigned
+		case OpShutdown:
+			// Save the data one last time.
+			snap := NewSnapshot(toDos)
+			snap.Workflows = workflows
+			snap.Fields = fields
+			snap.Lists = lists
+			err := SaveSnapshot(filename, snap, cmd.Ctx)
+			if err != nil {
+				cmd.ErrChan <- err
+			} else {
+				cmd.Result <- "success"
+			}
+			return // Return from the function to stop the actor goroutine.
 		}
-	}()
+	}
+}
+
+// requiredRole is the role the caller needs on the item with Command.ID for each command on one item.
+// OpStopTimer isn't here: it finds the item from the user's running timer, and checks for RoleEditor on that.
+var requiredRole = map[Op]Role{
+	OpUpdate:          RoleEditor,
+	OpDelete:          RoleOwner,
+	OpAttach:          RoleEditor,
+	OpDetach:          RoleEditor,
+	OpComment:         RoleEditor,
+	OpEditComment:     RoleEditor,
+	OpDeleteComment:   RoleEditor,
+	OpStartTimer:      RoleEditor,
+	OpAddTimeEntry:    RoleEditor,
+	OpDeleteTimeEntry: RoleEditor,
+	OpTransition:      RoleEditor,
+	OpShare:           RoleOwner, // when sharing an item rather than a list
+	OpAssign:          RoleEditor,
+}
+
+// copyOptionalFields copies the fields AddToDo doesn't take as arguments from the item a client sent to the stored item.
+func copyOptionalFields(stored *Item, requested Item) {
+	stored.Priority = requested.Priority
+	stored.Projects = requested.Projects
+	stored.Contexts = requested.Contexts
+	stored.Notes = requested.Notes
+	stored.Estimate = requested.Estimate
+	stored.Fields = requested.Fields
 }
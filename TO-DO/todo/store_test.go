@@ -79,7 +79,7 @@ func TestConcurrentReads(t *testing.T) {
 	// This would delete the file before the readers could access it.
 	// This wouldn't cause a failure because the store would already have loaded initial data into memory,
 	// but it would not be a clean test of reading from disk.
-	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+	t.Cleanup(func() { os.Remove(tmpFile.Name()); os.Remove(tmpFile.Name() + ".v0.bak") })
 
 	// Pre-populate the file with 50 items so we have something to read.
 	initialItems := []Item{}
@@ -134,7 +134,7 @@ func TestConcurrentUpdates(t *testing.T) {
 	if err != nil {
 		t.Fatal("Failed to create temp file:", err)
 	}
-	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+	t.Cleanup(func() { os.Remove(tmpFile.Name()); os.Remove(tmpFile.Name() + ".v0.bak") })
 
 	// Pre-populate
 	initialItems := []Item{}
@@ -184,7 +184,7 @@ func TestStartStore_LoadsExistingData(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+	t.Cleanup(func() { os.Remove(tmpFile.Name()); os.Remove(tmpFile.Name() + ".v0.bak") })
 
 	initialData := []Item{{ID: 99, Name: "Existing Task", Due: "01-01-2025"}}
 	data, _ := json.Marshal(initialData)
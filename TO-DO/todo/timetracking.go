@@ -0,0 +1,293 @@
+package todo
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxTimeNoteLength is the longest TimeEntry.Note accepted, in bytes.
+const MaxTimeNoteLength = 1 << 10 // 1 KB
+
+// TimeEntry is a stretch of time a user spent on an item, either timed with a start/stop timer or entered by hand.
+type TimeEntry struct {
+	ID    string    `json:"id"`
+	User  string    `json:"user"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end,omitzero"` // zero while the timer is running
+	Note  string    `json:"note,omitempty"`
+}
+
+// Running reports whether the entry is a timer that hasn't been stopped yet.
+func (e TimeEntry) Running() bool {
+	return e.End.IsZero()
+}
+
+// ItemTimeEntry is a time entry together with the ID of the item it belongs to.
+type ItemTimeEntry struct {
+	ItemID int `json:"item_id"`
+	TimeEntry
+}
+
+var (
+	// ErrTimerRunning is returned when a user starts a timer while another one of theirs is running.
+	ErrTimerRunning = errors.New("a timer is already running")
+	// ErrNoTimer is returned when a user stops their timer but none is running.
+	ErrNoTimer = errors.New("no timer is running")
+)
+
+// ValidateTimeEntry checks a time entry before it is stored.
+func ValidateTimeEntry(e TimeEntry) error {
+	switch {
+	case strings.TrimSpace(e.User) == "":
+		return errors.New("user cannot be empty")
+	case len(e.User) > MaxAuthorLength:
+		return fmt.Errorf("user must be at most %d bytes, got %d", MaxAuthorLength, len(e.User))
+	case e.Start.IsZero():
+		return errors.New("start is required")
+	case !e.Running() && !e.End.After(e.Start):
+		return errors.New("end must be after start")
+	case len(e.Note) > MaxTimeNoteLength:
+		return fmt.Errorf("note must be at most %d bytes, got %d", MaxTimeNoteLength, len(e.Note))
+	}
+	return nil
+}
+
+// RunningTimer finds the timer the user has running, if any.
+func RunningTimer(toDos []Item, user string) (ItemTimeEntry, bool) {
+	for _, item := range toDos {
+		for _, e := range item.TimeEntries {
+			if e.User == user && e.Running() {
+				return ItemTimeEntry{ItemID: item.ID, TimeEntry: e}, true
+			}
+		}
+	}
+	return ItemTimeEntry{}, false
+}
+
+// StartTimer starts a timer for e.User on the item with the given ID. e.End must be zero.
+// Each user can only have one timer running at a time; starting a second one fails with ErrTimerRunning.
+func StartTimer(toDos []Item, id int, e TimeEntry, ctx context.Context) ([]Item, error) {
+	if !e.Running() {
+		return toDos, errors.New("a new timer cannot have an end")
+	}
+	if running, ok := RunningTimer(toDos, e.User); ok {
+		return toDos, fmt.Errorf("%w for %s on item %d", ErrTimerRunning, e.User, running.ItemID)
+	}
+	toDos, err := addTimeEntry(toDos, id, e)
+	if err == nil {
+		slog.Default().Log(ctx, slog.LevelInfo, "Timer started", "id", id, "entry_id", e.ID, "user", e.User)
+	}
+	return toDos, err
+}
+
+// StopTimer stops the user's running timer at the given time and returns the finished entry.
+func StopTimer(toDos []Item, user string, at time.Time, ctx context.Context) ([]Item, ItemTimeEntry, error) {
+	running, ok := RunningTimer(toDos, user)
+	if !ok {
+		return toDos, ItemTimeEntry{}, fmt.Errorf("%w for %s", ErrNoTimer, user)
+	}
+	// A clock step backwards must not leave an entry that ends before it starts.
+	running.End = at
+	if !running.End.After(running.Start) {
+		running.End = running.Start.Add(time.Second)
+	}
+	i := indexOf(toDos, running.ItemID)
+	entries := slices.Clone(toDos[i].TimeEntries)
+	for j := range entries {
+		if entries[j].ID == running.ID {
+			entries[j] = running.TimeEntry
+		}
+	}
+	toDos[i].TimeEntries = entries
+	slog.Default().Log(ctx, slog.LevelInfo, "Timer stopped", "id", running.ItemID, "entry_id", running.ID, "user", user, "duration", running.End.Sub(running.Start))
+	return toDos, running, nil
+}
+
+// AddTimeEntry records a finished stretch of time, entered by hand, on the item with the given ID.
+func AddTimeEntry(toDos []Item, id int, e TimeEntry, ctx context.Context) ([]Item, error) {
+	if e.Running() {
+		return toDos, errors.New("end is required")
+	}
+	toDos, err := addTimeEntry(toDos, id, e)
+	if err == nil {
+		slog.Default().Log(ctx, slog.LevelInfo, "Time entry added", "id", id, "entry_id", e.ID, "user", e.User, "duration", e.End.Sub(e.Start))
+	}
+	return toDos, err
+}
+
+func addTimeEntry(toDos []Item, id int, e TimeEntry) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	if err := ValidateTimeEntry(e); err != nil {
+		return toDos, err
+	}
+	// Build a new slice rather than appending in place, since snapshots handed out by OpGet may share the old one.
+	toDos[i].TimeEntries = append(slices.Clip(toDos[i].TimeEntries), e)
+	return toDos, nil
+}
+
+// DeleteTimeEntry removes a time entry, running or not, from the item with the given ID and returns it.
+func DeleteTimeEntry(toDos []Item, id int, entryID string, ctx context.Context) ([]Item, ItemTimeEntry, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, ItemTimeEntry{}, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	for j, e := range toDos[i].TimeEntries {
+		if e.ID == entryID {
+			toDos[i].TimeEntries = append(toDos[i].TimeEntries[:j:j], toDos[i].TimeEntries[j+1:]...)
+			slog.Default().Log(ctx, slog.LevelInfo, "Time entry deleted", "id", id, "entry_id", entryID)
+			return toDos, ItemTimeEntry{ItemID: id, TimeEntry: e}, nil
+		}
+	}
+	return toDos, ItemTimeEntry{}, fmt.Errorf("time entry %s %w on item %d", entryID, ErrNotFound, id)
+}
+
+// Ways of grouping a time report.
+const (
+	TimeGroupItem    = "item"    // one total per item
+	TimeGroupProject = "project" // one total per +project; items without a project are totalled under ""
+	TimeGroupContext = "context" // one total per @context; items without a context are totalled under ""
+)
+
+// TimeTotal is the time tracked on one group of a TimeReport.
+type TimeTotal struct {
+	Key     string `json:"key"`               // the item ID, project or context
+	Name    string `json:"name,omitempty"`    // the item name, when grouped by item
+	Seconds int64  `json:"seconds"`           // time tracked inside the report's range
+	Entries int    `json:"entries"`           // number of entries that overlap the range
+	Running bool   `json:"running,omitempty"` // whether a timer is still running, so the total will grow
+}
+
+// TimeReport totals the time tracked between From (inclusive) and To (exclusive).
+type TimeReport struct {
+	From         time.Time   `json:"from"`
+	To           time.Time   `json:"to"`
+	GroupBy      string      `json:"group_by"`
+	User         string      `json:"user,omitempty"` // only this user's entries are counted; empty means everyone's
+	TotalSeconds int64       `json:"total_seconds"`
+	Totals       []TimeTotal `json:"totals"`
+}
+
+// BuildTimeReport totals the time tracked on the items between from and to, grouped by item, project or context.
+// Only the part of an entry inside the range is counted, and a running timer counts up to now.
+// An item in several projects (or contexts) counts towards each of them, so project and context totals can add up to
+// more than TotalSeconds.
+func BuildTimeReport(items []Item, from, to time.Time, groupBy, user string, now time.Time) (TimeReport, error) {
+	if groupBy == "" {
+		groupBy = TimeGroupItem
+	}
+	if groupBy != TimeGroupItem && groupBy != TimeGroupProject && groupBy != TimeGroupContext {
+		return TimeReport{}, fmt.Errorf("unknown grouping %q (use item, project or context)", groupBy)
+	}
+	if !to.After(from) {
+		return TimeReport{}, errors.New("the end of the range must be after its start")
+	}
+
+	report := TimeReport{From: from, To: to, GroupBy: groupBy, User: user, Totals: []TimeTotal{}}
+	totals := make(map[string]*TimeTotal)
+	for _, item := range items {
+		var seconds int64
+		entries := 0
+		running := false
+		for _, e := range item.TimeEntries {
+			if user != "" && e.User != user {
+				continue
+			}
+			start, end := e.Start, e.End
+			if e.Running() {
+				end = now
+			}
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			overlap := end.Sub(start)
+			if overlap <= 0 {
+				continue
+			}
+			seconds += int64(overlap / time.Second)
+			entries++
+			running = running || e.Running()
+		}
+		if entries == 0 {
+			continue
+		}
+		report.TotalSeconds += seconds
+
+		var keys []string
+		switch groupBy {
+		case TimeGroupItem:
+			keys = []string{strconv.Itoa(item.ID)}
+		case TimeGroupProject:
+			keys = item.Projects
+		case TimeGroupContext:
+			keys = item.Contexts
+		}
+		if len(keys) == 0 {
+			keys = []string{""}
+		}
+		for _, key := range keys {
+			t, ok := totals[key]
+			if !ok {
+				t = &TimeTotal{Key: key}
+				if groupBy == TimeGroupItem {
+					t.Name = item.Name
+				}
+				totals[key] = t
+			}
+			t.Seconds += seconds
+			t.Entries += entries
+			t.Running = t.Running || running
+		}
+	}
+
+	for _, t := range totals {
+		report.Totals = append(report.Totals, *t)
+	}
+	sort.Slice(report.Totals, func(i, j int) bool {
+		a, b := report.Totals[i], report.Totals[j]
+		if a.Seconds != b.Seconds {
+			return a.Seconds > b.Seconds
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+// TimeReportCSVHeader is the header row written by WriteTimeReportCSV.
+var TimeReportCSVHeader = []string{"Group", "Key", "Name", "Seconds", "Hours", "Entries", "Running"}
+
+// WriteTimeReportCSV writes the report's totals, one row per group, followed by a row with the overall total.
+func WriteTimeReportCSV(w io.Writer, report TimeReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(TimeReportCSVHeader); err != nil {
+		return err
+	}
+	hours := func(seconds int64) string {
+		return strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64)
+	}
+	for _, t := range report.Totals {
+		record := []string{report.GroupBy, escapeFormula(t.Key), escapeFormula(t.Name), strconv.FormatInt(t.Seconds, 10), hours(t.Seconds), strconv.Itoa(t.Entries), strconv.FormatBool(t.Running)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write([]string{"total", "", "", strconv.FormatInt(report.TotalSeconds, 10), hours(report.TotalSeconds), "", ""}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,148 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimers_OneRunningPerUser(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	toDos := []Item{{ID: 1, Name: "One", Due: "01-01-2026"}, {ID: 2, Name: "Two", Due: "01-01-2026"}}
+
+	toDos, err := StartTimer(toDos, 1, TimeEntry{ID: "a", User: "ana", Start: start}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartTimer(toDos, 2, TimeEntry{ID: "b", User: "ana", Start: start}, ctx); !errors.Is(err, ErrTimerRunning) {
+		t.Errorf("Expected ErrTimerRunning for a second timer, got %v", err)
+	}
+	// Other users have their own timer.
+	if toDos, err = StartTimer(toDos, 2, TimeEntry{ID: "c", User: "ben", Start: start}, ctx); err != nil {
+		t.Errorf("Expected another user's timer to start, got %v", err)
+	}
+
+	toDos, stopped, err := StopTimer(toDos, "ana", start.Add(90*time.Minute), ctx)
+	if err != nil || stopped.ItemID != 1 || stopped.ID != "a" || stopped.End.Sub(stopped.Start) != 90*time.Minute {
+		t.Fatalf("Unexpected stopped timer %+v, %v", stopped, err)
+	}
+	if _, ok := RunningTimer(toDos, "ana"); ok {
+		t.Error("Expected no timer running for ana")
+	}
+	if _, _, err := StopTimer(toDos, "ana", start, ctx); !errors.Is(err, ErrNoTimer) {
+		t.Errorf("Expected ErrNoTimer, got %v", err)
+	}
+}
+
+func TestValidateTimeEntry(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, e := range []TimeEntry{
+		{User: "", Start: start},
+		{User: "ana"},
+		{User: "ana", Start: start, End: start},
+		{User: "ana", Start: start, End: start.Add(-time.Hour)},
+		{User: "ana", Start: start, Note: strings.Repeat("x", MaxTimeNoteLength+1)},
+	} {
+		if err := ValidateTimeEntry(e); err == nil {
+			t.Errorf("Expected %+v to be rejected", e)
+		}
+	}
+}
+
+func TestBuildTimeReport(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.UTC) }
+	items := []Item{
+		{ID: 1, Name: "Design", Projects: []string{"web"}, TimeEntries: []TimeEntry{
+			{ID: "a", User: "ana", Start: day(2, 9), End: day(2, 11)},
+			{ID: "b", User: "ben", Start: day(2, 9), End: day(2, 10)},
+			// Straddles the start of the range: only the hour inside counts.
+			{ID: "c", User: "ana", Start: day(0, 23), End: day(1, 1)},
+		}},
+		{ID: 2, Name: "Deploy", Projects: []string{"web", "ops"}, TimeEntries: []TimeEntry{
+			{ID: "d", User: "ana", Start: day(3, 9), End: day(3, 12)},
+			// Outside the range.
+			{ID: "e", User: "ana", Start: day(20, 9), End: day(20, 10)},
+		}},
+		{ID: 3, Name: "Call", TimeEntries: []TimeEntry{
+			// Still running: counts up to now.
+			{ID: "f", User: "ana", Start: day(4, 9)},
+		}},
+	}
+	from, to, now := day(1, 0), day(8, 0), day(4, 10)
+
+	report, err := BuildTimeReport(items, from, to, TimeGroupItem, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalSeconds != (4+3+1)*3600 {
+		t.Errorf("Expected 8 hours in total, got %d seconds", report.TotalSeconds)
+	}
+	if len(report.Totals) != 3 || report.Totals[0].Key != "1" || report.Totals[0].Seconds != 4*3600 || report.Totals[0].Entries != 3 || !report.Totals[2].Running {
+		t.Errorf("Unexpected item totals %+v", report.Totals)
+	}
+
+	report, _ = BuildTimeReport(items, from, to, TimeGroupProject, "ana", now)
+	want := map[string]int64{"web": (3 + 3) * 3600, "ops": 3 * 3600, "": 3600}
+	if len(report.Totals) != len(want) {
+		t.Fatalf("Unexpected project totals %+v", report.Totals)
+	}
+	for _, total := range report.Totals {
+		if total.Seconds != want[total.Key] {
+			t.Errorf("Project %q: expected %d seconds, got %d", total.Key, want[total.Key], total.Seconds)
+		}
+	}
+
+	if _, err := BuildTimeReport(items, from, to, "colour", "", now); err == nil {
+		t.Error("Expected an unknown grouping to be rejected")
+	}
+
+	var b strings.Builder
+	if err := WriteTimeReportCSV(&b, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "Group,Key,Name,Seconds,Hours,Entries,Running\nproject,web,,21600,6.00,3,false\n") {
+		t.Errorf("Unexpected CSV:\n%s", b.String())
+	}
+}
+
+func TestStartStore_Timers(t *testing.T) {
+	Store = make(chan Command)
+	StartStore(filepath.Join(t.TempDir(), "todos.json"))
+	t.Cleanup(func() { close(Store) })
+
+	send := func(cmd Command) (any, error) {
+		cmd.Ctx = context.Background()
+		cmd.Result = make(chan any)
+		cmd.ErrChan = make(chan error)
+		Store <- cmd
+		select {
+		case result := <-cmd.Result:
+			return result, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+
+	send(Command{Action: OpAdd, Item: Item{Name: "Task", Due: "01-01-2026"}})
+	if _, err := send(Command{Action: OpStartTimer, ID: 1, TimeEntry: TimeEntry{ID: "a", User: "ana", Start: time.Now().UTC().Add(-time.Minute)}}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := send(Command{Action: OpStopTimer, TimeEntry: TimeEntry{User: "ana"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped := result.(ItemTimeEntry); stopped.ItemID != 1 || stopped.Running() {
+		t.Errorf("Unexpected stopped timer %+v", stopped)
+	}
+	if _, err := send(Command{Action: OpDeleteTimeEntry, ID: 1, TimeEntry: TimeEntry{ID: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	result, _ = send(Command{Action: OpGet})
+	if items := result.([]Item); len(items[0].TimeEntries) != 0 {
+		t.Errorf("Expected the entry to be deleted, got %+v", items[0].TimeEntries)
+	}
+}
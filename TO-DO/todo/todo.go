@@ -3,26 +3,141 @@ package todo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 var Filename string = "todos.json"
 
+// DueLayout is the layout of Item.Due (DD-MM-YYYY) in time.Parse terms.
+const DueLayout = "02-01-2006"
+
+// withDefaultDue gives an imported item that has no due date one: the day it is imported. Formats such as
+// todo.txt and iCalendar don't require due dates, but every item needs one.
+func withDefaultDue(item Item) Item {
+	if item.Due == "" {
+		item.Due = time.Now().Format(DueLayout)
+	}
+	return item
+}
+
+// MaxNotesSize is the largest Item.Notes accepted, in bytes.
+const MaxNotesSize = 64 << 10 // 64 KB
+
+// MaxEstimate is the largest Item.Estimate accepted.
+const MaxEstimate = 10000
+
 type Item struct { // To-Do item structure: names must be capitalized to be exported
 	ID        int
 	Name      string
-	Completed bool
+	Completed bool // derived from Status: true while the item is in one of its workflow's done states
 	Due       string
+	Priority  int `json:",omitempty"` // 0 = none, otherwise 1 (highest) to 9 (lowest), as in iCalendar
+
+	// Status is the item's state in the workflow of its list (see workflow.go).
+	Status string `json:",omitempty"`
+
+	// Estimate is the expected effort, in points or hours as the team prefers; 0 means not estimated.
+	Estimate float64 `json:",omitempty"`
+
+	// Projects and Contexts group items the way todo.txt's +project and @context tags do (stored without the sigil).
+	Projects []string `json:",omitempty"`
+	Contexts []string `json:",omitempty"`
+
+	// Fields holds the values of the custom fields of the item's list (see fields.go), by field name.
+	Fields map[string]string `json:",omitempty"`
+
+	// Notes is free-form Markdown describing the item, rendered as sanitised HTML on the item page.
+	Notes string `json:",omitempty"`
+
+	// Attachments are files attached to the item; their contents are kept in Blobs.
+	Attachments []Attachment `json:",omitempty"`
+
+	// Comments are the item's discussion thread, oldest first.
+	Comments []Comment `json:",omitempty"`
+
+	// TimeEntries is the time spent on the item, in the order it was recorded.
+	TimeEntries []TimeEntry `json:",omitempty"`
+
+	// Owner is the ID of the user who created the item, and Shares the roles other users have on it, by user ID
+	// (see sharing.go). Items created before there were accounts have no owner.
+	Owner  string          `json:",omitempty"`
+	Shares map[string]Role `json:",omitempty"`
+
+	// Assignees are the usernames of the users working on the item (see assignees.go).
+	Assignees []string `json:",omitempty"`
+
+	// Timestamps are maintained by AddToDo and UpdateToDo. omitzero keeps them out of files written before they existed.
+	CreatedAt   time.Time `json:",omitzero"`
+	UpdatedAt   time.Time `json:",omitzero"`
+	CompletedAt time.Time `json:",omitzero"`
+}
+
+// ItemUpdate holds pointers for partial updates.
+// This allows us to distinguish between a zero value (e.g., "") and a field that wasn't provided.
+type ItemUpdate struct {
+	Name      *string
+	Due       *string
+	Completed *bool
+	Priority  *int
+	Notes     *string
+	Estimate  *float64
+	// Fields sets the given custom field values and clears those given as "". Nil leaves them all unchanged.
+	// The store checks the values against the item's list before the update is applied.
+	Fields map[string]string
 }
 
-var ToDos []Item
 var ToDosMutex sync.RWMutex //
 
+// ValidateItem checks the fields a client must supply when creating a new item.
+func ValidateItem(item Item) error {
+	if item.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if _, err := time.Parse(DueLayout, item.Due); err != nil {
+		return errors.New("due date must be in DD-MM-YYYY format")
+	}
+	if err := ValidatePriority(item.Priority); err != nil {
+		return err
+	}
+	if err := ValidateEstimate(item.Estimate); err != nil {
+		return err
+	}
+	return validateNotes(item.Notes)
+}
+
+// ValidateEstimate checks an Item.Estimate.
+func ValidateEstimate(e float64) error {
+	if math.IsNaN(e) || e < 0 || e > MaxEstimate {
+		return fmt.Errorf("estimate must be between 0 (none) and %d, got %v", MaxEstimate, e)
+	}
+	return nil
+}
+
+// ValidatePriority checks an Item.Priority.
+func ValidatePriority(p int) error {
+	if p < 0 || p > 9 {
+		return errors.New("priority must be between 0 (none) and 9")
+	}
+	return nil
+}
+
+func validateNotes(notes string) error {
+	if len(notes) > MaxNotesSize {
+		return fmt.Errorf("notes must be at most %d bytes, got %d", MaxNotesSize, len(notes))
+	}
+	return nil
+}
+
 func AddToDo(toDos []Item, id int, name string, due string, ctx context.Context) ([]Item, error) {
-	task := Item{ID: id, Name: name, Due: due} //Completed defaults to false
+	now := time.Now().UTC()
+	task := Item{ID: id, Name: name, Due: due, CreatedAt: now, UpdatedAt: now} //Completed defaults to false
 	toDos = append(toDos, task)
 	slog.Default().Log(
 		ctx,
@@ -42,42 +157,86 @@ func RemoveToDo(toDos []Item, id int, ctx context.Context) ([]Item, error) {
 				slog.LevelInfo,
 				"To-do data successfully removed",
 				"id", id)
+			// Delete the item's attachment blobs unless another item has the same file attached.
+			deleteOrphans(toDos, item.Attachments, ctx)
 			return toDos, nil // Return successfully after removing the item.
 		}
 	}
 	// If the loop completes without finding the ID, return an error.
-	return toDos, fmt.Errorf("item with id %d not found", id)
+	return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
 }
 
-func UpdateToDo(toDos []Item, id int, name *string, due *string, completed *bool, ctx context.Context) ([]Item, error) {
-	slog.Default().Log(ctx, slog.LevelInfo, "updating with the following values", "id", id, "name", name, "due", due, "completed", completed)
+func UpdateToDo(toDos []Item, id int, u ItemUpdate, ctx context.Context) ([]Item, error) {
+	slog.Default().Log(ctx, slog.LevelInfo, "updating with the following values", "id", id, "name", u.Name, "due", u.Due, "completed", u.Completed, "priority", u.Priority)
+	if u.Priority != nil {
+		if err := ValidatePriority(*u.Priority); err != nil {
+			return toDos, err
+		}
+	}
+	if u.Notes != nil {
+		if err := validateNotes(*u.Notes); err != nil {
+			return toDos, err
+		}
+	}
+	if u.Estimate != nil {
+		if err := ValidateEstimate(*u.Estimate); err != nil {
+			return toDos, err
+		}
+	}
 	for i, item := range toDos {
 		if item.ID == id {
-			if name != nil {
-				toDos[i].Name = *name
+			now := time.Now().UTC()
+			if u.Name != nil {
+				toDos[i].Name = *u.Name
+			}
+			if u.Due != nil {
+				toDos[i].Due = *u.Due
+			}
+			if u.Completed != nil {
+				// Record when the item was completed; reopening it clears the timestamp.
+				if *u.Completed && !item.Completed {
+					toDos[i].CompletedAt = now
+				} else if !*u.Completed {
+					toDos[i].CompletedAt = time.Time{}
+				}
+				toDos[i].Completed = *u.Completed
 			}
-			if due != nil {
-				toDos[i].Due = *due
+			if u.Priority != nil {
+				toDos[i].Priority = *u.Priority
 			}
-			if completed != nil {
-				toDos[i].Completed = *completed
+			if u.Notes != nil {
+				toDos[i].Notes = *u.Notes
 			}
+			if u.Estimate != nil {
+				toDos[i].Estimate = *u.Estimate
+			}
+			if u.Fields != nil {
+				toDos[i].Fields = mergeFields(item.Fields, u.Fields)
+			}
+			toDos[i].UpdatedAt = now
 			slog.Default().Log(ctx, slog.LevelInfo, "To-do data successfully updated", "id", id)
 			return toDos, nil // Return successfully after updating.
 		}
 	}
 	// If the loop completes without finding the ID, return an error.
-	return toDos, fmt.Errorf("item with id %d not found", id)
+	return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
 }
 
 func SaveToDos(filename string, todos []Item, ctx context.Context) error { //error is a built-in type
-	// Convert the todos slice to JSON
-	data, err := json.Marshal(todos)
+	return SaveSnapshot(filename, NewSnapshot(todos), ctx)
+}
+
+// SaveSnapshot writes the list, along with the workflows and custom fields in the snapshot, to filename.
+func SaveSnapshot(filename string, snap Snapshot, ctx context.Context) error {
+	todos := snap.Items
+	// Convert the snapshot to JSON; its envelope records the schema version (see schema.go)
+	data, err := json.Marshal(snap)
 	if err != nil {
 		return err
 	}
-	// Write the JSON data to a file
-	err = os.WriteFile(filename, data, 0644)
+	// Write the JSON data to a temporary file and rename it over the old one, so that
+	// the data file is never left half-written if the process dies while saving.
+	err = writeFileAtomic(filename, data, 0644)
 	if err != nil {
 		return err
 	}
@@ -91,6 +250,12 @@ func SaveToDos(filename string, todos []Item, ctx context.Context) error { //err
 }
 
 func LoadToDos(filename string, ctx context.Context) ([]Item, error) {
+	snap, err := LoadSnapshot(filename, ctx)
+	return snap.Items, err
+}
+
+// LoadSnapshot reads the list and the workflows from filename, migrating files written by older versions.
+func LoadSnapshot(filename string, ctx context.Context) (Snapshot, error) {
 	// Read the JSON data from the file
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -100,7 +265,7 @@ func LoadToDos(filename string, ctx context.Context) ([]Item, error) {
 				slog.LevelInfo,
 				"Data file not found, initializing empty list.",
 				"file", filename)
-			return []Item{}, nil // Return an empty slice if the file doesn't exist
+			return NewSnapshot(nil), nil // Return an empty list if the file doesn't exist
 		}
 		slog.Default().Log(
 			ctx,
@@ -108,7 +273,7 @@ func LoadToDos(filename string, ctx context.Context) ([]Item, error) {
 			"Failed to read data file",
 			"file", filename,
 			"error", err)
-		return nil, fmt.Errorf("could not read file %s: %w", filename, err)
+		return Snapshot{}, fmt.Errorf("could not read file %s: %w", filename, err)
 	}
 
 	if len(data) == 0 {
@@ -118,12 +283,11 @@ func LoadToDos(filename string, ctx context.Context) ([]Item, error) {
 			"Empty file, initializing empty list.",
 			"file", filename)
 		// If the file is empty, return an empty list.
-		return []Item{}, nil
+		return NewSnapshot(nil), nil
 	}
 
-	// Convert the JSON data to a slice of Item
-	var todos []Item
-	err = json.Unmarshal(data, &todos)
+	// Upgrade files written by older versions, then convert the JSON data to a slice of Item
+	snap, from, err := decodeSnapshot(data)
 	if err != nil {
 		slog.Default().Log(
 			ctx,
@@ -131,7 +295,46 @@ func LoadToDos(filename string, ctx context.Context) ([]Item, error) {
 			"Failed to decode data file contents",
 			"file", filename,
 			"error", err)
-		return nil, fmt.Errorf("could not unmarshal data: %w", err)
+		return Snapshot{}, fmt.Errorf("could not unmarshal data: %w", err)
+	}
+	if from < SchemaVersion {
+		// Keep the file as it was, since the next save will overwrite it in the new format.
+		backup := fmt.Sprintf("%s.v%d.bak", filename, from)
+		if err := writeFileAtomic(backup, data, 0644); err != nil {
+			return Snapshot{}, fmt.Errorf("could not back up %s before migrating it: %w", filename, err)
+		}
+		slog.Default().Log(
+			ctx,
+			slog.LevelInfo,
+			"Data file migrated to the current schema version.",
+			"file", filename,
+			"from_version", from,
+			"to_version", SchemaVersion,
+			"backup", backup)
+	}
+	return snap, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it to filename.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename has succeeded
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
 	}
-	return todos, nil
+	return os.Rename(tmp.Name(), filename)
 }
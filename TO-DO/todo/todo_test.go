@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +71,7 @@ func TestLoadToDos_HappyPath(t *testing.T) {
 	}
 	tmpFileName := tmpFile.Name()
 	defer os.Remove(tmpFileName) // Ensure the file is deleted when the test exits
+	defer os.Remove(tmpFileName + ".v0.bak")
 	validJSON := `[{"Name":"Test ToDo","DueDate":"2024-12-31T23:59:59Z","Completed":false}]`
 	_, writeErr := tmpFile.WriteString(validJSON)
 	if writeErr != nil {
@@ -149,22 +151,23 @@ func TestSaveToDos(t *testing.T) {
 	if readErr != nil {
 		t.Fatalf("Failed to read saved file: %v", readErr)
 	}
-	// Create the expected data structure, matching what was passed to SaveToDos.
-	var expectedContent []Item
-	task1 := Item{ID: 1, Name: "ToDo 1", Due: "2024-12-01T10:00:00Z", Completed: false}
-	task2 := Item{ID: 2, Name: "ToDo 2", Due: "2024-12-02T11:00:00Z", Completed: false}
-	expectedContent = append(expectedContent, task1)
-	expectedContent = append(expectedContent, task2)
+	// The items are wrapped in an envelope recording the schema version.
+	var saved Snapshot
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Saved file is not a snapshot: %v\n%s", err, data)
+	}
+	if saved.SchemaVersion != SchemaVersion || saved.ItemCount != 2 {
+		t.Errorf("Expected schema version %d with 2 items, got %d with %d", SchemaVersion, saved.SchemaVersion, saved.ItemCount)
+	}
 
-	// Marshal the expected data to JSON for comparison.
-	expectedJSON, err := json.Marshal(expectedContent)
+	// Compare the saved items with the data that was passed to SaveToDos.
+	expectedJSON, err := json.Marshal(todosToSave)
 	if err != nil {
 		t.Fatalf("Failed to marshal expected data: %v", err)
 	}
-
-	// Compare the actual file content with the expected JSON.
-	if string(data) != string(expectedJSON) {
-		t.Errorf("Saved file content does not match expected.\nGot: %s\nWant: %s", string(data), string(expectedJSON))
+	gotJSON, _ := json.Marshal(saved.Items)
+	if string(gotJSON) != string(expectedJSON) {
+		t.Errorf("Saved items do not match expected.\nGot: %s\nWant: %s", gotJSON, expectedJSON)
 	}
 }
 
@@ -216,3 +219,54 @@ func TestRemoveToDo(t *testing.T) {
 		t.Errorf("Remaining todos do not match expected values. Got: %+v", updatedTodos)
 	}
 }
+
+func TestUpdateToDo_TimestampsAndPriority(t *testing.T) {
+	ctx := context.Background()
+	todos, _ := AddToDo([]Item{}, 1, "Task", "01-01-2026", ctx)
+	if todos[0].CreatedAt.IsZero() || !todos[0].UpdatedAt.Equal(todos[0].CreatedAt) {
+		t.Fatalf("Expected AddToDo to set CreatedAt and UpdatedAt, got %+v", todos[0])
+	}
+
+	completed, priority := true, 3
+	todos, err := UpdateToDo(todos, 1, ItemUpdate{Completed: &completed, Priority: &priority}, ctx)
+	if err != nil {
+		t.Fatalf("UpdateToDo failed unexpectedly: %v", err)
+	}
+	if todos[0].Priority != 3 || todos[0].CompletedAt.IsZero() || todos[0].UpdatedAt.Before(todos[0].CreatedAt) {
+		t.Errorf("Expected priority and completion time to be set, got %+v", todos[0])
+	}
+
+	// Reopening an item clears its completion time.
+	completed = false
+	todos, _ = UpdateToDo(todos, 1, ItemUpdate{Completed: &completed}, ctx)
+	if !todos[0].CompletedAt.IsZero() {
+		t.Errorf("Expected CompletedAt to be cleared, got %v", todos[0].CompletedAt)
+	}
+
+	priority = 10
+	if _, err := UpdateToDo(todos, 1, ItemUpdate{Priority: &priority}, ctx); err == nil {
+		t.Error("Expected an error for priority 10")
+	}
+}
+
+func TestNotes_SizeLimit(t *testing.T) {
+	ctx := context.Background()
+	todos, _ := AddToDo([]Item{}, 1, "Task", "01-01-2026", ctx)
+
+	notes := "## Context\n\nCall *before* noon."
+	todos, err := UpdateToDo(todos, 1, ItemUpdate{Notes: &notes}, ctx)
+	if err != nil || todos[0].Notes != notes {
+		t.Fatalf("Expected notes to be set, got %q (%v)", todos[0].Notes, err)
+	}
+
+	tooLong := strings.Repeat("x", MaxNotesSize+1)
+	if _, err := UpdateToDo(todos, 1, ItemUpdate{Notes: &tooLong}, ctx); err == nil {
+		t.Error("Expected an error for notes over MaxNotesSize")
+	}
+	if err := ValidateItem(Item{Name: "Task", Due: "01-01-2026", Notes: tooLong}); err == nil {
+		t.Error("Expected ValidateItem to reject notes over MaxNotesSize")
+	}
+	if err := ValidateItem(Item{Name: "Task", Due: "01-01-2026", Notes: tooLong[1:]}); err != nil {
+		t.Errorf("Expected notes of exactly MaxNotesSize to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package todo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// todo.txt support (https://github.com/todotxt/todo.txt). One line per item:
+//
+//	x 2026-01-02 2026-01-01 Call the garage +Car @phone due:2026-01-05
+//	(A) 2026-01-01 Book flights +Holiday
+//
+// The whole description, including +project and @context tags and any unknown key:value pairs,
+// is kept in Item.Name so that lines survive a round trip unchanged. Projects and Contexts are
+// extracted from it for filtering. due: and pri: are mapped onto Due and Priority and removed from the text.
+
+const todoTxtDateLayout = "2006-01-02"
+
+// FormatTodoTxt returns the todo.txt line for an item.
+func FormatTodoTxt(item Item) string {
+	var parts []string
+	if item.Completed {
+		parts = append(parts, "x")
+		// A creation date is only allowed on a completed task if the completion date precedes it.
+		if !item.CompletedAt.IsZero() {
+			parts = append(parts, item.CompletedAt.UTC().Format(todoTxtDateLayout))
+			if !item.CreatedAt.IsZero() {
+				parts = append(parts, item.CreatedAt.UTC().Format(todoTxtDateLayout))
+			}
+		}
+	} else {
+		if item.Priority > 0 {
+			parts = append(parts, "("+priorityLetter(item.Priority)+")")
+		}
+		if !item.CreatedAt.IsZero() {
+			parts = append(parts, item.CreatedAt.UTC().Format(todoTxtDateLayout))
+		}
+	}
+
+	// Newlines would start a new task, so they are flattened to spaces.
+	name := strings.Join(strings.Fields(item.Name), " ")
+	parts = append(parts, name)
+
+	words := strings.Fields(name)
+	for _, p := range item.Projects {
+		if !slices.Contains(words, "+"+p) {
+			parts = append(parts, "+"+p)
+		}
+	}
+	for _, c := range item.Contexts {
+		if !slices.Contains(words, "@"+c) {
+			parts = append(parts, "@"+c)
+		}
+	}
+	if due, err := time.Parse(DueLayout, item.Due); err == nil {
+		parts = append(parts, "due:"+due.Format(todoTxtDateLayout))
+	}
+	// Completed tasks lose their (A) prefix, so the priority is kept as a tag instead, as most tools do.
+	if item.Completed && item.Priority > 0 {
+		parts = append(parts, "pri:"+priorityLetter(item.Priority))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseTodoTxt parses one todo.txt line into an item. The item is not validated.
+func ParseTodoTxt(line string) (Item, error) {
+	var item Item
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		return item, errors.New("empty line")
+	}
+
+	if tokens[0] == "x" {
+		item.Completed = true
+		tokens = tokens[1:]
+		if len(tokens) > 0 {
+			if d, err := time.Parse(todoTxtDateLayout, tokens[0]); err == nil {
+				item.CompletedAt = d
+				tokens = tokens[1:]
+			}
+		}
+	} else if len(tokens[0]) == 3 && tokens[0][0] == '(' && tokens[0][2] == ')' && tokens[0][1] >= 'A' && tokens[0][1] <= 'Z' {
+		item.Priority = priorityFromLetter(tokens[0][1])
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 {
+		if d, err := time.Parse(todoTxtDateLayout, tokens[0]); err == nil {
+			item.CreatedAt = d
+			tokens = tokens[1:]
+		}
+	}
+
+	var description []string
+	for _, tok := range tokens {
+		key, value, isPair := strings.Cut(tok, ":")
+		switch {
+		case isPair && key == "due":
+			d, err := time.Parse(todoTxtDateLayout, value)
+			if err != nil {
+				return item, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", value)
+			}
+			item.Due = d.Format(DueLayout)
+			continue
+		case isPair && key == "pri" && len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z':
+			item.Priority = priorityFromLetter(value[0])
+			continue
+		case len(tok) > 1 && tok[0] == '+':
+			if !slices.Contains(item.Projects, tok[1:]) {
+				item.Projects = append(item.Projects, tok[1:])
+			}
+		case len(tok) > 1 && tok[0] == '@':
+			if !slices.Contains(item.Contexts, tok[1:]) {
+				item.Contexts = append(item.Contexts, tok[1:])
+			}
+		}
+		description = append(description, tok)
+	}
+	item.Name = strings.Join(description, " ")
+	return item, nil
+}
+
+// WriteTodoTxt writes one todo.txt line per item.
+func WriteTodoTxt(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		bw.WriteString(FormatTodoTxt(item))
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// ReadTodoTxt parses a todo.txt file. Blank lines are skipped; invalid lines are reported by line number.
+// Lines without a due: date are due the day they are imported.
+func ReadTodoTxt(r io.Reader) ([]Item, []RowError, error) {
+	scanner := bufio.NewScanner(r)
+	var items []Item
+	var rowErrs []RowError
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		item, err := ParseTodoTxt(text)
+		if err == nil {
+			item = withDefaultDue(item)
+			err = ValidateItem(item)
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Row: line, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read todo.txt: %w", err)
+	}
+	return items, rowErrs, nil
+}
+
+// priorityLetter maps priorities 1-9 onto A-I.
+func priorityLetter(p int) string {
+	return string(rune('A' + p - 1))
+}
+
+// priorityFromLetter maps A-I onto priorities 1-9. Lower todo.txt priorities (J-Z) all become 9, the lowest Item priority.
+func priorityFromLetter(c byte) int {
+	return min(int(c-'A')+1, 9)
+}
@@ -0,0 +1,105 @@
+package todo
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTodoTxt(t *testing.T) {
+	item, err := ParseTodoTxt("(B) 2026-01-01 Call the garage +Car @phone due:2026-01-05 t:2026-01-03")
+	if err != nil {
+		t.Fatalf("ParseTodoTxt failed: %v", err)
+	}
+	if item.Priority != 2 || item.Due != "05-01-2026" || item.Completed {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if !item.CreatedAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected creation date 2026-01-01, got %v", item.CreatedAt)
+	}
+	// Tags and unknown key:values stay in the text; due: is removed.
+	if item.Name != "Call the garage +Car @phone t:2026-01-03" {
+		t.Errorf("Unexpected name %q", item.Name)
+	}
+	if !slices.Equal(item.Projects, []string{"Car"}) || !slices.Equal(item.Contexts, []string{"phone"}) {
+		t.Errorf("Unexpected projects/contexts: %v %v", item.Projects, item.Contexts)
+	}
+}
+
+func TestParseTodoTxt_Completed(t *testing.T) {
+	item, err := ParseTodoTxt("x 2026-01-04 2026-01-01 Pay rent due:2026-01-05 pri:A")
+	if err != nil {
+		t.Fatalf("ParseTodoTxt failed: %v", err)
+	}
+	if !item.Completed || item.Priority != 1 || item.Name != "Pay rent" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if !item.CompletedAt.Equal(time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)) || !item.CreatedAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected dates: completed %v created %v", item.CompletedAt, item.CreatedAt)
+	}
+
+	// "x" only marks completion at the very start of the line, and (A) only counts as a priority there.
+	item, _ = ParseTodoTxt("Fix box x (A) due:2026-01-05")
+	if item.Completed || item.Priority != 0 || item.Name != "Fix box x (A)" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+
+	if _, err := ParseTodoTxt("Bad date due:05-01-2026"); err == nil {
+		t.Error("Expected an error for a due date not in YYYY-MM-DD format")
+	}
+}
+
+func TestFormatTodoTxt(t *testing.T) {
+	created := time.Date(2026, 1, 1, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		item Item
+		want string
+	}{
+		{Item{Name: "Book flights +Holiday", Due: "05-01-2026", Priority: 1, CreatedAt: created},
+			"(A) 2026-01-01 Book flights +Holiday due:2026-01-05"},
+		{Item{Name: "Call\nmum", Due: "05-01-2026", Projects: []string{"Family"}, Contexts: []string{"phone"}},
+			"Call mum +Family @phone due:2026-01-05"},
+		{Item{Name: "Pay rent", Due: "05-01-2026", Priority: 3, Completed: true, CompletedAt: created.Add(72 * time.Hour), CreatedAt: created},
+			"x 2026-01-04 2026-01-01 Pay rent due:2026-01-05 pri:C"},
+	}
+	for _, tt := range tests {
+		if got := FormatTodoTxt(tt.item); got != tt.want {
+			t.Errorf("FormatTodoTxt(%+v)\n got %q\nwant %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestTodoTxt_RoundTrip(t *testing.T) {
+	input := "(A) 2026-01-01 Book flights +Holiday @laptop due:2026-02-01\n" +
+		"\n" +
+		"x 2026-01-04 2026-01-01 Pay rent +Home due:2026-01-05 pri:B\n" +
+		"Bad date due:05-01-2026\n"
+	items, rowErrs, err := ReadTodoTxt(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadTodoTxt failed: %v", err)
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 4 {
+		t.Errorf("Expected an error on line 4, got %+v", rowErrs)
+	}
+
+	var buf bytes.Buffer
+	WriteTodoTxt(&buf, items)
+	want := "(A) 2026-01-01 Book flights +Holiday @laptop due:2026-02-01\n" +
+		"x 2026-01-04 2026-01-01 Pay rent +Home due:2026-01-05 pri:B\n"
+	if buf.String() != want {
+		t.Errorf("Round trip changed the file:\n got %q\nwant %q", buf.String(), want)
+	}
+}
+
+func TestReadTodoTxt_NoDueDate(t *testing.T) {
+	items, rowErrs, err := ReadTodoTxt(strings.NewReader("(B) Call the garage +Car\nx Pay rent\n"))
+	if err != nil || len(rowErrs) != 0 || len(items) != 2 {
+		t.Fatalf("Expected both lines to import, got %+v %+v (%v)", items, rowErrs, err)
+	}
+	today := time.Now().Format(DueLayout)
+	if items[0].Due != today || items[0].Name != "Call the garage +Car" || items[0].Priority != 2 || items[1].Due != today {
+		t.Errorf("Expected the items to be due today, got %+v", items)
+	}
+}
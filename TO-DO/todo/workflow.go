@@ -0,0 +1,243 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WorkflowState is one column of a board.
+type WorkflowState struct {
+	Name string `json:"name"`
+	Done bool   `json:"done,omitempty"` // items in a done state are Completed
+}
+
+// Workflow is the set of statuses the items of a list move through, and which moves are allowed.
+type Workflow struct {
+	// States are in board order. New items start in the first one, which must not be done.
+	States []WorkflowState `json:"states"`
+	// Transitions maps a state to the states an item may move to from it. If it is empty, any move is allowed.
+	Transitions map[string][]string `json:"transitions,omitempty"`
+}
+
+// DefaultWorkflow is used for items whose list has no workflow of its own.
+// Items from before workflows existed are migrated to "todo" or "done".
+var DefaultWorkflow = Workflow{
+	States: []WorkflowState{{Name: "todo"}, {Name: "in_progress"}, {Name: "in_review"}, {Name: "done", Done: true}},
+	Transitions: map[string][]string{
+		"todo":        {"in_progress", "done"},
+		"in_progress": {"todo", "in_review", "done"},
+		"in_review":   {"in_progress", "done"},
+		"done":        {"todo", "in_progress"},
+	},
+}
+
+var (
+	// ErrUnknownStatus is returned (wrapped) for a status that isn't a state of the item's workflow.
+	ErrUnknownStatus = errors.New("unknown status")
+	// ErrTransitionNotAllowed is returned (wrapped) when the workflow doesn't allow a move.
+	ErrTransitionNotAllowed = errors.New("transition not allowed")
+	// ErrWorkflowInUse is returned (wrapped) when changing a workflow would leave items in a status it doesn't have.
+	ErrWorkflowInUse = errors.New("workflow is in use")
+)
+
+var stateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Validate checks that the workflow has uniquely named states, a starting state that isn't done, at least one done
+// state, and transitions between known states only.
+func (wf Workflow) Validate() error {
+	if len(wf.States) < 2 {
+		return errors.New("a workflow needs at least two states")
+	}
+	seen := make(map[string]bool, len(wf.States))
+	hasDone := false
+	for _, s := range wf.States {
+		if !stateName.MatchString(s.Name) {
+			return fmt.Errorf("invalid state name %q (use up to 32 lower-case letters, digits, _ or -)", s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("duplicate state %q", s.Name)
+		}
+		seen[s.Name] = true
+		hasDone = hasDone || s.Done
+	}
+	if wf.States[0].Done {
+		return errors.New("the first state is where new items start, so it can't be done")
+	}
+	if !hasDone {
+		return errors.New("a workflow needs at least one done state")
+	}
+	for from, tos := range wf.Transitions {
+		if !seen[from] {
+			return fmt.Errorf("transition from unknown state %q", from)
+		}
+		for _, to := range tos {
+			if !seen[to] {
+				return fmt.Errorf("transition from %q to unknown state %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Has reports whether status is one of the workflow's states.
+func (wf Workflow) Has(status string) bool {
+	return slices.ContainsFunc(wf.States, func(s WorkflowState) bool { return s.Name == status })
+}
+
+// IsDone reports whether status is a done state.
+func (wf Workflow) IsDone(status string) bool {
+	return slices.ContainsFunc(wf.States, func(s WorkflowState) bool { return s.Name == status && s.Done })
+}
+
+// Initial is the state new items start in.
+func (wf Workflow) Initial() string {
+	return wf.States[0].Name
+}
+
+// FirstDone is the done state items move to when they are simply marked completed.
+func (wf Workflow) FirstDone() string {
+	for _, s := range wf.States {
+		if s.Done {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// Allows reports whether an item may move from one state to another.
+func (wf Workflow) Allows(from, to string) bool {
+	if from == to || !wf.Has(to) {
+		return false
+	}
+	if len(wf.Transitions) == 0 {
+		return true
+	}
+	return slices.Contains(wf.Transitions[from], to)
+}
+
+// Targets lists the states an item in the given state may move to, in board order.
+func (wf Workflow) Targets(from string) []string {
+	var targets []string
+	for _, s := range wf.States {
+		if wf.Allows(from, s.Name) {
+			targets = append(targets, s.Name)
+		}
+	}
+	return targets
+}
+
+// ListOf is the list an item belongs to: its first project, or "" if it has none.
+func ListOf(item Item) string {
+	if len(item.Projects) == 0 {
+		return ""
+	}
+	return item.Projects[0]
+}
+
+// WorkflowFor returns the workflow of the item's list, or DefaultWorkflow if the list has none.
+func WorkflowFor(workflows map[string]Workflow, item Item) Workflow {
+	if wf, ok := workflows[ListOf(item)]; ok {
+		return wf
+	}
+	return DefaultWorkflow
+}
+
+// initialStatus picks the status of a new item: the requested one if given, otherwise the first done state for a
+// completed item and the first state for any other.
+func initialStatus(workflows map[string]Workflow, item Item, requested string) (string, error) {
+	wf := WorkflowFor(workflows, item)
+	switch {
+	case requested == "" && item.Completed:
+		return wf.FirstDone(), nil
+	case requested == "":
+		return wf.Initial(), nil
+	case !wf.Has(requested):
+		return "", fmt.Errorf("%w %q for list %q", ErrUnknownStatus, requested, ListOf(item))
+	}
+	return requested, nil
+}
+
+// setStatus moves an item to a status and keeps Completed and CompletedAt in line with it.
+func setStatus(item *Item, wf Workflow, status string, now time.Time) {
+	item.Status = status
+	done := wf.IsDone(status)
+	if done && !item.Completed {
+		item.CompletedAt = now
+	} else if !done {
+		item.CompletedAt = time.Time{}
+	}
+	item.Completed = done
+}
+
+// syncStatus fixes an item's status after Completed was changed directly, as older clients do: completing an item
+// moves it to the first done state and reopening it moves it back to the first state, whatever the transitions say.
+func syncStatus(item *Item, wf Workflow) {
+	if item.Completed != wf.IsDone(item.Status) || !wf.Has(item.Status) {
+		if item.Completed {
+			item.Status = wf.FirstDone()
+		} else {
+			item.Status = wf.Initial()
+		}
+	}
+}
+
+// TransitionToDo moves the item with the given ID to another status of its workflow, if the workflow allows it.
+func TransitionToDo(toDos []Item, id int, status string, workflows map[string]Workflow) ([]Item, error) {
+	i := indexOf(toDos, id)
+	if i < 0 {
+		return toDos, fmt.Errorf("item with id %d %w", id, ErrNotFound)
+	}
+	item := &toDos[i]
+	wf := WorkflowFor(workflows, *item)
+	if !wf.Has(status) {
+		return toDos, fmt.Errorf("%w %q for list %q", ErrUnknownStatus, status, ListOf(*item))
+	}
+	if !wf.Allows(item.Status, status) {
+		return toDos, fmt.Errorf("%w from %q to %q", ErrTransitionNotAllowed, item.Status, status)
+	}
+	now := time.Now().UTC()
+	setStatus(item, wf, status, now)
+	item.UpdatedAt = now
+	return toDos, nil
+}
+
+// checkStatuses reports the items whose status isn't a state of their workflow under the given workflows.
+func checkStatuses(items []Item, workflows map[string]Workflow) error {
+	var stranded []string
+	for _, item := range items {
+		if !WorkflowFor(workflows, item).Has(item.Status) {
+			stranded = append(stranded, fmt.Sprintf("#%d (%s)", item.ID, item.Status))
+		}
+	}
+	if len(stranded) > 0 {
+		sort.Strings(stranded)
+		return fmt.Errorf("%w: items %s would be left in a status the workflow doesn't have; move them first", ErrWorkflowInUse, strings.Join(stranded, ", "))
+	}
+	return nil
+}
+
+// SetWorkflow sets (or, with a nil wf, removes) the workflow of a list and returns the new set of workflows.
+// It fails if any item of the list is in a status the new workflow doesn't have.
+func SetWorkflow(items []Item, workflows map[string]Workflow, list string, wf *Workflow) (map[string]Workflow, error) {
+	updated := make(map[string]Workflow, len(workflows)+1)
+	for name, existing := range workflows {
+		updated[name] = existing
+	}
+	if wf == nil {
+		delete(updated, list)
+	} else {
+		if err := wf.Validate(); err != nil {
+			return workflows, err
+		}
+		updated[list] = *wf
+	}
+	if err := checkStatuses(items, updated); err != nil {
+		return workflows, err
+	}
+	return updated, nil
+}
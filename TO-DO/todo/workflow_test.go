@@ -0,0 +1,156 @@
+package todo
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkflow_Validate(t *testing.T) {
+	if err := DefaultWorkflow.Validate(); err != nil {
+		t.Fatalf("DefaultWorkflow is invalid: %v", err)
+	}
+	tests := map[string]Workflow{
+		"one state":          {States: []WorkflowState{{Name: "todo"}}},
+		"no done state":      {States: []WorkflowState{{Name: "todo"}, {Name: "doing"}}},
+		"starts done":        {States: []WorkflowState{{Name: "done", Done: true}, {Name: "todo"}}},
+		"duplicate":          {States: []WorkflowState{{Name: "todo"}, {Name: "todo", Done: true}}},
+		"bad name":           {States: []WorkflowState{{Name: "To Do"}, {Name: "done", Done: true}}},
+		"unknown from state": {States: []WorkflowState{{Name: "todo"}, {Name: "done", Done: true}}, Transitions: map[string][]string{"doing": {"done"}}},
+		"unknown to state":   {States: []WorkflowState{{Name: "todo"}, {Name: "done", Done: true}}, Transitions: map[string][]string{"todo": {"doing"}}},
+	}
+	for name, wf := range tests {
+		if err := wf.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWorkflow_Transitions(t *testing.T) {
+	wf := DefaultWorkflow
+	if !wf.Allows("todo", "in_progress") || wf.Allows("todo", "in_review") || wf.Allows("todo", "todo") || wf.Allows("todo", "nope") {
+		t.Errorf("Unexpected transitions from todo: %v", wf.Targets("todo"))
+	}
+	if got := wf.Targets("in_progress"); len(got) != 3 || got[0] != "todo" || got[2] != "done" {
+		t.Errorf("Expected targets in board order, got %v", got)
+	}
+	if wf.Initial() != "todo" || wf.FirstDone() != "done" || !wf.IsDone("done") || wf.IsDone("in_review") {
+		t.Errorf("Unexpected initial or done states")
+	}
+}
+
+func TestTransitionToDo(t *testing.T) {
+	toDos := []Item{{ID: 1, Name: "a", Status: "todo"}}
+	toDos, err := TransitionToDo(toDos, 1, "done", nil)
+	if err != nil || toDos[0].Status != "done" || !toDos[0].Completed || toDos[0].CompletedAt.IsZero() {
+		t.Fatalf("Expected the item to be completed, got %+v (%v)", toDos[0], err)
+	}
+	if _, err := TransitionToDo(toDos, 1, "in_review", nil); !errors.Is(err, ErrTransitionNotAllowed) {
+		t.Errorf("Expected ErrTransitionNotAllowed, got %v", err)
+	}
+	if _, err := TransitionToDo(toDos, 1, "someday", nil); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus, got %v", err)
+	}
+	if _, err := TransitionToDo(toDos, 2, "todo", nil); err == nil {
+		t.Errorf("Expected an error for a missing item")
+	}
+	toDos, _ = TransitionToDo(toDos, 1, "in_progress", nil)
+	if toDos[0].Completed || !toDos[0].CompletedAt.IsZero() {
+		t.Errorf("Expected reopening to clear Completed, got %+v", toDos[0])
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	// Older clients only toggle Completed; that wins over the transitions.
+	item := Item{Status: "in_review", Completed: true}
+	syncStatus(&item, DefaultWorkflow)
+	if item.Status != "done" {
+		t.Errorf("Expected completing to move to done, got %q", item.Status)
+	}
+	item.Completed = false
+	syncStatus(&item, DefaultWorkflow)
+	if item.Status != "todo" {
+		t.Errorf("Expected reopening to move to todo, got %q", item.Status)
+	}
+	item.Status = "in_progress"
+	syncStatus(&item, DefaultWorkflow)
+	if item.Status != "in_progress" {
+		t.Errorf("Expected a consistent status to be kept, got %q", item.Status)
+	}
+}
+
+func TestSetWorkflow(t *testing.T) {
+	items := []Item{{ID: 1, Projects: []string{"Work"}, Status: "in_review"}}
+	lean := &Workflow{States: []WorkflowState{{Name: "todo"}, {Name: "done", Done: true}}}
+	if _, err := SetWorkflow(items, nil, "Work", lean); !errors.Is(err, ErrWorkflowInUse) {
+		t.Errorf("Expected ErrWorkflowInUse, got %v", err)
+	}
+	// Another list isn't affected.
+	workflows, err := SetWorkflow(items, nil, "Home", lean)
+	if err != nil || len(workflows) != 1 {
+		t.Fatalf("SetWorkflow failed: %v %+v", err, workflows)
+	}
+	if workflows, err = SetWorkflow(items, workflows, "Home", nil); err != nil || len(workflows) != 0 {
+		t.Errorf("Expected the workflow to be removed, got %+v (%v)", workflows, err)
+	}
+	if _, err := SetWorkflow(items, nil, "Home", &Workflow{}); err == nil {
+		t.Errorf("Expected an invalid workflow to be rejected")
+	}
+}
+
+func TestStartStore_Workflows(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todos.json")
+	Store = make(chan Command)
+	StartStore(filename)
+	t.Cleanup(func() { close(Store) })
+
+	events, unsubscribe := Events.Subscribe(10)
+	defer unsubscribe()
+
+	send := func(cmd Command) (any, error) {
+		cmd.Result, cmd.ErrChan = make(chan any), make(chan error)
+		Store <- cmd
+		select {
+		case r := <-cmd.Result:
+			return r, nil
+		case err := <-cmd.ErrChan:
+			return nil, err
+		}
+	}
+	ship := &Workflow{States: []WorkflowState{{Name: "backlog"}, {Name: "shipped", Done: true}}}
+	if _, err := send(Command{Action: OpSetWorkflow, List: "App", Workflow: ship}); err != nil {
+		t.Fatalf("OpSetWorkflow failed: %v", err)
+	}
+	added, _ := send(Command{Action: OpAdd, Item: Item{Name: "Release", Due: "01-01-2026", Projects: []string{"App"}}})
+	if added.(Item).Status != "backlog" {
+		t.Errorf("Expected a new item to start in the list's first state, got %+v", added)
+	}
+	if _, err := send(Command{Action: OpAdd, Item: Item{Name: "Bad", Due: "01-01-2026", Status: "backlog"}}); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus for a status of another list, got %v", err)
+	}
+	<-events // added
+
+	moved, err := send(Command{Action: OpTransition, ID: 1, Status: "shipped"})
+	if err != nil || !moved.(Item).Completed {
+		t.Fatalf("OpTransition failed: %+v (%v)", moved, err)
+	}
+	if e := <-events; e.Type != EventCompleted || e.ItemID != 1 {
+		t.Errorf("Expected a completed event, got %+v", e)
+	}
+
+	// Reopening through the old Completed flag moves the item back to the start.
+	reopen := false
+	updated, _ := send(Command{Action: OpUpdate, ID: 1, UpdatePayload: ItemUpdate{Completed: &reopen}})
+	if updated.(Item).Status != "backlog" {
+		t.Errorf("Expected the item back in backlog, got %+v", updated)
+	}
+
+	// The workflows are saved with the items.
+	if _, err := send(Command{Action: OpShutdown}); err != nil {
+		t.Fatalf("OpShutdown failed: %v", err)
+	}
+	snap, err := LoadSnapshot(filename, t.Context())
+	if err != nil || len(snap.Workflows["App"].States) != 2 || snap.Items[0].Status != "backlog" {
+		t.Errorf("Expected the workflow and statuses on disk, got %+v (%v)", snap, err)
+	}
+}
@@ -0,0 +1,467 @@
+// Package webhook delivers todo change events to subscribed HTTP endpoints.
+//
+// A Dispatcher subscribes to the actor's event broker (todo.Events) and, for every event matching
+// a subscription's filter, POSTs a signed JSON payload to the subscription's URL. Failed deliveries are
+// retried with exponential backoff; deliveries that never succeed end up on a dead-letter list.
+//
+// Unless Config.AllowPrivate is set, deliveries only go to public addresses: a subscription can't be used to
+// reach loopback, private or link-local addresses (such as a cloud metadata service) from the server.
+package webhook
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var Filename string = "webhooks.json"
+
+// ErrNotFound is returned for subscriptions that don't exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrPrivateTarget is returned for subscription URLs, and deliveries, that would reach a loopback, private or
+// link-local address while Config.AllowPrivate is off.
+var ErrPrivateTarget = errors.New("webhooks may not be sent to loopback, private or link-local addresses")
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "X-Todo-Signature" // "sha256=" followed by the hex HMAC-SHA256 of the body, keyed with the subscription secret
+	EventHeader     = "X-Todo-Event"
+	DeliveryHeader  = "X-Todo-Delivery"
+)
+
+// Subscription is a registered webhook receiver.
+// An empty Events filter means the subscription receives every event type.
+type Subscription struct {
+	ID        string           `json:"id"`
+	URL       string           `json:"url"`
+	Events    []todo.EventType `json:"events,omitempty"`
+	Secret    string           `json:"secret,omitempty"`
+	Tenant    string           `json:"tenant,omitempty"` // only events from this tenant's store are delivered; "" is the default store
+	Owner     string           `json:"owner,omitempty"`  // the ID of the user who created it; only events they may see are delivered
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants events of type t.
+func (s Subscription) Matches(t todo.EventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, t)
+}
+
+// Validate checks the URL and the event filter.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, t := range s.Events {
+		switch t {
+		case todo.EventCreated, todo.EventUpdated, todo.EventCompleted, todo.EventDeleted, todo.EventRestored,
+			todo.EventCommented, todo.EventCommentEdited, todo.EventCommentDeleted, todo.EventAssigned, todo.EventUnassigned:
+		default:
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return nil
+}
+
+// Payload is the JSON body POSTed to a subscriber.
+type Payload struct {
+	DeliveryID     string     `json:"delivery_id"`
+	SubscriptionID string     `json:"subscription_id"`
+	Event          todo.Event `json:"event"`
+}
+
+// Delivery records the outcome of one delivery (after all of its attempts).
+type Delivery struct {
+	ID             string         `json:"id"`
+	SubscriptionID string         `json:"subscription_id"`
+	Tenant         string         `json:"tenant,omitempty"`
+	Owner          string         `json:"owner,omitempty"`
+	URL            string         `json:"url"`
+	EventType      todo.EventType `json:"event_type"`
+	ItemID         int            `json:"item_id"`
+	Attempts       int            `json:"attempts"`
+	StatusCode     int            `json:"status_code,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Success        bool           `json:"success"`
+	Time           time.Time      `json:"time"`
+}
+
+// Sign returns the value of the SignatureHeader for body.
+// Receivers recompute it with their copy of the secret and compare using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Config controls delivery behaviour. Zero values are replaced by the defaults below.
+type Config struct {
+	Filename    string        // where subscriptions are persisted; empty keeps them in memory only
+	MaxAttempts int           // total attempts per delivery, including the first
+	BaseDelay   time.Duration // delay before the first retry; doubled for each further retry
+	MaxDelay    time.Duration // upper bound on the retry delay
+	Timeout     time.Duration // per-attempt HTTP timeout
+	LogSize     int           // number of deliveries kept per subscription in the delivery log, and in the dead-letter list
+	// AllowPrivate lets subscriptions reach loopback, private and link-local addresses, e.g. a receiver on the
+	// same host or network. Off by default, since it lets anyone who can create a webhook probe the server's network.
+	AllowPrivate bool
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = time.Second
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.LogSize <= 0 {
+		c.LogSize = 100
+	}
+	return c
+}
+
+// Dispatcher owns the subscriptions, the delivery log and the dead-letter list.
+type Dispatcher struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	subs        []Subscription
+	log         []Delivery // most recent last, at most cfg.LogSize entries per subscription
+	deadLetters []Delivery // likewise
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher and loads any persisted subscriptions.
+func NewDispatcher(cfg Config, ctx context.Context) (*Dispatcher, error) {
+	cfg = cfg.withDefaults()
+	d := &Dispatcher{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+	if !cfg.AllowPrivate {
+		// The address is checked as it is dialled, after any DNS lookup and for every redirect, so a name that
+		// resolves to a private address is caught too. No proxy is used, since it would dial on our behalf.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{Timeout: cfg.Timeout, Control: dialPublic}).DialContext
+		d.client.Transport = transport
+	}
+	if cfg.Filename == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(cfg.Filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			slog.Default().Log(ctx, slog.LevelInfo, "Webhook file not found, starting with no subscriptions.", "file", cfg.Filename)
+			return d, nil
+		}
+		return nil, fmt.Errorf("could not read file %s: %w", cfg.Filename, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &d.subs); err != nil {
+			return nil, fmt.Errorf("could not unmarshal webhooks: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// Start subscribes to the actor's events and delivers them in the background until Stop is called.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	events, unsubscribe := todo.Events.Subscribe(256)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer unsubscribe()
+		for {
+			select {
+			case e := <-events:
+				d.dispatch(ctx, e)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop cancels pending retries and waits for in-flight deliveries to finish.
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+// dispatch starts one delivery goroutine per subscription of the event's tenant matching the event, as long as
+// the subscription's owner may see the event; a subscription without an owner only gets events about unowned items.
+func (d *Dispatcher) dispatch(ctx context.Context, e todo.Event) {
+	d.mu.Lock()
+	var targets []Subscription
+	for _, s := range d.subs {
+		if s.Tenant == e.Tenant && s.Matches(e.Type) && e.VisibleTo(s.Owner) {
+			targets = append(targets, s)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, s := range targets {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(ctx, s, e)
+		}()
+	}
+}
+
+// deliver POSTs the event to one subscriber, retrying with exponential backoff,
+// and records the outcome in the delivery log (and the dead-letter list if every attempt failed).
+func (d *Dispatcher) deliver(ctx context.Context, s Subscription, e todo.Event) {
+	delivery := Delivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: s.ID,
+		Tenant:         s.Tenant,
+		Owner:          s.Owner,
+		URL:            s.URL,
+		EventType:      e.Type,
+		ItemID:         e.ItemID,
+	}
+	body, err := json.Marshal(Payload{DeliveryID: delivery.ID, SubscriptionID: s.ID, Event: e})
+	if err != nil {
+		delivery.Error = err.Error()
+		d.record(ctx, delivery)
+		return
+	}
+
+	delay := d.cfg.BaseDelay
+	for delivery.Attempts < d.cfg.MaxAttempts {
+		delivery.Attempts++
+		delivery.StatusCode, err = d.post(ctx, s, delivery.ID, e.Type, body)
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+		slog.Default().Log(ctx, slog.LevelWarn, "Webhook delivery attempt failed.",
+			"subscription", s.ID, "delivery", delivery.ID, "attempt", delivery.Attempts, "error", err)
+
+		if delivery.Attempts == d.cfg.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			delivery.Error = "dispatcher stopped before delivery succeeded: " + delivery.Error
+			d.record(ctx, delivery)
+			return
+		}
+		delay = min(delay*2, d.cfg.MaxDelay)
+	}
+	d.record(ctx, delivery)
+}
+
+// post makes a single delivery attempt. Any non-2xx response counts as a failure.
+func (d *Dispatcher) post(ctx context.Context, s Subscription, deliveryID string, t todo.EventType, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(t))
+	req.Header.Set(DeliveryHeader, deliveryID)
+	if s.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(ctx context.Context, delivery Delivery) {
+	delivery.Time = time.Now().UTC()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.log = appendCapped(d.log, delivery, d.cfg.LogSize)
+	if !delivery.Success {
+		d.deadLetters = appendCapped(d.deadLetters, delivery, d.cfg.LogSize)
+		slog.Default().Log(ctx, slog.LevelError, "Webhook delivery moved to dead-letter list.",
+			"subscription", delivery.SubscriptionID, "delivery", delivery.ID, "error", delivery.Error)
+		return
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "Webhook delivered.",
+		"subscription", delivery.SubscriptionID, "delivery", delivery.ID, "attempts", delivery.Attempts)
+}
+
+// appendCapped appends delivery to list and drops the oldest delivery of the same subscription once it has more
+// than n, so one busy or failing subscription can't push the others' history out.
+func appendCapped(list []Delivery, delivery Delivery, n int) []Delivery {
+	list = append(list, delivery)
+	oldest, count := -1, 0
+	for i, x := range list {
+		if x.SubscriptionID == delivery.SubscriptionID {
+			if oldest < 0 {
+				oldest = i
+			}
+			count++
+		}
+	}
+	if count > n {
+		list = slices.Delete(list, oldest, oldest+1)
+	}
+	return list
+}
+
+// Add validates and registers a new subscription, assigning its ID.
+func (d *Dispatcher) Add(s Subscription, ctx context.Context) (Subscription, error) {
+	if err := s.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	if !d.cfg.AllowPrivate {
+		if err := checkPublicHost(s.URL); err != nil {
+			return Subscription{}, err
+		}
+	}
+	s.ID = uuid.New().String()
+	s.CreatedAt = time.Now().UTC()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs = append(d.subs, s)
+	if err := d.save(ctx); err != nil {
+		d.subs = d.subs[:len(d.subs)-1]
+		return Subscription{}, err
+	}
+	return s, nil
+}
+
+// Remove deletes the subscription with the given ID, along with its deliveries and dead letters.
+func (d *Dispatcher) Remove(id string, ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := slices.IndexFunc(d.subs, func(s Subscription) bool { return s.ID == id })
+	if i < 0 {
+		return fmt.Errorf("webhook with id %s %w", id, ErrNotFound)
+	}
+	removed := d.subs[i]
+	d.subs = slices.Delete(d.subs, i, i+1)
+	if err := d.save(ctx); err != nil {
+		d.subs = slices.Insert(d.subs, i, removed)
+		return err
+	}
+	ofRemoved := func(x Delivery) bool { return x.SubscriptionID == id }
+	d.log = slices.DeleteFunc(d.log, ofRemoved)
+	d.deadLetters = slices.DeleteFunc(d.deadLetters, ofRemoved)
+	return nil
+}
+
+// Subscriptions returns a copy of the registered subscriptions.
+func (d *Dispatcher) Subscriptions() []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.subs)
+}
+
+// Deliveries returns a copy of the delivery log, oldest first.
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.log)
+}
+
+// DeadLetters returns a copy of the deliveries that failed every attempt.
+func (d *Dispatcher) DeadLetters() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.deadLetters)
+}
+
+// checkPublicHost refuses URLs whose host is obviously not public: localhost, or a private IP address.
+// Other names are checked when a delivery dials them (see dialPublic).
+func checkPublicHost(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateTarget
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !isPublic(ip) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// dialPublic is a net.Dialer Control function that refuses connections to addresses that aren't public.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addr.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, addr.Addr())
+	}
+	return nil
+}
+
+// isPublic reports whether ip is a public unicast address: not loopback, private (including IPv6 unique local
+// addresses), link-local (including the 169.254.169.254 metadata service), shared (100.64.0.0/10) or unspecified.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't reachable from the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// save writes the subscriptions to disk. The caller must hold d.mu.
+func (d *Dispatcher) save(ctx context.Context) error {
+	if d.cfg.Filename == "" {
+		return nil
+	}
+	data, err := json.Marshal(d.subs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.cfg.Filename, data, 0600); err != nil { // 0600: the file contains secrets
+		return err
+	}
+	slog.Default().Log(ctx, slog.LevelInfo, "Webhook subscriptions saved to disk", "file", d.cfg.Filename, "count", len(d.subs))
+	return nil
+}
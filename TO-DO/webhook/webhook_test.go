@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"GoAcademy/TO-DO/todo"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it returns true or the deadline passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newTestDispatcher starts a dispatcher that may deliver to local receivers, such as httptest servers.
+func newTestDispatcher(t *testing.T, cfg Config) *Dispatcher {
+	t.Helper()
+	cfg.AllowPrivate = true
+	d, err := NewDispatcher(cfg, context.Background())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	d.Start(context.Background())
+	t.Cleanup(d.Stop)
+	return d
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{})
+	sub, err := d.Add(Subscription{URL: receiver.URL, Secret: "s3cret"}, context.Background())
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 7, Item: todo.Item{ID: 7, Name: "Hook"}})
+
+	r := <-received
+	body := <-bodies
+	if got, want := r.Header.Get(SignatureHeader), Sign("s3cret", body); got != want {
+		t.Errorf("Signature mismatch: got %q, want %q", got, want)
+	}
+	if r.Header.Get(EventHeader) != "created" {
+		t.Errorf("Expected event header 'created', got %q", r.Header.Get(EventHeader))
+	}
+
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if p.SubscriptionID != sub.ID || p.Event.ItemID != 7 || p.Event.Item.Name != "Hook" {
+		t.Errorf("Unexpected payload: %+v", p)
+	}
+
+	waitFor(t, func() bool { return len(d.Deliveries()) == 1 })
+	if delivery := d.Deliveries()[0]; !delivery.Success || delivery.Attempts != 1 {
+		t.Errorf("Expected one successful attempt, got %+v", delivery)
+	}
+}
+
+func TestDispatcher_FiltersEvents(t *testing.T) {
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{})
+	d.Add(Subscription{URL: receiver.URL, Events: []todo.EventType{todo.EventCompleted}}, context.Background())
+
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 1})
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCompleted, ItemID: 1})
+
+	waitFor(t, func() bool { return len(d.Deliveries()) == 1 })
+	if d.Deliveries()[0].EventType != todo.EventCompleted || hits.Load() != 1 {
+		t.Errorf("Expected only the completed event to be delivered, got %+v", d.Deliveries())
+	}
+}
+
+func TestDispatcher_FiltersTenants(t *testing.T) {
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{})
+	d.Add(Subscription{URL: receiver.URL, Tenant: "acme"}, context.Background())
+
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 1})
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 2, Tenant: "globex"})
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 3, Tenant: "acme"})
+
+	waitFor(t, func() bool { return len(d.Deliveries()) == 1 })
+	if delivery := d.Deliveries()[0]; delivery.ItemID != 3 || delivery.Tenant != "acme" || hits.Load() != 1 {
+		t.Errorf("Expected only acme's event to be delivered, got %+v", d.Deliveries())
+	}
+}
+
+func TestDispatcher_FiltersOwners(t *testing.T) {
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{})
+	bobs, _ := d.Add(Subscription{URL: receiver.URL, Owner: "bob"}, context.Background())
+
+	// Ana's private item is not for bob's webhook; an unowned item is for everyone.
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 1, Readers: []string{"ana"}})
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: 2})
+
+	waitFor(t, func() bool { return len(d.Deliveries()) == 1 })
+	time.Sleep(20 * time.Millisecond) // give a wrong delivery of item 1 time to show up
+	if deliveries := d.Deliveries(); len(deliveries) != 1 || deliveries[0].ItemID != 2 || deliveries[0].Owner != bobs.Owner || hits.Load() != 1 {
+		t.Errorf("Expected only the unowned item to be delivered to bob, got %+v", deliveries)
+	}
+}
+
+func TestDispatcher_RetriesThenSucceeds(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	d.Add(Subscription{URL: receiver.URL}, context.Background())
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventDeleted, ItemID: 3})
+
+	waitFor(t, func() bool { return len(d.Deliveries()) == 1 })
+	delivery := d.Deliveries()[0]
+	if !delivery.Success || delivery.Attempts != 3 || delivery.StatusCode != http.StatusOK {
+		t.Errorf("Expected success on the third attempt, got %+v", delivery)
+	}
+	if len(d.DeadLetters()) != 0 {
+		t.Errorf("Expected no dead letters, got %+v", d.DeadLetters())
+	}
+}
+
+func TestDispatcher_DeadLettersAfterMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(receiver.Close)
+
+	d := newTestDispatcher(t, Config{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	d.Add(Subscription{URL: receiver.URL}, context.Background())
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventUpdated, ItemID: 2})
+
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+	dead := d.DeadLetters()[0]
+	if dead.Success || dead.Attempts != 3 || dead.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Unexpected dead letter: %+v", dead)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, receiver saw %d", calls.Load())
+	}
+}
+
+func TestDispatcher_PersistsSubscriptions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "webhooks.json")
+	ctx := context.Background()
+
+	d, _ := NewDispatcher(Config{Filename: filename}, ctx)
+	kept, _ := d.Add(Subscription{URL: "http://example.com/keep"}, ctx)
+	removed, _ := d.Add(Subscription{URL: "http://example.com/remove"}, ctx)
+	if err := d.Remove(removed.ID, ctx); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := d.Remove("missing", ctx); err == nil {
+		t.Error("Expected error removing unknown subscription")
+	}
+
+	reloaded, err := NewDispatcher(Config{Filename: filename}, ctx)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	subs := reloaded.Subscriptions()
+	if len(subs) != 1 || subs[0].ID != kept.ID {
+		t.Errorf("Expected only %s after reload, got %+v", kept.ID, subs)
+	}
+}
+
+func TestDispatcher_RefusesPrivateTargets(t *testing.T) {
+	ctx := context.Background()
+	d, _ := NewDispatcher(Config{MaxAttempts: 1}, ctx)
+	for _, u := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://[::1]/hook", "http://10.1.2.3/hook",
+		"http://169.254.169.254/latest/meta-data/", "http://[fd00:ec2::254]/hook", "http://[::ffff:192.168.0.1]/hook", "http://0.0.0.0/hook"} {
+		if _, err := d.Add(Subscription{URL: u}, ctx); !errors.Is(err, ErrPrivateTarget) {
+			t.Errorf("%s: expected ErrPrivateTarget, got %v", u, err)
+		}
+	}
+	if _, err := d.Add(Subscription{URL: "https://example.com/hook"}, ctx); err != nil {
+		t.Errorf("Expected a public host to be accepted, got %v", err)
+	}
+
+	// Addresses are checked again as they are dialled, so names that resolve to private addresses (and
+	// subscriptions saved before the check) are refused too.
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer receiver.Close()
+	s := Subscription{ID: "saved-before", URL: receiver.URL}
+	if _, err := d.post(ctx, s, "1", todo.EventCreated, []byte("{}")); !errors.Is(err, ErrPrivateTarget) || calls.Load() != 0 {
+		t.Errorf("Expected the delivery to be refused, got %v with %d calls", err, calls.Load())
+	}
+}
+
+func TestDispatcher_CapsDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	d := newTestDispatcher(t, Config{MaxAttempts: 1, LogSize: 2})
+	quiet, err := d.Add(Subscription{URL: receiver.URL, Events: []todo.EventType{todo.EventUpdated}}, context.Background())
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	noisy, err := d.Add(Subscription{URL: receiver.URL, Events: []todo.EventType{todo.EventCreated}}, context.Background())
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventUpdated, ItemID: 9})
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+	for id := 1; id <= 3; id++ {
+		todo.Events.Publish(context.Background(), todo.Event{Type: todo.EventCreated, ItemID: id})
+		waitFor(t, func() bool { log := d.Deliveries(); return len(log) > 0 && log[len(log)-1].ItemID == id })
+	}
+	// The noisy subscription keeps only its 2 latest, and doesn't push out the quiet one's.
+	dead := d.DeadLetters()
+	if len(dead) != 3 || dead[0].SubscriptionID != quiet.ID || dead[1].ItemID != 2 || dead[2].ItemID != 3 {
+		t.Errorf("Expected the quiet dead letter and the 2 latest noisy ones, got %+v", dead)
+	}
+	if log := d.Deliveries(); len(log) != 3 {
+		t.Errorf("Expected 3 deliveries in the log, got %+v", log)
+	}
+
+	if err := d.Remove(quiet.ID, context.Background()); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if dead := d.DeadLetters(); len(dead) != 2 || dead[0].SubscriptionID != noisy.ID {
+		t.Errorf("Expected the removed subscription's dead letters to be dropped, got %+v", dead)
+	}
+}
+
+func TestSubscription_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  Subscription
+		ok   bool
+	}{
+		{"valid", Subscription{URL: "https://example.com/hook", Events: []todo.EventType{todo.EventCreated}}, true},
+		{"relative url", Subscription{URL: "/hook"}, false},
+		{"bad scheme", Subscription{URL: "ftp://example.com"}, false},
+		{"assignment events", Subscription{URL: "https://example.com/hook", Events: []todo.EventType{todo.EventAssigned, todo.EventUnassigned}}, true},
+		{"unknown event", Subscription{URL: "https://example.com", Events: []todo.EventType{"exploded"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sub.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
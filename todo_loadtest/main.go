@@ -1,18 +1,29 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 )
 
+// errRateLimited is reported for requests the server turned away with 429 Too Many Requests.
+var errRateLimited = errors.New("rate limited")
+
 func main() {
 	// Configuration
 	// We will hit the /get endpoint as it is safe to call repeatedly without filling up memory.
 	targetURL := "http://localhost:8080/get"
 	concurrency := 50      // Number of parallel users (goroutines)
 	totalRequests := 10000 // Total requests to send across all users
+	// The server needs a login, so we send an API token with the read scope (create one with POST /api/v1/auth/tokens).
+	token := os.Getenv("TODO_TOKEN")
+	if token == "" {
+		fmt.Println("Set TODO_TOKEN to an API token with the read scope.")
+		os.Exit(1)
+	}
 
 	fmt.Printf("Bombarding %s with %d requests using %d concurrent workers...\n", targetURL, totalRequests, concurrency)
 
@@ -40,7 +51,13 @@ func main() {
 
 			for range jobs {
 				// Perform the HTTP Request
-				resp, err := client.Get(targetURL)
+				req, err := http.NewRequest(http.MethodGet, targetURL, nil)
+				if err != nil {
+					results <- err
+					continue
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+				resp, err := client.Do(req)
 				if err != nil {
 					results <- err
 					continue
@@ -49,7 +66,9 @@ func main() {
 				// If we don't do this, we will run out of file descriptors/ports very quickly.
 				resp.Body.Close()
 
-				if resp.StatusCode != http.StatusOK {
+				if resp.StatusCode == http.StatusTooManyRequests {
+					results <- errRateLimited
+				} else if resp.StatusCode != http.StatusOK {
 					results <- fmt.Errorf("status code: %d", resp.StatusCode)
 				} else {
 					results <- nil // Success
@@ -72,9 +91,11 @@ func main() {
 	duration := time.Since(start)
 
 	// 4. Calculate Statistics
-	var successCount, failCount int
+	var successCount, failCount, limitedCount int
 	for err := range results {
-		if err != nil {
+		if errors.Is(err, errRateLimited) {
+			limitedCount++
+		} else if err != nil {
 			failCount++
 		} else {
 			successCount++
@@ -86,5 +107,6 @@ func main() {
 	fmt.Printf("Total reqs:   %d\n", totalRequests)
 	fmt.Printf("Success:      %d\n", successCount)
 	fmt.Printf("Failed:       %d\n", failCount)
+	fmt.Printf("Rate limited: %d\n", limitedCount)
 	fmt.Printf("Throughput:   %.2f req/sec\n", float64(totalRequests)/duration.Seconds())
 }